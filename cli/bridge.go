@@ -0,0 +1,197 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"mcphub/mcp"
+	"mcphub/services"
+
+	"github.com/spf13/cobra"
+)
+
+var bridgeCmd = &cobra.Command{
+	Use:   "bridge <image_name>",
+	Short: "Serve a stdio MCP server over streamable HTTP and SSE",
+	Long: `Serve a stdio MCP server image to remote clients. Every client session gets its own
+throwaway container of the image, started when the client initializes and removed
+when the session ends or stays idle.
+
+The streamable HTTP endpoint is /mcp; legacy SSE clients connect to /sse.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		rt, err := containerRuntime()
+		if err != nil {
+			return err
+		}
+		return serveBridge(rt, args[0])
+	},
+}
+
+// newBridge configures a bridge to image from the flags
+func newBridge(rt *services.ContainerRuntime, image string) (*mcp.Bridge, error) {
+	bridge, err := services.NewImageBridge(rt, image, envFlags, os.Stderr)
+	if err != nil {
+		return nil, err
+	}
+	bridge.MaxSessions = maxSessionsFlag
+	bridge.IdleTimeout = idleTimeoutFlag
+	bridge.AllowedOrigins = originFlags
+	bridge.Logf = func(format string, args ...any) {
+		fmt.Printf("%s %s\n", time.Now().Format(time.RFC3339), fmt.Sprintf(format, args...))
+	}
+	return bridge, nil
+}
+
+// serveBridge serves image on --listen until interrupted
+func serveBridge(rt *services.ContainerRuntime, image string) error {
+	if backgroundFlag {
+		// Started by mcphub run: outlive the terminal, and drop the record run wrote on exit
+		signal.Ignore(syscall.SIGHUP)
+		if nameFlag != "" {
+			defer services.RemoveBackgroundBridge(nameFlag, os.Getpid())
+		}
+	}
+	bridge, err := newBridge(rt, image)
+	if err != nil {
+		return err
+	}
+	listener, err := net.Listen("tcp", listenFlag)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %v", listenFlag, err)
+	}
+
+	server := &http.Server{Handler: bridge}
+	served := make(chan error, 1)
+	go func() { served <- server.Serve(listener) }()
+
+	base := bridgeURL(listener.Addr().String())
+	fmt.Printf("🌉 Serving %s\n", image)
+	fmt.Printf("🌐 Streamable HTTP: %s%s\n", base, mcp.BridgePath)
+	fmt.Printf("🌐 SSE: %s%s\n", base, mcp.BridgeSSEPath)
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	select {
+	case err = <-served:
+	case <-stop:
+		fmt.Println("🛑 Stopping the bridge...")
+	}
+	// Ending the sessions first closes their streams, so the shutdown does not wait on them
+	bridge.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	server.Shutdown(ctx)
+	if err != nil && err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
+
+// startBridge runs `mcphub bridge` for image in the background for mcphub run, logging
+// to ~/.mcphub/logs, and waits until it accepts connections
+func startBridge(rt *services.ContainerRuntime, image, name string) error {
+	running, err := services.FindBackgroundBridge(name)
+	if err != nil {
+		return err
+	}
+	if running != nil {
+		return fmt.Errorf("the bridge %s is already running (PID %d); stop it with 'mcphub stop %s' or pick another --name", name, running.PID, name)
+	}
+	// Check the image and its environment here, where errors can be shown
+	if _, err := newBridge(rt, image); err != nil {
+		return err
+	}
+	executable, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	logPath, err := services.BridgeLogPath(name)
+	if err != nil {
+		return fmt.Errorf("failed to create the bridge log: %v", err)
+	}
+	logFile, err := os.OpenFile(logPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to create the bridge log: %v", err)
+	}
+	defer logFile.Close()
+
+	args := []string{"bridge", image, "--background", "--name", name, "--runtime", rt.Name, "--listen", listenFlag,
+		"--max-sessions", fmt.Sprint(maxSessionsFlag), "--idle-timeout", idleTimeoutFlag.String()}
+	for _, env := range envFlags {
+		args = append(args, "-e", env)
+	}
+	for _, origin := range originFlags {
+		args = append(args, "--allow-origin", origin)
+	}
+	bridge := exec.Command(executable, args...)
+	bridge.Stdout = logFile
+	bridge.Stderr = logFile
+	if err := bridge.Start(); err != nil {
+		return fmt.Errorf("failed to start the bridge: %v", err)
+	}
+	exited := make(chan struct{})
+	go func() {
+		bridge.Wait()
+		close(exited)
+	}()
+
+	address := strings.TrimPrefix(bridgeURL(listenFlag), "http://")
+	deadline := time.After(10 * time.Second)
+	for {
+		select {
+		case <-exited:
+			output, _ := os.ReadFile(logPath)
+			return fmt.Errorf("the bridge exited: %s", lastLines(string(output), 10))
+		case <-deadline:
+			return fmt.Errorf("the bridge (PID %d) is not accepting connections on %s; see %s", bridge.Process.Pid, listenFlag, logPath)
+		case <-time.After(100 * time.Millisecond):
+		}
+		if conn, err := net.Dial("tcp", address); err == nil {
+			conn.Close()
+			break
+		}
+	}
+
+	base := bridgeURL(listenFlag)
+	// The record lets mcphub stop and the gateway find the bridge
+	record := &services.BackgroundBridge{Name: name, Image: image, PID: bridge.Process.Pid, URL: base}
+	if err := services.SaveBackgroundBridge(record); err != nil {
+		fmt.Printf("⚠️  Failed to record the bridge: %v\n", err)
+	}
+	fmt.Println("✅ Bridge started successfully!")
+	fmt.Printf("🆔 Bridge PID: %d\n", bridge.Process.Pid)
+	fmt.Printf("🌐 Streamable HTTP: %s%s\n", base, mcp.BridgePath)
+	fmt.Printf("🌐 SSE: %s%s\n", base, mcp.BridgeSSEPath)
+	fmt.Printf("💡 To view logs: tail -f %s\n", logPath)
+	fmt.Printf("💡 To stop: mcphub stop %s\n", name)
+	return nil
+}
+
+// bridgeURL is the base URL clients on this machine reach a listen address at
+func bridgeURL(address string) string {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return "http://" + address
+	}
+	if host == "" || host == "0.0.0.0" || host == "::" {
+		host = "localhost"
+	}
+	return "http://" + net.JoinHostPort(host, port)
+}
+
+func lastLines(text string, count int) string {
+	lines := strings.Split(strings.TrimSpace(text), "\n")
+	if len(lines) > count {
+		lines = lines[len(lines)-count:]
+	}
+	return strings.Join(lines, "\n")
+}
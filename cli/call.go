@@ -0,0 +1,175 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"mcphub/mcp"
+	"mcphub/services"
+
+	"github.com/spf13/cobra"
+)
+
+var callCmd = &cobra.Command{
+	Use:   "call <server> <tool>",
+	Short: "Call a tool on a running or local MCP server",
+	Long: `Call a tool and print its result. <server> is a container started with mcphub run,
+or a local image, which is started in a throwaway container for the call.
+
+Arguments are a JSON object given with --args, or read from a file with --args-file
+("-" reads standard input).`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		arguments, err := toolArguments()
+		if err != nil {
+			return err
+		}
+		rt, err := containerRuntime()
+		if err != nil {
+			return err
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), callTimeout)
+		defer cancel()
+		session, err := services.ConnectServer(ctx, rt, args[0], envFlags)
+		if err != nil {
+			return fmt.Errorf("failed to connect to %s: %v", args[0], err)
+		}
+		defer session.Close()
+
+		result, err := session.Client.CallTool(ctx, args[1], arguments)
+		if err != nil {
+			return fmt.Errorf("failed to call %s: %v", args[1], withToolNames(ctx, session.Client, args[1], err))
+		}
+
+		if jsonFlag {
+			content, err := json.MarshalIndent(result, "", "  ")
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(content))
+		} else {
+			printToolResult(result)
+		}
+		if result.IsError {
+			return fmt.Errorf("tool %s reported an error", args[1])
+		}
+		return nil
+	},
+}
+
+// toolArguments reads the JSON object of --args or --args-file
+func toolArguments() (json.RawMessage, error) {
+	if argsFlag != "" && argsFileFlag != "" {
+		return nil, fmt.Errorf("use either --args or --args-file, not both")
+	}
+	content := []byte(argsFlag)
+	if argsFileFlag == "-" {
+		read, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read arguments: %v", err)
+		}
+		content = read
+	} else if argsFileFlag != "" {
+		read, err := os.ReadFile(argsFileFlag)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read arguments: %v", err)
+		}
+		content = read
+	}
+	if strings.TrimSpace(string(content)) == "" {
+		return json.RawMessage("{}"), nil
+	}
+
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal(content, &object); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) {
+			return nil, fmt.Errorf("arguments must be a JSON object, not a JSON %s", typeErr.Value)
+		}
+		return nil, fmt.Errorf("arguments must be a JSON object: %v", err)
+	}
+	return json.RawMessage(content), nil
+}
+
+// withToolNames adds the available tools to err when the server has no tool by that name
+func withToolNames(ctx context.Context, client *mcp.Client, name string, err error) error {
+	tools, listErr := client.ListTools(ctx)
+	if listErr != nil {
+		return err
+	}
+	var names []string
+	for _, tool := range tools {
+		if tool.Name == name {
+			return err
+		}
+		names = append(names, tool.Name)
+	}
+	return fmt.Errorf("%w; available tools: %s", err, strings.Join(names, ", "))
+}
+
+// printToolResult prints the content blocks of a tool result as text
+func printToolResult(result *mcp.CallToolResult) {
+	if result.IsError {
+		fmt.Println("❌ The tool reported an error:")
+	}
+	for _, content := range result.Content {
+		printContent(content)
+	}
+	if len(result.StructuredContent) > 0 {
+		fmt.Println("📦 Structured content:")
+		printJSON(result.StructuredContent)
+	}
+}
+
+// printContent prints a content block: text as is, binary data as a summary
+func printContent(content mcp.Content) {
+	switch content.Type {
+	case "text":
+		fmt.Println(content.Text)
+	case "image", "audio":
+		fmt.Printf("🖼️  [%s %s, %d bytes base64]\n", content.Type, content.MimeType, len(content.Data))
+	case "resource_link":
+		fmt.Printf("🔗 %s", content.URI)
+		if content.Name != "" {
+			fmt.Printf(" (%s)", content.Name)
+		}
+		fmt.Println()
+	case "resource":
+		if content.Resource != nil {
+			printResourceContents(*content.Resource)
+		}
+	default:
+		fmt.Printf("❓ [%s content]\n", content.Type)
+	}
+}
+
+// printResourceContents prints a resource's text, or a summary of its blob
+func printResourceContents(resource mcp.ResourceContents) {
+	fmt.Printf("📄 %s\n", resource.URI)
+	if resource.Blob != "" {
+		mimeType := resource.MimeType
+		if mimeType == "" {
+			mimeType = "binary"
+		}
+		fmt.Printf("   [%s, %d bytes base64]\n", mimeType, len(resource.Blob))
+		return
+	}
+	fmt.Println(resource.Text)
+}
+
+// printJSON prints a JSON value indented
+func printJSON(value json.RawMessage) {
+	var indented bytes.Buffer
+	if err := json.Indent(&indented, value, "", "  "); err != nil {
+		fmt.Println(string(value))
+		return
+	}
+	fmt.Println(indented.String())
+}
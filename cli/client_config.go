@@ -0,0 +1,97 @@
+package cli
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"mcphub/models"
+	"mcphub/services"
+
+	"github.com/spf13/cobra"
+)
+
+var clientConfigCmd = &cobra.Command{
+	Use:   "client-config [image_name...]",
+	Short: "Generate MCP host configuration for local server images",
+	Long: `Print the server entries an MCP host needs for local images (every MCPHub image when
+none are named), or merge them into the host's configuration file with --write.
+
+Stdio servers are started by the host with "docker run -i"; HTTP and SSE servers are
+reached where their running container publishes its port, or at their port on localhost,
+so start them with mcphub run. Required and secret environment variables become
+placeholders to fill in (inputs VS Code prompts for).
+
+Targets: claude-desktop, vscode (.vscode/mcp.json in the current directory), cursor
+and windsurf.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		config, err := services.NewClientConfig(targetFlag)
+		if err != nil {
+			return err
+		}
+		rt, err := containerRuntime()
+		if err != nil {
+			return err
+		}
+
+		images := args
+		if len(images) == 0 {
+			if images, err = rt.ImagesWithLabel(services.LabelTransport); err != nil {
+				return err
+			}
+			if len(images) == 0 {
+				return fmt.Errorf("no MCPHub images found; pull or push one first")
+			}
+		}
+		for _, image := range images {
+			labels, err := rt.ImageLabels(image)
+			if err != nil {
+				return err
+			}
+			metadata := services.ParseImageMetadata(labels)
+			address := ""
+			if metadata.Transport != models.TransportStdio && metadata.Port > 0 {
+				address = rt.ServerAddress(image, metadata.Port)
+			}
+			if err := config.Add(services.ClientServerName(image, metadata), rt.Binary(), image, metadata, address); err != nil {
+				return err
+			}
+		}
+
+		if !writeFlag {
+			content, err := config.JSON()
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(content))
+			return nil
+		}
+
+		path := fileFlag
+		if path == "" {
+			if path, err = services.ClientConfigPath(targetFlag); err != nil {
+				return err
+			}
+		}
+		backup, replaced, err := config.Merge(path)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("✅ Wrote %d servers to %s\n", len(config.Servers), path)
+		if backup != "" {
+			fmt.Printf("💾 Backup: %s\n", backup)
+		}
+		if len(replaced) > 0 {
+			fmt.Printf("♻️  Replaced: %s\n", strings.Join(replaced, ", "))
+		}
+		names := make([]string, 0, len(config.Placeholders))
+		for name := range config.Placeholders {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Printf("🔑 Fill in %s for %s\n", strings.Join(config.Placeholders[name], ", "), name)
+		}
+		return nil
+	},
+}
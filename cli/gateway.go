@@ -0,0 +1,105 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"mcphub/mcp"
+	"mcphub/services"
+
+	"github.com/spf13/cobra"
+)
+
+var gatewayCmd = &cobra.Command{
+	Use:   "gateway",
+	Short: "Serve the running MCP servers behind one endpoint",
+	Long: `Aggregate the servers started with mcphub run, containers and bridges, behind a
+single MCP endpoint. Their tools and prompts are offered as <server>.<name>, where
+<server> is the container or bridge name, and their resources under their own URIs. Servers that start or stop are picked up
+while the gateway runs, and clients are told the lists changed.
+
+The gateway is served over streamable HTTP at /mcp and SSE at /sse, or over standard
+input and output with --stdio for hosts that launch their servers.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		rt, err := containerRuntime()
+		if err != nil {
+			return err
+		}
+
+		// In stdio mode standard output carries the protocol, so logs go to stderr
+		var logs io.Writer = os.Stdout
+		if stdioFlag {
+			logs = os.Stderr
+		}
+		logf := func(format string, args ...any) {
+			fmt.Fprintf(logs, "%s %s\n", time.Now().Format(time.RFC3339), fmt.Sprintf(format, args...))
+		}
+
+		gateway := mcp.NewGateway()
+		gateway.Logf = logf
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		synced := make(chan struct{})
+		go func() {
+			services.SyncGateway(ctx, rt, gateway, serverFlags, intervalFlag, logf)
+			close(synced)
+		}()
+		// On return, stop syncing and wait for the server sessions to close
+		defer func() {
+			stop()
+			<-synced
+		}()
+
+		if stdioFlag {
+			served := make(chan error, 1)
+			go func() { served <- mcp.ServeStdio(gateway.Connect(), os.Stdin, os.Stdout) }()
+			select {
+			case err = <-served:
+				return err
+			case <-ctx.Done():
+				return nil
+			}
+		}
+
+		bridge := mcp.NewBridge(func(ctx context.Context) (mcp.Transport, error) {
+			return gateway.Connect(), nil
+		})
+		bridge.MaxSessions = maxSessionsFlag
+		bridge.IdleTimeout = idleTimeoutFlag
+		bridge.AllowedOrigins = originFlags
+		listener, err := net.Listen("tcp", listenFlag)
+		if err != nil {
+			return fmt.Errorf("failed to listen on %s: %v", listenFlag, err)
+		}
+		server := &http.Server{Handler: bridge}
+		served := make(chan error, 1)
+		go func() { served <- server.Serve(listener) }()
+
+		base := bridgeURL(listener.Addr().String())
+		fmt.Println("🚪 Gateway started")
+		fmt.Printf("🌐 Streamable HTTP: %s%s\n", base, mcp.BridgePath)
+		fmt.Printf("🌐 SSE: %s%s\n", base, mcp.BridgeSSEPath)
+
+		select {
+		case err = <-served:
+		case <-ctx.Done():
+			fmt.Println("🛑 Stopping the gateway...")
+		}
+		bridge.Close()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+		if err != nil && err != http.ErrServerClosed {
+			return err
+		}
+		return nil
+	},
+}
@@ -0,0 +1,139 @@
+package cli
+
+import (
+	"fmt"
+	"strings"
+
+	"mcphub/services"
+
+	"github.com/spf13/cobra"
+)
+
+var infoCmd = &cobra.Command{
+	Use:   "info <image_name>",
+	Short: "Show what an MCP server image contains and how to run it",
+	Long: `Read the labels of a local image (built by mcphub push, pulled, or loaded from elsewhere)
+and print the server's metadata, transport, port and environment variables.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		rt, err := containerRuntime()
+		if err != nil {
+			return err
+		}
+
+		labels, err := rt.ImageLabels(args[0])
+		if err != nil {
+			return err
+		}
+		metadata := services.ParseImageMetadata(labels)
+		if metadata.Transport == "" {
+			fmt.Printf("⚠️  %s was not built by MCPHub; only its OCI labels are shown\n", args[0])
+		}
+
+		title := metadata.Title
+		if title == "" {
+			title = args[0]
+		}
+		fmt.Printf("📦 %s %s\n", title, metadata.Version)
+		printField("📝", "", metadata.Description)
+		printField("👤", "Authors", metadata.Authors)
+		printField("📄", "License", metadata.Licenses)
+		printField("🔗", "Source", metadata.Source)
+		printField("🔖", "Revision", metadata.Revision)
+		printField("🕒", "Created", metadata.Created)
+
+		switch {
+		case metadata.Transport == "":
+		case metadata.Port > 0:
+			fmt.Printf("🔌 Transport: %s on port %d (%s)\n", metadata.Transport, metadata.Port, metadata.Path)
+		default:
+			fmt.Printf("🔌 Transport: %s\n", metadata.Transport)
+		}
+
+		if len(metadata.Env) > 0 {
+			fmt.Println("🔑 Environment:")
+			for _, env := range metadata.Env {
+				var traits []string
+				if env.Required {
+					traits = append(traits, "required")
+				}
+				if env.Secret {
+					traits = append(traits, "secret")
+				}
+				if env.Default != "" {
+					traits = append(traits, "default "+env.Default)
+				}
+				line := "   " + env.Name
+				if len(traits) > 0 {
+					line += " (" + strings.Join(traits, ", ") + ")"
+				}
+				if env.Description != "" {
+					line += " - " + env.Description
+				}
+				fmt.Println(line)
+			}
+		}
+
+		if introspection := metadata.Introspection; introspection != nil {
+			fmt.Printf("🤝 Server: %s %s (protocol %s)\n", introspection.ServerInfo.Name, introspection.ServerInfo.Version, introspection.ProtocolVersion)
+			if len(introspection.Tools) > 0 {
+				fmt.Printf("🧰 Tools (%d):\n", len(introspection.Tools))
+				for _, tool := range introspection.Tools {
+					printItem(tool.Name, tool.Description)
+				}
+			}
+			if len(introspection.Resources)+len(introspection.ResourceTemplates) > 0 {
+				fmt.Printf("📚 Resources (%d):\n", len(introspection.Resources)+len(introspection.ResourceTemplates))
+				for _, resource := range introspection.Resources {
+					printItem(resource.URI, resource.Description)
+				}
+				for _, template := range introspection.ResourceTemplates {
+					printItem(template.URITemplate, template.Description)
+				}
+			}
+			if len(introspection.Prompts) > 0 {
+				fmt.Printf("💬 Prompts (%d):\n", len(introspection.Prompts))
+				for _, prompt := range introspection.Prompts {
+					printItem(prompt.Name, prompt.Description)
+				}
+			}
+		} else if metadata.Transport != "" {
+			fmt.Println("🔍 No introspection recorded (the server could not be started when it was built)")
+		}
+
+		printField("🧾", "Config digest", metadata.ConfigDigest)
+		printField("🧬", "Source hash", metadata.SourceHash)
+
+		if metadata.Transport != "" {
+			fmt.Printf("💡 Run it with: mcphub run %s", args[0])
+			for _, env := range metadata.Env {
+				if env.Required {
+					fmt.Printf(" -e %s=...", env.Name)
+				}
+			}
+			fmt.Println()
+		}
+		return nil
+	},
+}
+
+func printField(icon, name, value string) {
+	if value == "" {
+		return
+	}
+	if name == "" {
+		fmt.Printf("%s %s\n", icon, value)
+		return
+	}
+	fmt.Printf("%s %s: %s\n", icon, name, value)
+}
+
+// printItem prints a list entry with the first line of its description
+func printItem(name, description string) {
+	description, _, _ = strings.Cut(strings.TrimSpace(description), "\n")
+	if description == "" {
+		fmt.Printf("   %s\n", name)
+		return
+	}
+	fmt.Printf("   %s - %s\n", name, description)
+}
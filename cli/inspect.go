@@ -0,0 +1,465 @@
+package cli
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+
+	"mcphub/mcp"
+	"mcphub/services"
+
+	"github.com/spf13/cobra"
+)
+
+var inspectCmd = &cobra.Command{
+	Use:   "inspect <server>",
+	Short: "Explore an MCP server interactively",
+	Long: `Open a prompt to list and call a server's tools, read its resources, fill in its
+prompts and watch its notifications and logs. <server> is a container started with
+mcphub run, or a local image, which is started in a throwaway container until you quit.
+
+Type help at the prompt for the commands. Ctrl-C cancels the running request;
+quit or Ctrl-D leaves.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		rt, err := containerRuntime()
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("🔌 Connecting to %s...\n", args[0])
+		ctx, cancel := context.WithTimeout(context.Background(), inspectTimeout)
+		session, err := services.ConnectServer(ctx, rt, args[0], envFlags)
+		cancel()
+		if err != nil {
+			return fmt.Errorf("failed to connect to %s: %v", args[0], err)
+		}
+		defer session.Close()
+
+		info := session.Info
+		fmt.Printf("🤝 %s %s (protocol %s)\n", info.ServerInfo.Name, info.ServerInfo.Version, info.ProtocolVersion)
+		if info.Instructions != "" {
+			fmt.Printf("📝 %s\n", info.Instructions)
+		}
+		fmt.Println("💡 Type help for the commands")
+
+		in := &inspector{session: session, reader: bufio.NewReader(os.Stdin)}
+		session.Client.OnNotification(in.notification)
+		in.run()
+		return nil
+	},
+}
+
+// inspector is the prompt of mcphub inspect
+type inspector struct {
+	session *services.Session
+	reader  *bufio.Reader
+
+	mu     sync.Mutex
+	cancel context.CancelFunc // of the running command, nil at the prompt
+}
+
+const inspectorPrompt = "mcp> "
+
+const inspectorHelp = `  tools                 List the tools
+  resources             List the resources and resource templates
+  prompts               List the prompts
+  schema <tool>         Show the input and output schemas of a tool
+  call <tool> [json]    Call a tool; without JSON its arguments are asked for
+  read <uri>            Read a resource
+  subscribe <uri>       Be notified when a resource changes
+  unsubscribe <uri>     Stop being notified about a resource
+  prompt <name>         Fill in a prompt, asking for its arguments
+  loglevel <level>      Set the level of the log messages the server sends
+  logs                  Show what the server printed
+  ping                  Check that the server answers
+  quit                  Leave (or Ctrl-D)`
+
+func (in *inspector) run() {
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, os.Interrupt)
+	defer signal.Stop(interrupts)
+	go func() {
+		for range interrupts {
+			in.mu.Lock()
+			if in.cancel != nil {
+				in.cancel()
+			} else {
+				fmt.Print("\n(quit or Ctrl-D to leave)\n" + inspectorPrompt)
+			}
+			in.mu.Unlock()
+		}
+	}()
+
+	for {
+		fmt.Print(inspectorPrompt)
+		line, ok := in.readLine()
+		if !ok {
+			fmt.Println()
+			return
+		}
+		command, argument, _ := strings.Cut(line, " ")
+		argument = strings.TrimSpace(argument)
+		if command == "" {
+			continue
+		}
+		if command == "quit" || command == "exit" {
+			return
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		in.mu.Lock()
+		in.cancel = cancel
+		in.mu.Unlock()
+		err := in.execute(ctx, command, argument)
+		in.mu.Lock()
+		in.cancel = nil
+		in.mu.Unlock()
+		if ctx.Err() != nil {
+			err = fmt.Errorf("cancelled")
+		}
+		cancel()
+		if err != nil {
+			fmt.Printf("❌ %v\n", err)
+		}
+	}
+}
+
+func (in *inspector) execute(ctx context.Context, command, argument string) error {
+	client := in.session.Client
+	needs := func(what string) error {
+		if argument == "" {
+			return fmt.Errorf("usage: %s %s", command, what)
+		}
+		return nil
+	}
+
+	switch command {
+	case "help", "?":
+		fmt.Println(inspectorHelp)
+	case "tools":
+		tools, err := client.ListTools(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("🧰 Tools (%d):\n", len(tools))
+		for _, tool := range tools {
+			printItem(tool.Name, tool.Description)
+		}
+	case "resources":
+		resources, err := client.ListResources(ctx)
+		if err != nil {
+			return err
+		}
+		// Templates are optional, so servers without them may not know the method
+		templates, err := client.ListResourceTemplates(ctx)
+		var rpcErr *mcp.Error
+		if err != nil && !(errors.As(err, &rpcErr) && rpcErr.Code == mcp.CodeMethodNotFound) {
+			return err
+		}
+		fmt.Printf("📚 Resources (%d):\n", len(resources)+len(templates))
+		for _, resource := range resources {
+			printItem(resource.URI, resource.Description)
+		}
+		for _, template := range templates {
+			printItem(template.URITemplate, template.Description)
+		}
+	case "prompts":
+		prompts, err := client.ListPrompts(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("💬 Prompts (%d):\n", len(prompts))
+		for _, prompt := range prompts {
+			printItem(prompt.Name, prompt.Description)
+		}
+	case "schema":
+		if err := needs("<tool>"); err != nil {
+			return err
+		}
+		tool, err := in.tool(ctx, argument)
+		if err != nil {
+			return err
+		}
+		if tool.Description != "" {
+			fmt.Printf("📝 %s\n", tool.Description)
+		}
+		fmt.Println("📥 Input schema:")
+		printJSON(tool.InputSchema)
+		if len(tool.OutputSchema) > 0 {
+			fmt.Println("📤 Output schema:")
+			printJSON(tool.OutputSchema)
+		}
+	case "call":
+		if err := needs("<tool> [json]"); err != nil {
+			return err
+		}
+		name, arguments, _ := strings.Cut(argument, " ")
+		raw := json.RawMessage(strings.TrimSpace(arguments))
+		if len(raw) == 0 {
+			tool, err := in.tool(ctx, name)
+			if err != nil {
+				return err
+			}
+			if raw, err = in.askToolArguments(tool); err != nil {
+				return err
+			}
+		} else if !json.Valid(raw) {
+			return fmt.Errorf("arguments must be a JSON object")
+		}
+		result, err := client.CallTool(ctx, name, raw)
+		if err != nil {
+			return err
+		}
+		printToolResult(result)
+	case "read":
+		if err := needs("<uri>"); err != nil {
+			return err
+		}
+		contents, err := client.ReadResource(ctx, argument)
+		if err != nil {
+			return err
+		}
+		for _, content := range contents {
+			printResourceContents(content)
+		}
+	case "subscribe":
+		if err := needs("<uri>"); err != nil {
+			return err
+		}
+		if err := client.Subscribe(ctx, argument); err != nil {
+			return err
+		}
+		fmt.Printf("🔔 Subscribed to %s\n", argument)
+	case "unsubscribe":
+		if err := needs("<uri>"); err != nil {
+			return err
+		}
+		if err := client.Unsubscribe(ctx, argument); err != nil {
+			return err
+		}
+		fmt.Printf("🔕 Unsubscribed from %s\n", argument)
+	case "prompt":
+		if err := needs("<name>"); err != nil {
+			return err
+		}
+		return in.getPrompt(ctx, argument)
+	case "loglevel":
+		if err := needs("<debug|info|notice|warning|error|critical|alert|emergency>"); err != nil {
+			return err
+		}
+		if err := client.SetLoggingLevel(ctx, argument); err != nil {
+			return err
+		}
+		fmt.Printf("📣 Logging level set to %s\n", argument)
+	case "logs":
+		logs, err := in.session.Logs()
+		if err != nil {
+			return err
+		}
+		if strings.TrimSpace(logs) == "" {
+			fmt.Println("📜 The server printed nothing")
+			return nil
+		}
+		fmt.Print(logs)
+	case "ping":
+		if err := client.Ping(ctx); err != nil {
+			return err
+		}
+		fmt.Println("🏓 pong")
+	default:
+		return fmt.Errorf("unknown command %q; type help for the commands", command)
+	}
+	return nil
+}
+
+// tool finds a tool by name
+func (in *inspector) tool(ctx context.Context, name string) (*mcp.Tool, error) {
+	tools, err := in.session.Client.ListTools(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for i := range tools {
+		if tools[i].Name == name {
+			return &tools[i], nil
+		}
+	}
+	return nil, fmt.Errorf("no tool named %s", name)
+}
+
+// askToolArguments asks for every property of the tool's input schema. Optional ones may
+// be left empty.
+func (in *inspector) askToolArguments(tool *mcp.Tool) (json.RawMessage, error) {
+	schema, err := mcp.ParseSchema(tool.InputSchema)
+	if err != nil {
+		return nil, err
+	}
+	arguments := map[string]any{}
+	for _, name := range schema.PropertyNames() {
+		property := schema.Properties[name]
+		required := schema.IsRequired(name)
+
+		var traits []string
+		if kind := property.Kind(); kind != "" {
+			traits = append(traits, kind)
+		}
+		if required {
+			traits = append(traits, "required")
+		}
+		if len(property.Enum) > 0 {
+			traits = append(traits, "one of "+property.EnumValues())
+		}
+		if len(property.Default) > 0 {
+			traits = append(traits, "default "+string(property.Default))
+		}
+		if property.Description != "" {
+			fmt.Printf("   # %s\n", strings.ReplaceAll(strings.TrimSpace(property.Description), "\n", "\n   # "))
+		}
+
+		for {
+			fmt.Printf("   %s (%s): ", name, strings.Join(traits, ", "))
+			input, ok := in.readLine()
+			if !ok {
+				return nil, fmt.Errorf("cancelled")
+			}
+			if input == "" {
+				if required {
+					fmt.Println("   ⚠️  A value is required")
+					continue
+				}
+				break
+			}
+			value, err := property.ParseValue(input)
+			if err != nil {
+				fmt.Printf("   ⚠️  %v\n", err)
+				continue
+			}
+			arguments[name] = value
+			break
+		}
+	}
+	return json.Marshal(arguments)
+}
+
+// getPrompt asks for the arguments of a prompt and prints its messages
+func (in *inspector) getPrompt(ctx context.Context, name string) error {
+	prompts, err := in.session.Client.ListPrompts(ctx)
+	if err != nil {
+		return err
+	}
+	var prompt *mcp.Prompt
+	for i := range prompts {
+		if prompts[i].Name == name {
+			prompt = &prompts[i]
+		}
+	}
+	if prompt == nil {
+		return fmt.Errorf("no prompt named %s", name)
+	}
+
+	arguments := map[string]string{}
+	for _, argument := range prompt.Arguments {
+		if argument.Description != "" {
+			fmt.Printf("   # %s\n", argument.Description)
+		}
+		for {
+			if argument.Required {
+				fmt.Printf("   %s (required): ", argument.Name)
+			} else {
+				fmt.Printf("   %s: ", argument.Name)
+			}
+			input, ok := in.readLine()
+			if !ok {
+				return fmt.Errorf("cancelled")
+			}
+			if input == "" && argument.Required {
+				fmt.Println("   ⚠️  A value is required")
+				continue
+			}
+			if input != "" {
+				arguments[argument.Name] = input
+			}
+			break
+		}
+	}
+
+	result, err := in.session.Client.GetPrompt(ctx, name, arguments)
+	if err != nil {
+		return err
+	}
+	if result.Description != "" {
+		fmt.Printf("📝 %s\n", result.Description)
+	}
+	for _, message := range result.Messages {
+		fmt.Printf("👤 %s:\n", message.Role)
+		printContent(message.Content)
+	}
+	return nil
+}
+
+// notification prints a server notification as it arrives
+func (in *inspector) notification(method string, params json.RawMessage) {
+	var text string
+	switch method {
+	case mcp.NotificationMessage:
+		var message mcp.LoggingMessage
+		json.Unmarshal(params, &message)
+		var data string
+		if json.Unmarshal(message.Data, &data) != nil {
+			data = string(message.Data)
+		}
+		if message.Logger != "" {
+			data = message.Logger + ": " + data
+		}
+		text = fmt.Sprintf("📣 [%s] %s", message.Level, data)
+	case mcp.NotificationProgress:
+		var progress mcp.Progress
+		json.Unmarshal(params, &progress)
+		text = fmt.Sprintf("⏳ %g", progress.Progress)
+		if progress.Total > 0 {
+			text += fmt.Sprintf("/%g", progress.Total)
+		}
+		if progress.Message != "" {
+			text += " " + progress.Message
+		}
+	case mcp.NotificationToolsListChanged:
+		text = "🔄 The tools changed"
+	case mcp.NotificationResourcesListChanged:
+		text = "🔄 The resources changed"
+	case mcp.NotificationPromptsListChanged:
+		text = "🔄 The prompts changed"
+	case mcp.NotificationResourceUpdated:
+		var updated struct {
+			URI string `json:"uri"`
+		}
+		json.Unmarshal(params, &updated)
+		text = "🔄 Updated: " + updated.URI
+	default:
+		text = fmt.Sprintf("🔔 %s %s", method, params)
+	}
+
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	if in.cancel == nil {
+		// Waiting at the prompt: print on a line of its own and show the prompt again
+		fmt.Print("\n" + text + "\n" + inspectorPrompt)
+		return
+	}
+	fmt.Println(text)
+}
+
+// readLine reads a line of input; ok is false at the end of the input
+func (in *inspector) readLine() (line string, ok bool) {
+	text, err := in.reader.ReadString('\n')
+	if err == io.EOF && text == "" {
+		return "", false
+	}
+	return strings.TrimSpace(text), true
+}
@@ -0,0 +1,69 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"mcphub/models"
+	"mcphub/services"
+
+	"github.com/spf13/cobra"
+)
+
+var lockCmd = &cobra.Command{
+	Use:   "lock [project-dir]",
+	Short: "Pin the generated Dockerfile's base images by digest",
+	Long: `Resolve the base images the generated Dockerfile would use to their current registry
+digests and record them in mcp.lock.json next to mcp.json. Later pushes build FROM the
+pinned digests until the lock is refreshed by running this command again.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dir := "."
+		if len(args) == 1 {
+			dir = args[0]
+		}
+
+		content, err := os.ReadFile(filepath.Join(dir, "mcp.json"))
+		if err != nil {
+			return fmt.Errorf("failed to read mcp.json: %v", err)
+		}
+		var config models.MCPConfig
+		if err := json.Unmarshal(content, &config); err != nil {
+			return fmt.Errorf("failed to parse mcp.json: %v", err)
+		}
+
+		rt, err := containerRuntime()
+		if err != nil {
+			return err
+		}
+
+		project, err := services.DetectProject(dir, &config)
+		if err != nil {
+			return err
+		}
+		builderImage, runtimeImage := services.NewDockerfileGenerator().BaseImages(&config, project)
+
+		lock := &models.LockFile{Images: map[string]string{}}
+		for _, image := range []string{builderImage, runtimeImage} {
+			if _, done := lock.Images[image]; done {
+				continue
+			}
+			fmt.Printf("📌 Resolving %s...\n", image)
+			digest, err := rt.ImageDigest(image)
+			if err != nil {
+				return err
+			}
+			lock.Images[image] = digest
+			fmt.Printf("   %s\n", digest)
+		}
+
+		if err := services.WriteLockFile(dir, lock); err != nil {
+			return fmt.Errorf("failed to write %s: %v", services.LockFileName, err)
+		}
+
+		fmt.Printf("✅ %s written\n", filepath.Join(dir, services.LockFileName))
+		return nil
+	},
+}
@@ -1,11 +1,14 @@
 package cli
 
 import (
+	"context"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
 
+	"mcphub/models"
 	"mcphub/services"
 
 	"github.com/spf13/cobra"
@@ -18,8 +21,11 @@ var pushCmd = &cobra.Command{
 1. Extracting the zip file
 2. Finding and parsing mcp.json configuration
 3. Generating a Dockerfile
-4. Building a Docker image
-5. Saving the image as a tar file and uploading to S3`,
+4. Building a Docker image (output is streamed live and saved under logs/)
+5. Publishing the image to S3 (as a tar file) or to an OCI registry (--registry)
+
+With --require-conformance the image is only published when it passes the conformance
+suite of mcphub test.`,
 	Args: cobra.ExactArgs(1),
 	RunE: runPush,
 }
@@ -48,22 +54,78 @@ func runPush(cmd *cobra.Command, args []string) error {
 
 	fmt.Printf("📦 Processing %s...\n", zipFileName)
 
+	rt, err := containerRuntime()
+	if err != nil {
+		return err
+	}
+
+	// Resolve the registry backend before building so configuration errors surface early
+	registry, err := services.NewRegistry(registryFlag, rt, os.Stdout)
+	if err != nil {
+		return err
+	}
+
+	templatesDir, err := services.ResolveTemplatesDir(templatesFlag)
+	if err != nil {
+		return err
+	}
+	if templatesDir != "" {
+		fmt.Printf("🧩 Using Dockerfile templates from %s\n", templatesDir)
+	}
+
 	// Process the zip file using the existing service
-	processor := services.NewZipProcessor()
-	result, err := processor.ProcessZip(zipData, zipFileName)
+	processor := services.NewZipProcessor(rt, services.ProcessOptions{
+		Platforms:         services.ParsePlatforms(platformFlag),
+		SkipArchive:       !registry.NeedsArchives(),
+		Force:             forceFlag,
+		Build:             services.BuildOptions{Output: buildOutput()},
+		Progress:          os.Stdout,
+		TemplatesDir:      templatesDir,
+		SkipIntrospection: noIntrospect,
+	})
+	prepared, err := processor.PrepareZip(zipData, zipFileName)
 	if err != nil {
 		return fmt.Errorf("failed to process zip file: %v", err)
 	}
 
-	// Initialize S3 service
-	s3Service, err := services.NewS3Service()
+	platforms, err := processor.TargetPlatforms(prepared)
+	if err != nil {
+		return err
+	}
+	// Nothing to do when this exact source was already published for the same platforms
+	if !forceFlag && registry.Published(prepared.Config, prepared.SourceHash, platforms) {
+		fmt.Printf("✅ %s v%s is already published from identical sources, skipping build and upload\n", prepared.Config.Name, prepared.Config.Version)
+		fmt.Printf("🔑 Source hash: %s\n", prepared.SourceHash)
+		return nil
+	}
+
+	result, err := processor.BuildPrepared(prepared)
 	if err != nil {
-		return fmt.Errorf("failed to initialize S3 service: %v", err)
+		return fmt.Errorf("failed to process zip file: %v", err)
+	}
+	defer services.RemoveArchives(result)
+
+	// Refuse to publish a server that does not follow the protocol
+	if conformanceFlag {
+		// The image built for this machine is the one that can be started
+		host := hostImage(result.Artifacts)
+		if host == "" {
+			return fmt.Errorf("--require-conformance needs an image for this machine's platform (%s)", services.HostPlatform())
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), pushTimeout)
+		defer cancel()
+		checks, err := checkConformance(ctx, rt, host)
+		if err != nil {
+			return err
+		}
+		if failed := failedConformanceChecks(checks); failed > 0 {
+			return fmt.Errorf("not publishing %s: it failed %d of %d conformance checks", result.Config.Name, failed, len(checks))
+		}
 	}
 
-	// Upload to S3
-	if err := s3Service.PushMCP(result.Config.Author, result.Config.Name, result.TarFilePath); err != nil {
-		return fmt.Errorf("failed to upload to S3: %v", err)
+	// Publish the image(s)
+	if err := registry.Push(result); err != nil {
+		return err
 	}
 
 	// Display results
@@ -71,7 +133,11 @@ func runPush(cmd *cobra.Command, args []string) error {
 	fmt.Printf("📁 Extracted to: %s\n", result.ExtractedPath)
 	fmt.Printf("🐳 Dockerfile: %s\n", result.DockerfilePath)
 	fmt.Printf("🏷️  Image name: %s\n", result.ImageName)
-	fmt.Printf("📦 Docker image uploaded to S3: %s/%s.tar\n", result.Config.Author, result.Config.Name)
+	if result.BuildLogPath != "" {
+		fmt.Printf("📝 Build log: %s\n", result.BuildLogPath)
+	}
+	fmt.Printf("🔑 Source hash: %s\n", result.SourceHash)
+	fmt.Printf("📦 Image published: %s/%s\n", result.Config.Author, result.Config.Name)
 	fmt.Printf("📋 MCP Server: %s v%s\n", result.Config.Name, result.Config.Version)
 
 	if result.Config.Description != "" {
@@ -92,3 +158,21 @@ func runPush(cmd *cobra.Command, args []string) error {
 
 	return nil
 }
+
+// hostImage returns the image of the artifact that runs on this machine, or "" when none does
+func hostImage(artifacts []models.ImageArtifact) string {
+	for _, artifact := range artifacts {
+		if artifact.Platform == "" || artifact.Platform == services.HostPlatform() {
+			return artifact.Image
+		}
+	}
+	return ""
+}
+
+// buildOutput is where live build output goes: stdout, or nowhere with --quiet
+func buildOutput() io.Writer {
+	if quietFlag {
+		return nil
+	}
+	return os.Stdout
+}
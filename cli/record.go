@@ -0,0 +1,195 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"mcphub/mcp"
+	"mcphub/services"
+
+	"github.com/spf13/cobra"
+)
+
+var recordCmd = &cobra.Command{
+	Use:   "record <server>",
+	Short: "Record the MCP traffic between a client and a server",
+	Long: `Proxy client sessions to a server and record every JSON-RPC message to a file, to
+replay later against a new build with mcphub replay. <server> is a container started
+with mcphub run, or a local image, started in a throwaway container per session.
+
+The proxy is served over streamable HTTP at /mcp and SSE at /sse, or over standard
+input and output with --stdio for hosts that launch their servers. Recordings hold
+everything the client sent, so keep them private when the session used secrets.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		rt, err := containerRuntime()
+		if err != nil {
+			return err
+		}
+		file, err := os.OpenFile(outputFlag, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+		if err != nil {
+			return fmt.Errorf("failed to create %s: %v", outputFlag, err)
+		}
+		defer file.Close()
+
+		recorder := mcp.NewRecorder(file)
+		start := func(ctx context.Context) (mcp.Transport, error) {
+			transport, err := services.OpenTransport(ctx, rt, args[0], envFlags, os.Stderr)
+			if err != nil {
+				return nil, err
+			}
+			return recorder.Record(transport), nil
+		}
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		if stdioFlag {
+			// Standard output carries the protocol, so messages go to stderr
+			transport, err := start(ctx)
+			if err != nil {
+				return fmt.Errorf("failed to connect to %s: %v", args[0], err)
+			}
+			fmt.Fprintf(os.Stderr, "📼 Recording %s to %s\n", args[0], outputFlag)
+			served := make(chan error, 1)
+			go func() { served <- mcp.ServeStdio(transport, os.Stdin, os.Stdout) }()
+			select {
+			case err = <-served:
+			case <-ctx.Done():
+				transport.Close()
+			}
+			if err != nil {
+				return err
+			}
+			return recorder.Err()
+		}
+
+		bridge := mcp.NewBridge(start)
+		bridge.MaxSessions = maxSessionsFlag
+		bridge.IdleTimeout = idleTimeoutFlag
+		bridge.AllowedOrigins = originFlags
+		bridge.Logf = func(format string, args ...any) {
+			fmt.Printf("%s %s\n", time.Now().Format(time.RFC3339), fmt.Sprintf(format, args...))
+		}
+		listener, err := net.Listen("tcp", listenFlag)
+		if err != nil {
+			return fmt.Errorf("failed to listen on %s: %v", listenFlag, err)
+		}
+		server := &http.Server{Handler: bridge}
+		served := make(chan error, 1)
+		go func() { served <- server.Serve(listener) }()
+
+		base := bridgeURL(listener.Addr().String())
+		fmt.Printf("📼 Recording %s to %s\n", args[0], outputFlag)
+		fmt.Printf("🌐 Streamable HTTP: %s%s\n", base, mcp.BridgePath)
+		fmt.Printf("🌐 SSE: %s%s\n", base, mcp.BridgeSSEPath)
+
+		select {
+		case err = <-served:
+		case <-ctx.Done():
+			fmt.Println("🛑 Stopping the recording...")
+		}
+		bridge.Close()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+		if err != nil && err != http.ErrServerClosed {
+			return err
+		}
+		if err := recorder.Err(); err != nil {
+			return err
+		}
+		fmt.Printf("✅ Recorded %d sessions to %s\n", recorder.Sessions(), outputFlag)
+		return nil
+	},
+}
+
+var replayCmd = &cobra.Command{
+	Use:   "replay <file>",
+	Short: "Replay a recorded session against a server and diff the responses",
+	Long: `Send the client messages of a recording made with mcphub record to a fresh container
+of the image given with --against, one container per recorded session, and compare
+each response with the recorded one. Requests the server makes of the client are
+answered as they were in the recording.
+
+Timestamps and UUIDs are not compared unless --strict is given. --ignore leaves out
+more values: a JSON path into the response such as $.result.serverInfo.version or
+$.result.content[*].text (* matches any key or index), or a key name, ignored at any
+depth. The command fails when any response differs.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		file, err := os.Open(args[0])
+		if err != nil {
+			return fmt.Errorf("failed to open %s: %v", args[0], err)
+		}
+		sessions, err := mcp.ReadRecording(file)
+		file.Close()
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %v", args[0], err)
+		}
+		if len(sessions) == 0 {
+			return fmt.Errorf("%s has no recorded sessions", args[0])
+		}
+		rt, err := containerRuntime()
+		if err != nil {
+			return err
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		options := services.ReplayOptions{Ignore: ignoreFlags, Strict: strictFlag}
+		compared, different := 0, 0
+		for i, session := range sessions {
+			fmt.Printf("🔁 Replaying session %d of %d against %s...\n", i+1, len(sessions), againstFlag)
+			replayed, err := services.ReplaySession(ctx, rt, againstFlag, envFlags, session, replayTimeout, os.Stderr)
+			if err != nil {
+				return fmt.Errorf("failed to start %s: %v", againstFlag, err)
+			}
+			for _, request := range replayed {
+				compared++
+				name := fmt.Sprintf("%s (id %s)", describeRequest(request.Request), request.Request.ID)
+				if request.Replayed == nil {
+					different++
+					fmt.Printf("❌ %s\n   no response within %s\n", name, replayTimeout)
+					continue
+				}
+				if diff := services.CompareResponses(request.Recorded, request.Replayed, options); diff != "" {
+					different++
+					fmt.Printf("❌ %s\n   %s\n", name, strings.ReplaceAll(diff, "\n", "\n   "))
+					continue
+				}
+				fmt.Printf("✅ %s\n", name)
+			}
+		}
+
+		if different > 0 {
+			return fmt.Errorf("%d of %d responses differ from the recording", different, compared)
+		}
+		fmt.Printf("✅ All %d responses match the recording\n", compared)
+		return nil
+	},
+}
+
+// describeRequest names a request by its method and, for tools, prompts and resources, what
+// it targets
+func describeRequest(request *mcp.Message) string {
+	var params struct {
+		Name string `json:"name"`
+		URI  string `json:"uri"`
+	}
+	json.Unmarshal(request.Params, &params)
+	switch {
+	case params.Name != "":
+		return request.Method + " " + params.Name
+	case params.URI != "":
+		return request.Method + " " + params.URI
+	}
+	return request.Method
+}
@@ -3,16 +3,56 @@ package cli
 import (
 	"fmt"
 	"os"
+	"strings"
+	"time"
+
+	"mcphub/services"
 
 	"github.com/spf13/cobra"
 )
 
 // Global flag variables
 var (
-	yesFlag  bool
-	detached bool
-	portFlag string
-	nameFlag string
+	yesFlag         bool
+	detached        bool
+	portFlag        string
+	nameFlag        string
+	runtimeFlag     string
+	quietFlag       bool
+	platformFlag    string
+	registryFlag    string
+	forceFlag       bool
+	envFlags        []string
+	templatesFlag   string
+	noIntrospect    bool
+	remoteFlag      bool
+	argsFlag        string
+	argsFileFlag    string
+	jsonFlag        bool
+	pushTimeout     time.Duration
+	testTimeout     time.Duration
+	callTimeout     time.Duration
+	inspectTimeout  time.Duration
+	replayTimeout   time.Duration
+	bridgeFlag      bool
+	backgroundFlag  bool
+	listenFlag      string
+	maxSessionsFlag int
+	idleTimeoutFlag time.Duration
+	originFlags     []string
+	serverFlags     []string
+	stdioFlag       bool
+	intervalFlag    time.Duration
+	targetFlag      string
+	writeFlag       bool
+	fileFlag        string
+	conformanceFlag bool
+	testsFlag       string
+	junitFlag       string
+	outputFlag      string
+	againstFlag     string
+	ignoreFlags     []string
+	strictFlag      bool
 )
 
 var rootCmd = &cobra.Command{
@@ -22,9 +62,25 @@ var rootCmd = &cobra.Command{
 
 Commands:
   init  - Initialize a new mcp.json configuration file
-  push  - Build Docker image from MCP server zip file  
-  pull  - Load Docker image from tar file
-  run   - Run Docker container from loaded image`,
+  push  - Build Docker image from MCP server zip file and publish it
+  pull  - Download and load a published image
+  run   - Run Docker container from loaded image
+  stop  - Stop a container or bridge started with run
+  lock  - Pin base images by digest in mcp.lock.json
+  info  - Show an image's MCP metadata and how to run it
+  templates - Print the built-in Dockerfile template to customize
+  search - Find servers by their tools, resources and prompts
+  call  - Call a tool on a running or local server
+  inspect - Explore a server's tools, resources and prompts interactively
+  bridge - Serve a stdio server over streamable HTTP and SSE
+  gateway - Serve the running servers behind one endpoint
+  client-config - Configure MCP hosts such as Claude Desktop or VS Code
+  test  - Check that a server follows the MCP protocol
+  record - Record the MCP traffic between a client and a server
+  replay - Replay a recorded session against a new build and diff the responses
+
+Docker, Podman (CLI or Docker-compatible socket) and nerdctl are supported.
+Select one with --runtime or MCPHUB_RUNTIME; otherwise it is detected.`,
 }
 
 // Execute is the entry point for the CLI
@@ -35,18 +91,132 @@ func Execute() {
 	}
 }
 
+// containerRuntime resolves the container runtime selected by flag, environment or detection
+func containerRuntime() (*services.ContainerRuntime, error) {
+	rt, err := services.NewContainerRuntime(runtimeFlag)
+	if err != nil {
+		return nil, err
+	}
+	if !rt.Available() {
+		return nil, fmt.Errorf("❌ %s is not running or not installed. Please start %s and try again", rt.DisplayName(), rt.DisplayName())
+	}
+	return rt, nil
+}
+
 func init() {
+	// Global flags
+	rootCmd.PersistentFlags().StringVar(&registryFlag, "registry", "", "Image registry: s3, s3://bucket or an OCI registry such as localhost:5000 (default: $MCPHUB_REGISTRY or s3)")
+	rootCmd.PersistentFlags().StringVar(&runtimeFlag, "runtime", "", "Container runtime: docker, podman, podman-socket or nerdctl (default: $MCPHUB_RUNTIME or auto-detect)")
+
 	// Register subcommands
 	rootCmd.AddCommand(initCmd)
 	rootCmd.AddCommand(pushCmd)
 	rootCmd.AddCommand(pullCmd)
 	rootCmd.AddCommand(runCmd)
+	rootCmd.AddCommand(stopCmd)
+	rootCmd.AddCommand(lockCmd)
+	rootCmd.AddCommand(infoCmd)
+	rootCmd.AddCommand(templatesCmd)
+	rootCmd.AddCommand(searchCmd)
+	rootCmd.AddCommand(callCmd)
+	rootCmd.AddCommand(inspectCmd)
+	rootCmd.AddCommand(bridgeCmd)
+	rootCmd.AddCommand(gatewayCmd)
+	rootCmd.AddCommand(clientConfigCmd)
+	rootCmd.AddCommand(testCmd)
+	rootCmd.AddCommand(recordCmd)
+	rootCmd.AddCommand(replayCmd)
 
 	// Flags for 'init' command
 	initCmd.Flags().BoolVarP(&yesFlag, "yes", "y", false, "Use default values without prompting")
 
+	// Flags for 'push' command
+	pushCmd.Flags().BoolVarP(&quietFlag, "quiet", "q", false, "Do not stream build output (it is still written to the build log)")
+	pushCmd.Flags().BoolVar(&forceFlag, "force", false, "Rebuild and upload even when the sources are unchanged")
+	pushCmd.Flags().StringVar(&platformFlag, "platform", "", "Comma separated platforms to build, e.g. linux/amd64,linux/arm64 (overrides mcp.json)")
+	pushCmd.Flags().BoolVar(&noIntrospect, "no-introspect", false, "Do not start the built server to record its tools, resources and prompts")
+	pushCmd.Flags().StringVar(&templatesFlag, "templates", "", "Directory of custom Dockerfile templates (default: $MCPHUB_TEMPLATES or \"templates\" in ~/.mcphub/config.json)")
+	pushCmd.Flags().BoolVar(&conformanceFlag, "require-conformance", false, "Publish only when the built server passes the conformance suite of mcphub test")
+	pushCmd.Flags().StringArrayVarP(&envFlags, "env", "e", nil, "Environment variable NAME=value for the server started by --require-conformance (repeatable)")
+	pushCmd.Flags().DurationVar(&pushTimeout, "timeout", 5*time.Minute, "Time allowed to start the server and run the conformance suite")
+
+	// Flags for 'test' command
+	testCmd.Flags().StringArrayVarP(&envFlags, "env", "e", nil, "Environment variable NAME=value for the server (repeatable)")
+	testCmd.Flags().DurationVar(&testTimeout, "timeout", 5*time.Minute, "Time allowed to start the server and run the conformance suite and tool tests")
+	testCmd.Flags().StringVar(&testsFlag, "tests", "", "Tool test file (default: mcp.tests.json or mcp.tests.yaml next to the project's mcp.json)")
+	testCmd.Flags().StringVar(&junitFlag, "junit", "", "Also write the results as JUnit XML to this file")
+	testCmd.Flags().BoolVarP(&quietFlag, "quiet", "q", false, "Do not stream build output (it is still written to the build log)")
+	testCmd.Flags().BoolVar(&forceFlag, "force", false, "Rebuild even when the sources are unchanged")
+	testCmd.Flags().StringVar(&templatesFlag, "templates", "", "Directory of custom Dockerfile templates (default: $MCPHUB_TEMPLATES or \"templates\" in ~/.mcphub/config.json)")
+
+	// Flags for 'pull' command
+	pullCmd.Flags().StringVar(&platformFlag, "platform", "", "Platform to download (defaults to this machine's platform)")
+
+	// Flags for 'search' command
+	searchCmd.Flags().BoolVar(&remoteFlag, "remote", false, "Search the servers published in the registry instead of local images")
+
+	// Flags for 'call' command
+	callCmd.Flags().StringVar(&argsFlag, "args", "", "Tool arguments as a JSON object")
+	callCmd.Flags().StringVar(&argsFileFlag, "args-file", "", "File with the tool arguments as a JSON object (- for standard input)")
+	callCmd.Flags().BoolVar(&jsonFlag, "json", false, "Print the result as JSON")
+	callCmd.Flags().StringArrayVarP(&envFlags, "env", "e", nil, "Environment variable NAME=value for a server started for the call (repeatable)")
+	callCmd.Flags().DurationVar(&callTimeout, "timeout", 2*time.Minute, "Time allowed to start the server and run the tool")
+
+	// Flags for 'inspect' command
+	inspectCmd.Flags().StringArrayVarP(&envFlags, "env", "e", nil, "Environment variable NAME=value for a server started for the session (repeatable)")
+	inspectCmd.Flags().DurationVar(&inspectTimeout, "timeout", 2*time.Minute, "Time allowed to start the server and connect")
+
+	// Flags for the 'bridge' command, which 'run --bridge' starts, and the 'gateway' and 'record'
+	// commands, which serve clients the same way
+	for _, served := range []struct {
+		cmd      *cobra.Command
+		server   string
+		sessions string
+	}{
+		{bridgeCmd, "the bridge", "Sessions, and so server containers, the bridge serves at once"},
+		{runCmd, "the bridge", "Sessions, and so server containers, the bridge serves at once"},
+		{gatewayCmd, "the gateway", "Client sessions the gateway serves at once"},
+		{recordCmd, "the recording proxy", "Sessions, and so server connections, the recording proxy serves at once"},
+	} {
+		served.cmd.Flags().StringVar(&listenFlag, "listen", "127.0.0.1:8080", "Address "+served.server+" listens on (use 0.0.0.0:8080 for remote clients)")
+		served.cmd.Flags().IntVar(&maxSessionsFlag, "max-sessions", 0, served.sessions+" (0 for no limit)")
+		served.cmd.Flags().DurationVar(&idleTimeoutFlag, "idle-timeout", 30*time.Minute, "End "+served.server+"'s sessions idle for this long (0 to keep them)")
+		served.cmd.Flags().StringArrayVar(&originFlags, "allow-origin", nil, "Browser origin allowed to use "+served.server+", or * for any (repeatable)")
+	}
+	bridgeCmd.Flags().StringArrayVarP(&envFlags, "env", "e", nil, "Environment variable NAME=value for the server (repeatable)")
+	bridgeCmd.Flags().BoolVar(&backgroundFlag, "background", false, "Started in the background by run")
+	bridgeCmd.Flags().MarkHidden("background")
+	bridgeCmd.Flags().StringVar(&nameFlag, "name", "", "Name run recorded the bridge under")
+	bridgeCmd.Flags().MarkHidden("name")
+
+	// Flags for 'gateway' command
+	gatewayCmd.Flags().StringArrayVar(&serverFlags, "server", nil, "Container or bridge to serve (repeatable; default: every server started by mcphub run)")
+	gatewayCmd.Flags().BoolVar(&stdioFlag, "stdio", false, "Serve over standard input and output instead of HTTP")
+	gatewayCmd.Flags().DurationVar(&intervalFlag, "interval", 5*time.Second, "How often to look for servers starting and stopping")
+
+	// Flags for 'record' command
+	recordCmd.Flags().StringVarP(&outputFlag, "output", "o", "mcp-session.jsonl", "File the recording is written to")
+	recordCmd.Flags().BoolVar(&stdioFlag, "stdio", false, "Serve over standard input and output instead of HTTP")
+	recordCmd.Flags().StringArrayVarP(&envFlags, "env", "e", nil, "Environment variable NAME=value for servers started for the sessions (repeatable)")
+
+	// Flags for 'replay' command
+	replayCmd.Flags().StringVar(&againstFlag, "against", "", "Image to replay the recording against")
+	replayCmd.Flags().StringArrayVar(&ignoreFlags, "ignore", nil, "JSON path such as $.result.serverInfo.version, or key name, left out of the comparison (repeatable)")
+	replayCmd.Flags().BoolVar(&strictFlag, "strict", false, "Compare timestamps and UUIDs too")
+	replayCmd.Flags().StringArrayVarP(&envFlags, "env", "e", nil, "Environment variable NAME=value for the server (repeatable)")
+	replayCmd.Flags().DurationVar(&replayTimeout, "timeout", 30*time.Second, "Time allowed to start the server and for each response")
+	replayCmd.MarkFlagRequired("against")
+
+	// Flags for 'client-config' command
+	clientConfigCmd.Flags().StringVarP(&targetFlag, "target", "t", "", "MCP host: "+strings.Join(services.ClientTargets, ", "))
+	clientConfigCmd.Flags().BoolVarP(&writeFlag, "write", "w", false, "Merge the entries into the host's configuration file, after backing it up")
+	clientConfigCmd.Flags().StringVar(&fileFlag, "file", "", "Configuration file to merge into (defaults to the host's)")
+	clientConfigCmd.MarkFlagRequired("target")
+
 	// Flags for 'run' command
 	runCmd.Flags().BoolVarP(&detached, "detach", "d", true, "Run container in detached mode")
-	runCmd.Flags().StringVarP(&portFlag, "port", "p", "", "Port mapping (e.g., 8080:8080)")
+	runCmd.Flags().StringVarP(&portFlag, "port", "p", "", "Port mapping (e.g., 8080:8080; defaults to the port in the image labels)")
+	runCmd.Flags().StringArrayVarP(&envFlags, "env", "e", nil, "Environment variable NAME=value for the server (repeatable)")
 	runCmd.Flags().StringVarP(&nameFlag, "name", "n", "", "Container name (defaults to image name)")
+	runCmd.Flags().BoolVar(&bridgeFlag, "bridge", false, "Serve a stdio server over HTTP and SSE with a bridge instead of running one container")
 }
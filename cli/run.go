@@ -3,9 +3,11 @@ package cli
 import (
 	"fmt"
 	"os"
-	"os/exec"
 	"strings"
 
+	"mcphub/models"
+	"mcphub/services"
+
 	"github.com/spf13/cobra"
 )
 
@@ -15,8 +17,9 @@ var runCmd = &cobra.Command{
 	Long:  `Start a Docker container from an image that was loaded with mcphub pull`,
 	Args:  cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
-		if !dockerAvailable() {
-			fmt.Println("❌ Docker is not running or not installed. Please start Docker and try again.")
+		rt, err := containerRuntime()
+		if err != nil {
+			fmt.Println(err)
 			return
 		}
 
@@ -26,7 +29,20 @@ var runCmd = &cobra.Command{
 			containerName = imageName
 		}
 
-		// Build docker run command
+		if bridgeFlag {
+			// Each bridge session runs its own container of the image
+			if detached {
+				err = startBridge(rt, imageName, containerName)
+			} else {
+				err = serveBridge(rt, imageName)
+			}
+			if err != nil {
+				fmt.Printf("❌ Failed to run the bridge: %v\n", err)
+			}
+			return
+		}
+
+		// Build run command
 		dockerArgs := []string{"run"}
 
 		if detached {
@@ -35,20 +51,44 @@ var runCmd = &cobra.Command{
 			dockerArgs = append(dockerArgs, "-it")
 		}
 
-		dockerArgs = append(dockerArgs, "--name", containerName)
+		// The label lets mcphub call and friends find the server again
+		dockerArgs = append(dockerArgs, "--name", containerName, "--label", services.LabelManaged+"=true")
 
-		if portFlag != "" {
-			dockerArgs = append(dockerArgs, "-p", portFlag)
+		// The image labels say how the server is reached and what it needs
+		metadata := &services.ImageMetadata{}
+		if labels, err := rt.ImageLabels(imageName); err == nil {
+			metadata = services.ParseImageMetadata(labels)
+		}
+		if detached && metadata.Transport == models.TransportStdio {
+			// Keep stdin open so the stdio server does not exit right away
+			dockerArgs = append(dockerArgs, "-i")
+		}
+
+		port := portFlag
+		if port == "" && metadata.Port > 0 {
+			port = fmt.Sprintf("%d:%d", metadata.Port, metadata.Port)
+		}
+		if port != "" {
+			dockerArgs = append(dockerArgs, "-p", port)
+		}
+
+		forward, missing := metadata.ResolveEnv(envFlags)
+		if len(missing) > 0 {
+			fmt.Printf("❌ Missing required environment variables: %s (pass them with -e NAME=value)\n", strings.Join(missing, ", "))
+			return
+		}
+		for _, env := range append(envFlags, forward...) {
+			dockerArgs = append(dockerArgs, "-e", env)
 		}
 
 		dockerArgs = append(dockerArgs, imageName)
 
 		fmt.Printf("🚀 Running container from image '%s'...\n", imageName)
 		if detached {
-			fmt.Printf("🔧 Command: docker %s\n", strings.Join(dockerArgs, " "))
+			fmt.Printf("🔧 Command: %s %s\n", rt.Binary(), strings.Join(dockerArgs, " "))
 		}
 
-		dockerCmd := exec.Command("docker", dockerArgs...)
+		dockerCmd := rt.Command(dockerArgs...)
 
 		if detached {
 			runOut, err := dockerCmd.CombinedOutput()
@@ -60,11 +100,11 @@ var runCmd = &cobra.Command{
 			fmt.Println("✅ Container started successfully!")
 			fmt.Printf("🆔 Container ID: %s\n", containerID)
 			fmt.Printf("📋 Container Name: %s\n", containerName)
-			if portFlag != "" {
-				fmt.Printf("🌐 Port mapping: %s\n", portFlag)
+			if port != "" {
+				fmt.Printf("🌐 Port mapping: %s\n", port)
 			}
-			fmt.Printf("💡 To view logs: docker logs %s\n", containerName)
-			fmt.Printf("💡 To stop: docker stop %s\n", containerName)
+			fmt.Printf("💡 To view logs: %s logs %s\n", rt.Binary(), containerName)
+			fmt.Printf("💡 To stop: %s stop %s\n", rt.Binary(), containerName)
 		} else {
 			// Run container interactively in foreground
 			dockerCmd.Stdout = os.Stdout
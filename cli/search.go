@@ -0,0 +1,82 @@
+package cli
+
+import (
+	"fmt"
+	"strings"
+
+	"mcphub/services"
+
+	"github.com/spf13/cobra"
+)
+
+var searchCmd = &cobra.Command{
+	Use:   "search <query>",
+	Short: "Find MCP servers by what they offer",
+	Long: `Search the MCP servers in the local runtime by name, description and the tools,
+resources and prompts recorded when they were built, e.g. "mcphub search create issue".
+With --remote the servers published in the registry are searched instead (S3 only).`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		query := strings.Join(args, " ")
+
+		rt, err := containerRuntime()
+		if err != nil {
+			return err
+		}
+
+		var matches []*services.SearchMatch
+		if remoteFlag {
+			registry, err := services.NewRegistry(registryFlag, rt, nil)
+			if err != nil {
+				return err
+			}
+			manifests, err := registry.Manifests()
+			if err != nil {
+				return err
+			}
+			for _, manifest := range manifests {
+				server := manifest.Author + "/" + manifest.Name
+				if match := services.SearchServer(server, manifest.Version, manifest.Config.Description, manifest.Introspection, query); match != nil {
+					matches = append(matches, match)
+				}
+			}
+		} else {
+			images, err := rt.ImagesWithLabel(services.LabelTransport)
+			if err != nil {
+				return err
+			}
+			for _, image := range images {
+				labels, err := rt.ImageLabels(image)
+				if err != nil {
+					continue
+				}
+				metadata := services.ParseImageMetadata(labels)
+				if match := services.SearchServer(image, metadata.Version, metadata.Description, metadata.Introspection, query); match != nil {
+					matches = append(matches, match)
+				}
+			}
+		}
+
+		if len(matches) == 0 {
+			fmt.Printf("🔍 No servers match %q\n", query)
+			return nil
+		}
+		fmt.Printf("🔍 %d servers match %q:\n", len(matches), query)
+		for _, match := range matches {
+			fmt.Printf("📦 %s %s\n", match.Server, match.Version)
+			if description, _, _ := strings.Cut(strings.TrimSpace(match.Description), "\n"); description != "" {
+				fmt.Printf("   %s\n", description)
+			}
+			if len(match.Tools) > 0 {
+				fmt.Printf("   🧰 %s\n", strings.Join(match.Tools, ", "))
+			}
+			if len(match.Resources) > 0 {
+				fmt.Printf("   📚 %s\n", strings.Join(match.Resources, ", "))
+			}
+			if len(match.Prompts) > 0 {
+				fmt.Printf("   💬 %s\n", strings.Join(match.Prompts, ", "))
+			}
+		}
+		return nil
+	},
+}
@@ -0,0 +1,50 @@
+package cli
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"mcphub/services"
+
+	"github.com/spf13/cobra"
+)
+
+var stopCmd = &cobra.Command{
+	Use:   "stop <name>",
+	Short: "Stop a server started with mcphub run",
+	Long: `Stop a server started with mcphub run: the bridge started with --bridge, which ends
+its sessions and their containers, or the container of that name.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name := args[0]
+		bridge, err := services.FindBackgroundBridge(name)
+		if err != nil {
+			return err
+		}
+		if bridge != nil {
+			if err := bridge.Stop(); err != nil {
+				return err
+			}
+			fmt.Printf("🛑 Stopped the bridge %s (PID %d)\n", name, bridge.PID)
+			return nil
+		}
+
+		rt, err := containerRuntime()
+		if err != nil {
+			return err
+		}
+		containers, err := rt.ManagedContainers()
+		if err != nil {
+			return err
+		}
+		if !slices.Contains(containers, name) {
+			return fmt.Errorf("no bridge or running container started by mcphub run is named %s", name)
+		}
+		if output, err := rt.Command("stop", name).CombinedOutput(); err != nil {
+			return fmt.Errorf("failed to stop %s: %s", name, strings.TrimSpace(string(output)))
+		}
+		fmt.Printf("🛑 Stopped the container %s\n", name)
+		return nil
+	},
+}
@@ -0,0 +1,22 @@
+package cli
+
+import (
+	"fmt"
+
+	"mcphub/services"
+
+	"github.com/spf13/cobra"
+)
+
+var templatesCmd = &cobra.Command{
+	Use:   "templates",
+	Short: "Print the built-in Dockerfile template",
+	Long: `Print the built-in Dockerfile template. Custom templates redefine its blocks, e.g.
+"runtime-prelude" to add CA certificates or "runtime-image" to use a company base image.
+Put them in a directory as *.tmpl files (per runtime in subdirectories such as node/ or
+python/) and pass it to push with --templates, MCPHUB_TEMPLATES or ~/.mcphub/config.json.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		fmt.Print(services.BuiltinTemplate())
+	},
+}
@@ -0,0 +1,232 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"mcphub/mcp"
+	"mcphub/models"
+	"mcphub/services"
+
+	"github.com/spf13/cobra"
+)
+
+var testCmd = &cobra.Command{
+	Use:   "test <image|directory|zip-file>",
+	Short: "Check that an MCP server follows the protocol and passes its tests",
+	Long: `Start a server in a throwaway container and run the MCP conformance suite against it:
+1. The initialize handshake
+2. Ping
+3. Capability honesty: declared capabilities answer, undeclared ones offer nothing
+4. Tool input and output schemas are valid JSON Schema
+5. Errors are JSON-RPC error responses (unknown methods get -32601)
+6. The server keeps answering after requests are cancelled
+
+A project directory or zip file is built for this machine's platform first, as push
+would build it. The tool tests in its mcp.tests.json or mcp.tests.yaml (or the file given
+with --tests) are then run against a fresh container. The command fails when any check
+or test fails; --junit also writes the results as JUnit XML.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runTest,
+}
+
+func runTest(cmd *cobra.Command, args []string) error {
+	rt, err := containerRuntime()
+	if err != nil {
+		return err
+	}
+	image, projectDir := args[0], ""
+	if _, err := os.Stat(args[0]); err == nil {
+		if image, projectDir, err = buildForTest(rt, args[0]); err != nil {
+			return err
+		}
+	}
+
+	// Read the tool tests before starting anything, so mistakes in them surface early
+	testsPath := testsFlag
+	if testsPath == "" && projectDir != "" {
+		testsPath = services.FindToolTests(projectDir)
+	}
+	var tests *models.ToolTestFile
+	if testsPath != "" {
+		if tests, err = services.ReadToolTests(testsPath); err != nil {
+			return err
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	checks, err := checkConformance(ctx, rt, image)
+	if err != nil {
+		return err
+	}
+	suites := []services.TestSuite{conformanceSuite(checks)}
+	failedChecks := failedConformanceChecks(checks)
+
+	failedTests := 0
+	if tests != nil {
+		suite := services.TestSuite{Name: "tools"}
+		fmt.Printf("🧪 Running %d tool tests from %s...\n", len(tests.Tests), filepath.Base(testsPath))
+		results, err := services.RunImageToolTests(ctx, rt, image, envFlags, tests.Tests)
+		if err != nil {
+			fmt.Printf("❌ Failed to start %s: %v\n", image, err)
+			for _, test := range tests.Tests {
+				suite.Cases = append(suite.Cases, services.TestCase{Name: test.Name, Failure: "the server did not start: " + err.Error()})
+			}
+			failedTests = len(tests.Tests)
+		}
+		for _, result := range results {
+			printToolTestResult(result)
+			test := services.TestCase{Name: result.Test.Name, Duration: result.Duration}
+			if !result.Passed() {
+				test.Failure = strings.Join(result.Failures, "\n")
+				if result.Text != "" {
+					test.Failure += "\ntext:\n" + result.Text
+				}
+				failedTests++
+			}
+			suite.Cases = append(suite.Cases, test)
+		}
+		suites = append(suites, suite)
+	}
+
+	if junitFlag != "" {
+		if err := services.WriteJUnit(junitFlag, suites); err != nil {
+			return err
+		}
+		fmt.Printf("📝 JUnit report: %s\n", junitFlag)
+	}
+
+	switch {
+	case failedChecks > 0 && failedTests > 0:
+		return fmt.Errorf("%s failed %d of %d conformance checks and %d of %d tool tests", image, failedChecks, len(checks), failedTests, len(tests.Tests))
+	case failedChecks > 0:
+		return fmt.Errorf("%s failed %d of %d conformance checks", image, failedChecks, len(checks))
+	case failedTests > 0:
+		return fmt.Errorf("%s failed %d of %d tool tests", image, failedTests, len(tests.Tests))
+	}
+	if tests != nil {
+		fmt.Printf("✅ %s passed all %d conformance checks and %d tool tests\n", image, len(checks), len(tests.Tests))
+	} else {
+		fmt.Printf("✅ %s passed all %d conformance checks\n", image, len(checks))
+	}
+	return nil
+}
+
+// buildForTest builds the project in a directory or zip file for the host platform and
+// returns its image and the directory holding its mcp.json
+func buildForTest(rt *services.ContainerRuntime, path string) (string, string, error) {
+	var zipData []byte
+	zipFileName := filepath.Base(path)
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			return "", "", err
+		}
+		zipFileName = filepath.Base(abs) + ".zip"
+		if zipData, err = services.ZipDirectory(abs); err != nil {
+			return "", "", err
+		}
+	} else if zipData, err = os.ReadFile(path); err != nil {
+		return "", "", fmt.Errorf("failed to read zip file: %v", err)
+	}
+
+	templatesDir, err := services.ResolveTemplatesDir(templatesFlag)
+	if err != nil {
+		return "", "", err
+	}
+
+	fmt.Printf("📦 Building %s...\n", strings.TrimSuffix(zipFileName, ".zip"))
+	processor := services.NewZipProcessor(rt, services.ProcessOptions{
+		SkipArchive:       true,
+		SkipIntrospection: true,
+		Force:             forceFlag,
+		Build:             services.BuildOptions{Output: buildOutput()},
+		Progress:          os.Stdout,
+		TemplatesDir:      templatesDir,
+	})
+	prepared, err := processor.PrepareZip(zipData, zipFileName)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to process %s: %v", path, err)
+	}
+	// Only the image for this machine can be started
+	prepared.Config.Platforms = nil
+	result, err := processor.BuildPrepared(prepared)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to build %s: %v", path, err)
+	}
+	return result.ImageName, prepared.ContextDir, nil
+}
+
+// checkConformance runs the conformance suite against image and prints each check
+func checkConformance(ctx context.Context, rt *services.ContainerRuntime, image string) ([]mcp.ConformanceCheck, error) {
+	fmt.Printf("🧪 Checking %s against the MCP protocol...\n", image)
+	checks, err := services.CheckImageConformance(ctx, rt, image, envFlags)
+	if err != nil {
+		return nil, fmt.Errorf("failed to test %s: %v", image, err)
+	}
+	for _, check := range checks {
+		printConformanceCheck(check)
+	}
+	return checks, nil
+}
+
+func failedConformanceChecks(checks []mcp.ConformanceCheck) int {
+	failed := 0
+	for _, check := range checks {
+		if !check.Passed() {
+			failed++
+		}
+	}
+	return failed
+}
+
+func conformanceSuite(checks []mcp.ConformanceCheck) services.TestSuite {
+	suite := services.TestSuite{Name: "conformance"}
+	for _, check := range checks {
+		suite.Cases = append(suite.Cases, services.TestCase{
+			Name:     check.Name,
+			Failure:  strings.Join(check.Problems, "\n"),
+			Skipped:  check.Skipped,
+			Duration: check.Duration,
+		})
+	}
+	return suite
+}
+
+func printConformanceCheck(check mcp.ConformanceCheck) {
+	switch {
+	case !check.Passed():
+		fmt.Printf("❌ %s\n", check.Name)
+		for _, problem := range check.Problems {
+			fmt.Printf("   %s\n", indent(problem))
+		}
+	case check.Skipped != "":
+		fmt.Printf("⏭️  %s (skipped: %s)\n", check.Name, check.Skipped)
+	default:
+		fmt.Printf("✅ %s\n", check.Name)
+	}
+}
+
+func printToolTestResult(result services.ToolTestResult) {
+	if result.Passed() {
+		fmt.Printf("✅ %s (%s)\n", result.Test.Name, result.Duration.Round(time.Millisecond))
+		return
+	}
+	fmt.Printf("❌ %s\n", result.Test.Name)
+	for _, failure := range result.Failures {
+		fmt.Printf("   %s\n", indent(failure))
+	}
+	if result.Text != "" {
+		fmt.Printf("   text:\n     %s\n", strings.ReplaceAll(result.Text, "\n", "\n     "))
+	}
+}
+
+// indent continues multi-line messages under the first line of a check or test
+func indent(message string) string {
+	return strings.ReplaceAll(message, "\n", "\n   ")
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"fmt"
 	"mcphub/cli"
+	"os"
 )
 const welcomeArt = `
 ███╗   ███╗ ██████╗  ██████╗  ██╗  ██╗           ██╗
@@ -13,6 +14,7 @@ const welcomeArt = `
 ╚═╝     ╚═╝  ╚═════╝ ╚═╝      ╚═╝  ╚═╝ ╚██████╔╝ ╚═════╝
  `
 func main() {
-	fmt.Println(welcomeArt)
+	// On stderr, so output piped to other tools and stdio MCP sessions stay clean
+	fmt.Fprintln(os.Stderr, welcomeArt)
 	cli.Execute()
 }
@@ -0,0 +1,575 @@
+package mcp
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Endpoints served by a Bridge
+const (
+	BridgePath        = "/mcp"
+	BridgeSSEPath     = "/sse"
+	BridgeMessagePath = "/message"
+)
+
+// maxMessageSize bounds the body of a message posted to a Bridge
+const maxMessageSize = 16 << 20
+
+// Bridge serves MCP servers reached through a Transport, such as stdio servers or a
+// Server, over the streamable HTTP transport (at BridgePath) and the legacy SSE transport
+// (at BridgeSSEPath and BridgeMessagePath). A stdio server talks to a single client, so
+// every session gets its own server, started when the client initializes and stopped
+// when the session ends.
+type Bridge struct {
+	start func(ctx context.Context) (Transport, error)
+
+	// MaxSessions limits the sessions served at once; 0 means no limit
+	MaxSessions int
+	// IdleTimeout ends sessions without open requests or streams for that long; 0 never does
+	IdleTimeout time.Duration
+	// AllowedOrigins are the browser origins, besides the bridge's own host, allowed to
+	// connect; "*" allows any. Other origins are refused to prevent DNS rebinding.
+	AllowedOrigins []string
+	// Logf, when set, reports sessions starting and ending
+	Logf func(format string, args ...any)
+
+	mu       sync.Mutex
+	sessions map[string]*bridgeSession
+	starting int // sessions whose server is starting, counted against MaxSessions
+	reaper   sync.Once
+	closed   chan struct{}
+}
+
+// NewBridge serves the servers start starts, one per session. The transport start returns
+// is closed when its session ends.
+func NewBridge(start func(ctx context.Context) (Transport, error)) *Bridge {
+	return &Bridge{start: start, sessions: map[string]*bridgeSession{}, closed: make(chan struct{})}
+}
+
+// bridgeSession is a client session and the server started for it
+type bridgeSession struct {
+	id        string
+	transport Transport
+	done      chan struct{}
+	end       sync.Once
+
+	mu       sync.Mutex
+	waiters  map[string]*bridgeStream // posted requests waiting for their response
+	stream   *bridgeStream            // the GET or legacy SSE stream, when open
+	active   int                      // open HTTP requests
+	lastUsed time.Time
+}
+
+// bridgeStream carries server messages to one HTTP response
+type bridgeStream struct {
+	messages chan *Message
+	closed   chan struct{}
+	// streaming is set when the response is an event stream that can also carry the
+	// server's requests and notifications
+	streaming bool
+}
+
+func newBridgeStream(streaming bool) *bridgeStream {
+	return &bridgeStream{messages: make(chan *Message, 64), closed: make(chan struct{}), streaming: streaming}
+}
+
+func (b *Bridge) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !b.originAllowed(r) {
+		http.Error(w, "origin not allowed", http.StatusForbidden)
+		return
+	}
+	switch r.URL.Path {
+	case BridgePath:
+		switch r.Method {
+		case http.MethodPost:
+			b.post(w, r)
+		case http.MethodGet:
+			b.listen(w, r)
+		case http.MethodDelete:
+			session := b.session(w, r.Header.Get(sessionHeader))
+			if session != nil {
+				b.endSession(session, "closed by the client")
+				w.WriteHeader(http.StatusNoContent)
+			}
+		default:
+			w.Header().Set("Allow", "GET, POST, DELETE")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	case BridgeSSEPath:
+		b.serveSSE(w, r)
+	case BridgeMessagePath:
+		b.postSSE(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (b *Bridge) originAllowed(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	if parsed, err := url.Parse(origin); err == nil && parsed.Host == r.Host {
+		return true
+	}
+	for _, allowed := range b.AllowedOrigins {
+		if allowed == "*" || strings.EqualFold(allowed, origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// post forwards a message of the streamable HTTP transport. A request is answered with
+// its response, as JSON or, when the client accepts it, as an event stream that also
+// carries what the server sends before the response.
+func (b *Bridge) post(w http.ResponseWriter, r *http.Request) {
+	message, ok := readMessage(w, r)
+	if !ok {
+		return
+	}
+
+	var session *bridgeSession
+	if id := r.Header.Get(sessionHeader); id != "" {
+		if session = b.session(w, id); session == nil {
+			return
+		}
+	} else if message.Method == "initialize" {
+		var err error
+		if session, err = b.openSession(r.Context(), w); err != nil {
+			return
+		}
+		w.Header().Set(sessionHeader, session.id)
+	} else {
+		http.Error(w, "missing "+sessionHeader+" header", http.StatusBadRequest)
+		return
+	}
+	session.use(1)
+	defer session.use(-1)
+
+	if !message.IsRequest() {
+		if err := session.transport.Send(r.Context(), message); err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+
+	streaming := acceptsEventStream(r)
+	waiter := newBridgeStream(streaming)
+	id := string(message.ID)
+	session.mu.Lock()
+	session.waiters[id] = waiter
+	session.mu.Unlock()
+	defer func() {
+		session.mu.Lock()
+		if session.waiters[id] == waiter {
+			delete(session.waiters, id)
+		}
+		session.mu.Unlock()
+		close(waiter.closed)
+	}()
+
+	if err := session.transport.Send(r.Context(), message); err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	if !streaming {
+		select {
+		case response := <-waiter.messages:
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(response)
+		case <-session.done:
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(exitedResponse(message.ID))
+		case <-r.Context().Done():
+		}
+		return
+	}
+
+	flusher := startEventStream(w)
+	for {
+		select {
+		case sent := <-waiter.messages:
+			writeEvent(w, flusher, "message", sent)
+			if sent.IsResponse() && string(sent.ID) == id {
+				return
+			}
+		case <-session.done:
+			writeEvent(w, flusher, "message", exitedResponse(message.ID))
+			return
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// exitedResponse answers a request the server exited before answering
+func exitedResponse(id json.RawMessage) *Message {
+	return &Message{JSONRPC: "2.0", ID: id, Error: &Error{Code: CodeInternalError, Message: "the server exited"}}
+}
+
+// listen opens the stream of the streamable HTTP transport for the server's requests and
+// notifications outside of a request
+func (b *Bridge) listen(w http.ResponseWriter, r *http.Request) {
+	if !acceptsEventStream(r) {
+		http.Error(w, "the stream requires Accept: text/event-stream", http.StatusNotAcceptable)
+		return
+	}
+	session := b.session(w, r.Header.Get(sessionHeader))
+	if session == nil {
+		return
+	}
+	stream, ok := session.attach()
+	if !ok {
+		http.Error(w, "the session already has a stream", http.StatusConflict)
+		return
+	}
+	defer session.detach(stream)
+
+	b.relay(w, r, session, stream)
+}
+
+// serveSSE opens a session of the legacy SSE transport, which lasts as long as its stream
+func (b *Bridge) serveSSE(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	session, err := b.openSession(r.Context(), w)
+	if err != nil {
+		return
+	}
+	defer b.endSession(session, "stream closed")
+	stream, _ := session.attach()
+	defer session.detach(stream)
+
+	flusher := startEventStream(w)
+	fmt.Fprintf(w, "event: endpoint\ndata: %s?sessionId=%s\n\n", BridgeMessagePath, session.id)
+	flusher.Flush()
+	b.relay(w, r, session, stream)
+}
+
+// postSSE forwards a message of the legacy SSE transport; the answer goes to the stream
+func (b *Bridge) postSSE(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	message, ok := readMessage(w, r)
+	if !ok {
+		return
+	}
+	session := b.session(w, r.URL.Query().Get("sessionId"))
+	if session == nil {
+		return
+	}
+	session.use(1)
+	defer session.use(-1)
+	if err := session.transport.Send(r.Context(), message); err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// relay writes the messages of stream as events until the client or the server leaves
+func (b *Bridge) relay(w http.ResponseWriter, r *http.Request, session *bridgeSession, stream *bridgeStream) {
+	flusher := startEventStream(w)
+	for {
+		select {
+		case message := <-stream.messages:
+			writeEvent(w, flusher, "message", message)
+		case <-session.done:
+			return
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// openSession starts a server for a new session, answering w itself on failure
+func (b *Bridge) openSession(ctx context.Context, w http.ResponseWriter) (*bridgeSession, error) {
+	// The slot is reserved before starting the server so concurrent clients cannot exceed
+	// MaxSessions
+	b.mu.Lock()
+	full := b.MaxSessions > 0 && len(b.sessions)+b.starting >= b.MaxSessions
+	if !full {
+		b.starting++
+	}
+	b.mu.Unlock()
+	if full {
+		err := fmt.Errorf("too many sessions (at most %d)", b.MaxSessions)
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return nil, err
+	}
+
+	transport, err := b.start(ctx)
+	if err != nil {
+		b.mu.Lock()
+		b.starting--
+		b.mu.Unlock()
+		http.Error(w, "failed to start the server: "+err.Error(), http.StatusBadGateway)
+		return nil, err
+	}
+	id := make([]byte, 16)
+	rand.Read(id)
+	session := &bridgeSession{
+		id:        hex.EncodeToString(id),
+		transport: transport,
+		done:      make(chan struct{}),
+		waiters:   map[string]*bridgeStream{},
+		lastUsed:  time.Now(),
+	}
+
+	b.mu.Lock()
+	b.starting--
+	b.sessions[session.id] = session
+	b.mu.Unlock()
+	b.logf("session %s started", session.id)
+	if b.IdleTimeout > 0 {
+		b.reaper.Do(func() { go b.reap() })
+	}
+
+	go func() {
+		for {
+			message, err := transport.Receive()
+			if err != nil {
+				b.endSession(session, "the server exited")
+				return
+			}
+			session.route(message)
+		}
+	}()
+	return session, nil
+}
+
+// session finds the session with id, answering w itself when there is none
+func (b *Bridge) session(w http.ResponseWriter, id string) *bridgeSession {
+	b.mu.Lock()
+	session := b.sessions[id]
+	b.mu.Unlock()
+	if session == nil {
+		if id == "" {
+			http.Error(w, "missing session", http.StatusBadRequest)
+		} else {
+			http.Error(w, "unknown or expired session", http.StatusNotFound)
+		}
+	}
+	return session
+}
+
+func (b *Bridge) endSession(session *bridgeSession, reason string) {
+	session.end.Do(func() {
+		b.mu.Lock()
+		delete(b.sessions, session.id)
+		b.mu.Unlock()
+		close(session.done)
+		session.transport.Close()
+		b.logf("session %s ended: %s", session.id, reason)
+	})
+}
+
+// reap ends idle sessions until the bridge is closed
+func (b *Bridge) reap() {
+	ticker := time.NewTicker(max(b.IdleTimeout/4, time.Second))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-b.closed:
+			return
+		}
+		b.mu.Lock()
+		var idle []*bridgeSession
+		for _, session := range b.sessions {
+			if session.idleFor() > b.IdleTimeout {
+				idle = append(idle, session)
+			}
+		}
+		b.mu.Unlock()
+		for _, session := range idle {
+			b.endSession(session, "idle")
+		}
+	}
+}
+
+// Sessions returns the number of sessions being served
+func (b *Bridge) Sessions() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.sessions)
+}
+
+// Close ends every session and stops their servers
+func (b *Bridge) Close() error {
+	b.mu.Lock()
+	select {
+	case <-b.closed:
+	default:
+		close(b.closed)
+	}
+	sessions := make([]*bridgeSession, 0, len(b.sessions))
+	for _, session := range b.sessions {
+		sessions = append(sessions, session)
+	}
+	b.mu.Unlock()
+	for _, session := range sessions {
+		b.endSession(session, "bridge closed")
+	}
+	return nil
+}
+
+func (b *Bridge) logf(format string, args ...any) {
+	if b.Logf != nil {
+		b.Logf(format, args...)
+	}
+}
+
+// route hands a server message to the request waiting for it or to the session's stream.
+// Requests and notifications go to the stream, or to a posted request's event stream when
+// the client has none open. A server request nobody can receive is answered with an error.
+func (s *bridgeSession) route(message *Message) {
+	s.mu.Lock()
+	var target *bridgeStream
+	if message.IsResponse() {
+		target = s.waiters[string(message.ID)]
+		delete(s.waiters, string(message.ID))
+	}
+	if target == nil {
+		target = s.stream
+	}
+	if target == nil && !message.IsResponse() {
+		for _, waiter := range s.waiters {
+			if waiter.streaming {
+				target = waiter
+				break
+			}
+		}
+	}
+	s.mu.Unlock()
+
+	if target == nil {
+		if message.IsRequest() {
+			s.transport.Send(context.Background(), &Message{JSONRPC: "2.0", ID: message.ID, Error: &Error{
+				Code: CodeInternalError, Message: "no client stream is open to receive the request",
+			}})
+		}
+		return
+	}
+	select {
+	case target.messages <- message:
+	case <-target.closed:
+	case <-s.done:
+	}
+}
+
+// attach opens the session's stream; ok is false when one is already open
+func (s *bridgeSession) attach() (stream *bridgeStream, ok bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stream != nil {
+		return nil, false
+	}
+	s.stream = newBridgeStream(true)
+	s.active++
+	return s.stream, true
+}
+
+func (s *bridgeSession) detach(stream *bridgeStream) {
+	s.mu.Lock()
+	if s.stream == stream {
+		s.stream = nil
+	}
+	s.active--
+	s.lastUsed = time.Now()
+	s.mu.Unlock()
+	close(stream.closed)
+}
+
+// use counts the open HTTP requests of the session
+func (s *bridgeSession) use(delta int) {
+	s.mu.Lock()
+	s.active += delta
+	s.lastUsed = time.Now()
+	s.mu.Unlock()
+}
+
+func (s *bridgeSession) idleFor() time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.active > 0 {
+		return 0
+	}
+	return time.Since(s.lastUsed)
+}
+
+// readMessage decodes the posted message, answering w itself when it is not one
+func readMessage(w http.ResponseWriter, r *http.Request) (*Message, bool) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxMessageSize))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil, false
+	}
+	var message Message
+	if err := json.Unmarshal(body, &message); err != nil || message.JSONRPC != "2.0" {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(&Message{JSONRPC: "2.0", Error: &Error{
+			Code: CodeParseError, Message: "expected a single JSON-RPC 2.0 message",
+		}})
+		return nil, false
+	}
+	return &message, true
+}
+
+func acceptsEventStream(r *http.Request) bool {
+	for _, accepted := range strings.Split(r.Header.Get("Accept"), ",") {
+		if mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(accepted)); err == nil && mediaType == "text/event-stream" {
+			return true
+		}
+	}
+	return false
+}
+
+// startEventStream sends the headers of an event stream
+func startEventStream(w http.ResponseWriter) http.Flusher {
+	if w.Header().Get("Content-Type") != "text/event-stream" {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.WriteHeader(http.StatusOK)
+	}
+	flusher, _ := w.(http.Flusher)
+	if flusher == nil {
+		flusher = noFlusher{}
+	}
+	flusher.Flush()
+	return flusher
+}
+
+func writeEvent(w io.Writer, flusher http.Flusher, event string, message *Message) {
+	data, err := json.Marshal(message)
+	if err != nil {
+		return
+	}
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data)
+	flusher.Flush()
+}
+
+type noFlusher struct{}
+
+func (noFlusher) Flush() {}
@@ -0,0 +1,531 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ClientInfo identifies MCPHub to the servers it connects to
+var ClientInfo = Implementation{Name: "mcphub", Version: "1.0.0"}
+
+// ErrNotSupported is returned for requests the server did not declare a capability for
+var ErrNotSupported = errors.New("not supported by the server")
+
+// RequestHandler answers a request the server sends to the client. Returning an *Error
+// sends it as is; other errors become internal errors.
+type RequestHandler func(ctx context.Context, params json.RawMessage) (any, error)
+
+// NotificationHandler receives the notifications the server sends
+type NotificationHandler func(method string, params json.RawMessage)
+
+// cancelTimeout bounds sending the cancellation of an abandoned request
+const cancelTimeout = 5 * time.Second
+
+// Client is a connection to one MCP server
+type Client struct {
+	transport Transport
+	nextID    atomic.Int64
+
+	mu       sync.Mutex
+	pending  map[string]chan *Message
+	inFlight map[string]context.CancelFunc
+	err      error
+
+	handlers      map[string]RequestHandler
+	notifications []NotificationHandler
+	initialized   *InitializeResult
+}
+
+// NewClient starts reading server messages from transport. Register handlers, then call
+// Initialize before anything else.
+func NewClient(transport Transport) *Client {
+	c := &Client{
+		transport: transport,
+		pending:   map[string]chan *Message{},
+		inFlight:  map[string]context.CancelFunc{},
+		handlers:  map[string]RequestHandler{},
+	}
+	c.handlers["ping"] = func(ctx context.Context, params json.RawMessage) (any, error) {
+		return struct{}{}, nil
+	}
+	go c.readLoop()
+	return c
+}
+
+// HandleRequest answers the server's requests for method, e.g. "roots/list" or
+// "sampling/createMessage". The matching client capability is declared on Initialize,
+// so handlers must be registered before it.
+func (c *Client) HandleRequest(method string, handler RequestHandler) {
+	c.mu.Lock()
+	c.handlers[method] = handler
+	c.mu.Unlock()
+}
+
+// SetRoots answers roots/list with roots and declares the roots capability
+func (c *Client) SetRoots(roots []Root) {
+	c.HandleRequest("roots/list", func(ctx context.Context, params json.RawMessage) (any, error) {
+		return listRootsResult{Roots: roots}, nil
+	})
+}
+
+// OnNotification calls handler for every notification from the server, such as log
+// messages, progress and list changes. Handlers run on the connection's read loop and
+// must not block.
+func (c *Client) OnNotification(handler NotificationHandler) {
+	c.mu.Lock()
+	c.notifications = append(c.notifications, handler)
+	c.mu.Unlock()
+}
+
+func (c *Client) readLoop() {
+	for {
+		message, err := c.transport.Receive()
+		if err != nil {
+			c.fail(err)
+			return
+		}
+		switch {
+		case message.IsResponse():
+			c.mu.Lock()
+			response, ok := c.pending[string(message.ID)]
+			delete(c.pending, string(message.ID))
+			c.mu.Unlock()
+			if ok {
+				response <- message
+			}
+		case message.IsRequest():
+			c.serve(message)
+		case message.IsNotification():
+			c.notify(message)
+		}
+	}
+}
+
+// serve runs the handler of a server request in the background so the read loop keeps
+// delivering responses, including those the handler may be waiting for
+func (c *Client) serve(request *Message) {
+	ctx, cancel := context.WithCancel(context.Background())
+	c.mu.Lock()
+	handler := c.handlers[request.Method]
+	c.inFlight[string(request.ID)] = cancel
+	c.mu.Unlock()
+
+	go func() {
+		defer func() {
+			c.mu.Lock()
+			delete(c.inFlight, string(request.ID))
+			c.mu.Unlock()
+			cancel()
+		}()
+
+		response := &Message{JSONRPC: "2.0", ID: request.ID}
+		if handler == nil {
+			response.Error = &Error{Code: CodeMethodNotFound, Message: "method not found: " + request.Method}
+		} else if result, err := handler(ctx, request.Params); err != nil {
+			var rpcErr *Error
+			if !errors.As(err, &rpcErr) {
+				rpcErr = &Error{Code: CodeInternalError, Message: err.Error()}
+			}
+			response.Error = rpcErr
+		} else if response.Result, err = json.Marshal(result); err != nil {
+			response.Result = nil
+			response.Error = &Error{Code: CodeInternalError, Message: err.Error()}
+		}
+
+		if ctx.Err() != nil {
+			// Cancelled by the server, which expects no response
+			return
+		}
+		c.transport.Send(context.Background(), response)
+	}()
+}
+
+func (c *Client) notify(notification *Message) {
+	if notification.Method == NotificationCancelled {
+		var params cancelledParams
+		if json.Unmarshal(notification.Params, &params) == nil {
+			c.mu.Lock()
+			if cancel, ok := c.inFlight[string(params.RequestID)]; ok {
+				cancel()
+			}
+			c.mu.Unlock()
+		}
+	}
+
+	c.mu.Lock()
+	handlers := c.notifications
+	c.mu.Unlock()
+	for _, handler := range handlers {
+		handler(notification.Method, notification.Params)
+	}
+}
+
+// fail ends every pending request with err
+func (c *Client) fail(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.err = err
+	for id, response := range c.pending {
+		close(response)
+		delete(c.pending, id)
+	}
+}
+
+// Request sends a request and decodes its result into result (unless nil). JSON-RPC errors
+// are returned as *Error. When ctx ends first the server is told to cancel the request.
+func (c *Client) Request(ctx context.Context, method string, params, result any) error {
+	message, response, err := c.newRequest(method, params)
+	if err != nil {
+		return err
+	}
+	id := string(message.ID)
+
+	if err := c.transport.Send(ctx, message); err != nil {
+		c.forget(id)
+		if ctx.Err() != nil {
+			// The HTTP transport waits for the response in Send, so the server may be working on it
+			c.abandon(method, message.ID, ctx.Err())
+		}
+		return fmt.Errorf("%s failed: %w", method, err)
+	}
+
+	select {
+	case reply, ok := <-response:
+		if !ok {
+			return fmt.Errorf("%s failed: %w", method, c.closedErr())
+		}
+		if reply.Error != nil {
+			return reply.Error
+		}
+		if result == nil {
+			return nil
+		}
+		if err := json.Unmarshal(reply.Result, result); err != nil {
+			return fmt.Errorf("invalid %s result: %w", method, err)
+		}
+		return nil
+	case <-ctx.Done():
+		c.forget(id)
+		c.abandon(method, message.ID, ctx.Err())
+		return fmt.Errorf("%s failed: %w", method, ctx.Err())
+	}
+}
+
+// newRequest numbers a request and registers the channel its response is delivered on. The
+// caller sends it, and forgets its id when no longer waiting.
+func (c *Client) newRequest(method string, params any) (*Message, chan *Message, error) {
+	message := &Message{JSONRPC: "2.0", Method: method}
+	if params != nil {
+		data, err := json.Marshal(params)
+		if err != nil {
+			return nil, nil, err
+		}
+		message.Params = data
+	}
+
+	id := strconv.FormatInt(c.nextID.Add(1), 10)
+	message.ID = json.RawMessage(id)
+	response := make(chan *Message, 1)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err != nil {
+		return nil, nil, c.err
+	}
+	c.pending[id] = response
+	return message, response, nil
+}
+
+// abandon tells the server to stop working on an abandoned request. The initialize request
+// must not be cancelled.
+func (c *Client) abandon(method string, id json.RawMessage, reason error) {
+	if method == "initialize" {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), cancelTimeout)
+	defer cancel()
+	c.Notify(ctx, NotificationCancelled, cancelledParams{RequestID: id, Reason: reason.Error()})
+}
+
+// Notify sends a notification, which gets no response
+func (c *Client) Notify(ctx context.Context, method string, params any) error {
+	message := &Message{JSONRPC: "2.0", Method: method}
+	if params != nil {
+		data, err := json.Marshal(params)
+		if err != nil {
+			return err
+		}
+		message.Params = data
+	}
+	return c.transport.Send(ctx, message)
+}
+
+func (c *Client) forget(id string) {
+	c.mu.Lock()
+	delete(c.pending, id)
+	c.mu.Unlock()
+}
+
+func (c *Client) closedErr() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err == nil {
+		return ErrClosed
+	}
+	return c.err
+}
+
+// Initialize performs the handshake: it negotiates the protocol version, declares the
+// capabilities of the registered request handlers and learns those of the server
+func (c *Client) Initialize(ctx context.Context) (*InitializeResult, error) {
+	params := initializeParams{
+		ProtocolVersion: LatestProtocolVersion,
+		Capabilities:    c.capabilities(),
+		ClientInfo:      ClientInfo,
+	}
+	var result InitializeResult
+	if err := c.Request(ctx, "initialize", params, &result); err != nil {
+		return nil, err
+	}
+	if !supportedVersion(result.ProtocolVersion) {
+		return nil, fmt.Errorf("server speaks unsupported protocol version %q", result.ProtocolVersion)
+	}
+	if versioned, ok := c.transport.(interface{ setProtocolVersion(string) }); ok {
+		versioned.setProtocolVersion(result.ProtocolVersion)
+	}
+	if err := c.Notify(ctx, "notifications/initialized", nil); err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	c.initialized = &result
+	c.mu.Unlock()
+	return &result, nil
+}
+
+// capabilities declares what the registered request handlers support
+func (c *Client) capabilities() ClientCapabilities {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var capabilities ClientCapabilities
+	if c.handlers["roots/list"] != nil {
+		capabilities.Roots = &Capability{}
+	}
+	if c.handlers["sampling/createMessage"] != nil {
+		capabilities.Sampling = &Capability{}
+	}
+	if c.handlers["elicitation/create"] != nil {
+		capabilities.Elicitation = &Capability{}
+	}
+	return capabilities
+}
+
+func supportedVersion(version string) bool {
+	for _, supported := range SupportedProtocolVersions {
+		if version == supported {
+			return true
+		}
+	}
+	return false
+}
+
+// InitializeResult returns the result of the handshake, or nil before Initialize
+func (c *Client) InitializeResult() *InitializeResult {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.initialized
+}
+
+// require fails with ErrNotSupported when the server did not declare the capability that
+// selected picks from its capabilities. Before Initialize everything is allowed.
+func (c *Client) require(name string, selected func(ServerCapabilities) bool) error {
+	initialized := c.InitializeResult()
+	if initialized == nil || selected(initialized.Capabilities) {
+		return nil
+	}
+	return fmt.Errorf("%s: %w", name, ErrNotSupported)
+}
+
+func hasTools(capabilities ServerCapabilities) bool     { return capabilities.Tools != nil }
+func hasResources(capabilities ServerCapabilities) bool { return capabilities.Resources != nil }
+func hasPrompts(capabilities ServerCapabilities) bool   { return capabilities.Prompts != nil }
+func hasLogging(capabilities ServerCapabilities) bool   { return capabilities.Logging != nil }
+
+// Ping checks that the server is responsive
+func (c *Client) Ping(ctx context.Context) error {
+	return c.Request(ctx, "ping", nil, nil)
+}
+
+// ListTools returns every tool of the server, following pagination
+func (c *Client) ListTools(ctx context.Context) ([]Tool, error) {
+	if err := c.require("tools", hasTools); err != nil {
+		return nil, err
+	}
+	var tools []Tool
+	cursor := ""
+	seen := cursors{}
+	for {
+		var page listToolsResult
+		if err := c.Request(ctx, "tools/list", paginatedParams{Cursor: cursor}, &page); err != nil {
+			return nil, err
+		}
+		tools = append(tools, page.Tools...)
+		if cursor = page.NextCursor; cursor == "" {
+			return tools, nil
+		}
+		if err := seen.add("tools/list", cursor); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// cursors are the pagination cursors a list request was given so far
+type cursors map[string]bool
+
+// add records cursor, failing when the server already returned it: following it again
+// would list the same pages forever
+func (seen cursors) add(method, cursor string) error {
+	if seen[cursor] {
+		return fmt.Errorf("%s returned the cursor %q twice", method, cursor)
+	}
+	seen[cursor] = true
+	return nil
+}
+
+// CallTool calls a tool with arguments, a JSON object (nil for none)
+func (c *Client) CallTool(ctx context.Context, name string, arguments json.RawMessage) (*CallToolResult, error) {
+	if err := c.require("tools", hasTools); err != nil {
+		return nil, err
+	}
+	var result CallToolResult
+	if err := c.Request(ctx, "tools/call", callToolParams{Name: name, Arguments: arguments}, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// ListResources returns every resource of the server, following pagination
+func (c *Client) ListResources(ctx context.Context) ([]Resource, error) {
+	if err := c.require("resources", hasResources); err != nil {
+		return nil, err
+	}
+	var resources []Resource
+	cursor := ""
+	seen := cursors{}
+	for {
+		var page listResourcesResult
+		if err := c.Request(ctx, "resources/list", paginatedParams{Cursor: cursor}, &page); err != nil {
+			return nil, err
+		}
+		resources = append(resources, page.Resources...)
+		if cursor = page.NextCursor; cursor == "" {
+			return resources, nil
+		}
+		if err := seen.add("resources/list", cursor); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// ListResourceTemplates returns every resource template of the server, following pagination
+func (c *Client) ListResourceTemplates(ctx context.Context) ([]ResourceTemplate, error) {
+	if err := c.require("resources", hasResources); err != nil {
+		return nil, err
+	}
+	var templates []ResourceTemplate
+	cursor := ""
+	seen := cursors{}
+	for {
+		var page listResourceTemplatesResult
+		if err := c.Request(ctx, "resources/templates/list", paginatedParams{Cursor: cursor}, &page); err != nil {
+			return nil, err
+		}
+		templates = append(templates, page.ResourceTemplates...)
+		if cursor = page.NextCursor; cursor == "" {
+			return templates, nil
+		}
+		if err := seen.add("resources/templates/list", cursor); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// ReadResource returns the contents of the resource at uri
+func (c *Client) ReadResource(ctx context.Context, uri string) ([]ResourceContents, error) {
+	if err := c.require("resources", hasResources); err != nil {
+		return nil, err
+	}
+	var result readResourceResult
+	if err := c.Request(ctx, "resources/read", resourceParams{URI: uri}, &result); err != nil {
+		return nil, err
+	}
+	return result.Contents, nil
+}
+
+// Subscribe asks for notifications/resources/updated when the resource at uri changes
+func (c *Client) Subscribe(ctx context.Context, uri string) error {
+	if err := c.require("resource subscriptions", func(capabilities ServerCapabilities) bool {
+		return capabilities.Resources != nil && capabilities.Resources.Subscribe
+	}); err != nil {
+		return err
+	}
+	return c.Request(ctx, "resources/subscribe", resourceParams{URI: uri}, nil)
+}
+
+// Unsubscribe stops the notifications requested with Subscribe
+func (c *Client) Unsubscribe(ctx context.Context, uri string) error {
+	return c.Request(ctx, "resources/unsubscribe", resourceParams{URI: uri}, nil)
+}
+
+// ListPrompts returns every prompt of the server, following pagination
+func (c *Client) ListPrompts(ctx context.Context) ([]Prompt, error) {
+	if err := c.require("prompts", hasPrompts); err != nil {
+		return nil, err
+	}
+	var prompts []Prompt
+	cursor := ""
+	seen := cursors{}
+	for {
+		var page listPromptsResult
+		if err := c.Request(ctx, "prompts/list", paginatedParams{Cursor: cursor}, &page); err != nil {
+			return nil, err
+		}
+		prompts = append(prompts, page.Prompts...)
+		if cursor = page.NextCursor; cursor == "" {
+			return prompts, nil
+		}
+		if err := seen.add("prompts/list", cursor); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// GetPrompt fills in a prompt with arguments
+func (c *Client) GetPrompt(ctx context.Context, name string, arguments map[string]string) (*GetPromptResult, error) {
+	if err := c.require("prompts", hasPrompts); err != nil {
+		return nil, err
+	}
+	var result GetPromptResult
+	if err := c.Request(ctx, "prompts/get", getPromptParams{Name: name, Arguments: arguments}, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// SetLoggingLevel asks the server to send log messages of level and above, e.g. "debug" or "info"
+func (c *Client) SetLoggingLevel(ctx context.Context, level string) error {
+	if err := c.require("logging", hasLogging); err != nil {
+		return err
+	}
+	return c.Request(ctx, "logging/setLevel", setLevelParams{Level: level}, nil)
+}
+
+// Close ends the connection to the server
+func (c *Client) Close() error {
+	return c.transport.Close()
+}
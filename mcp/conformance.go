@@ -0,0 +1,273 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// HandshakeCheck is the name of the conformance check of the initialize handshake
+const HandshakeCheck = "initialize handshake"
+
+// conformanceTimeout bounds each conformance check
+const conformanceTimeout = 10 * time.Second
+
+// ConformanceCheck is the outcome of one check of the conformance suite
+type ConformanceCheck struct {
+	Name string
+	// Problems are the ways the server failed the check; none means it passed
+	Problems []string
+	// Skipped, when set, is why the check does not apply to the server
+	Skipped  string
+	Duration time.Duration
+}
+
+// Passed reports whether the server passed the check or it was skipped
+func (c ConformanceCheck) Passed() bool {
+	return len(c.Problems) == 0
+}
+
+// ConformancePassed reports whether the server passed every check
+func ConformancePassed(checks []ConformanceCheck) bool {
+	for _, check := range checks {
+		if !check.Passed() {
+			return false
+		}
+	}
+	return true
+}
+
+// CheckConformance runs the protocol conformance suite against a server the client completed
+// the handshake with: the handshake result, ping, whether the declared capabilities match the
+// methods the server answers, tool schemas, JSON-RPC error responses and cancellation
+func CheckConformance(ctx context.Context, client *Client) []ConformanceCheck {
+	initialized := client.InitializeResult()
+	if initialized == nil {
+		return []ConformanceCheck{{Name: HandshakeCheck, Problems: []string{"the client is not initialized"}}}
+	}
+
+	checks := []struct {
+		name  string
+		check func(ctx context.Context, client *Client, initialized *InitializeResult) ConformanceCheck
+	}{
+		{HandshakeCheck, checkHandshake},
+		{"ping", checkPing},
+		{"capability honesty", checkCapabilities},
+		{"tool schemas", checkToolSchemas},
+		{"JSON-RPC errors", checkErrors},
+		{"cancellation", checkCancellation},
+	}
+	results := make([]ConformanceCheck, 0, len(checks))
+	for _, check := range checks {
+		started := time.Now()
+		checkCtx, cancel := context.WithTimeout(ctx, conformanceTimeout)
+		result := check.check(checkCtx, client, initialized)
+		cancel()
+		result.Name = check.name
+		result.Duration = time.Since(started)
+		results = append(results, result)
+	}
+	return results
+}
+
+func checkHandshake(ctx context.Context, client *Client, initialized *InitializeResult) ConformanceCheck {
+	var result ConformanceCheck
+	if initialized.ServerInfo.Name == "" {
+		result.Problems = append(result.Problems, "serverInfo.name is missing from the initialize result")
+	}
+	if initialized.ServerInfo.Version == "" {
+		result.Problems = append(result.Problems, "serverInfo.version is missing from the initialize result")
+	}
+	return result
+}
+
+func checkPing(ctx context.Context, client *Client, initialized *InitializeResult) ConformanceCheck {
+	var result ConformanceCheck
+	if err := client.Ping(ctx); err != nil {
+		result.Problems = append(result.Problems, fmt.Sprintf("ping: %v", err))
+	}
+	return result
+}
+
+// checkCapabilities calls the list method of every capability: declared ones must answer,
+// undeclared ones must not offer anything
+func checkCapabilities(ctx context.Context, client *Client, initialized *InitializeResult) ConformanceCheck {
+	var result ConformanceCheck
+	capabilities := initialized.Capabilities
+	lists := []struct {
+		capability string
+		method     string
+		declared   bool
+	}{
+		{"tools", "tools/list", capabilities.Tools != nil},
+		{"resources", "resources/list", capabilities.Resources != nil},
+		{"prompts", "prompts/list", capabilities.Prompts != nil},
+	}
+	for _, list := range lists {
+		var listed map[string]json.RawMessage
+		err := client.Request(ctx, list.method, nil, &listed)
+		switch {
+		case list.declared && err != nil:
+			result.Problems = append(result.Problems, fmt.Sprintf("declares %s but %s fails: %v", list.capability, list.method, err))
+		case !list.declared && err == nil && offers(listed[list.capability]):
+			result.Problems = append(result.Problems, fmt.Sprintf("%s lists %s but the %s capability is not declared", list.method, list.capability, list.capability))
+		}
+	}
+	if capabilities.Logging != nil {
+		if err := client.Request(ctx, "logging/setLevel", setLevelParams{Level: "info"}, nil); err != nil {
+			result.Problems = append(result.Problems, fmt.Sprintf("declares logging but logging/setLevel fails: %v", err))
+		}
+	}
+	return result
+}
+
+// offers reports whether a list result holds any entries
+func offers(list json.RawMessage) bool {
+	var entries []json.RawMessage
+	return json.Unmarshal(list, &entries) == nil && len(entries) > 0
+}
+
+func checkToolSchemas(ctx context.Context, client *Client, initialized *InitializeResult) ConformanceCheck {
+	var result ConformanceCheck
+	if initialized.Capabilities.Tools == nil {
+		result.Skipped = "the server declares no tools"
+		return result
+	}
+	tools, err := client.ListTools(ctx)
+	if err != nil {
+		result.Problems = append(result.Problems, fmt.Sprintf("tools/list: %v", err))
+		return result
+	}
+
+	seen := map[string]bool{}
+	for _, tool := range tools {
+		if tool.Name == "" {
+			result.Problems = append(result.Problems, "a tool has no name")
+			continue
+		}
+		if seen[tool.Name] {
+			result.Problems = append(result.Problems, fmt.Sprintf("%s is listed more than once", tool.Name))
+		}
+		seen[tool.Name] = true
+		if len(tool.InputSchema) == 0 || string(tool.InputSchema) == "null" {
+			result.Problems = append(result.Problems, fmt.Sprintf("%s has no inputSchema", tool.Name))
+		} else {
+			for _, problem := range ValidateObjectSchema(tool.InputSchema) {
+				result.Problems = append(result.Problems, fmt.Sprintf("%s inputSchema: %s", tool.Name, problem))
+			}
+		}
+		if len(tool.OutputSchema) > 0 {
+			for _, problem := range ValidateObjectSchema(tool.OutputSchema) {
+				result.Problems = append(result.Problems, fmt.Sprintf("%s outputSchema: %s", tool.Name, problem))
+			}
+		}
+	}
+	if len(tools) == 0 {
+		result.Skipped = "the server lists no tools"
+	}
+	return result
+}
+
+// checkErrors sends an unknown method, which must fail with "method not found", and a tool
+// call without a tool name, which must fail with a JSON-RPC error rather than a result
+func checkErrors(ctx context.Context, client *Client, initialized *InitializeResult) ConformanceCheck {
+	var result ConformanceCheck
+	err := client.Request(ctx, "mcphub/conformance/unknown", nil, nil)
+	var rpcErr *Error
+	switch {
+	case err == nil:
+		result.Problems = append(result.Problems, "an unknown method was answered with a result instead of error -32601")
+	case !errors.As(err, &rpcErr):
+		result.Problems = append(result.Problems, fmt.Sprintf("an unknown method got no JSON-RPC error response: %v", err))
+	case rpcErr.Code != CodeMethodNotFound:
+		result.Problems = append(result.Problems, fmt.Sprintf("an unknown method failed with code %d instead of -32601 (method not found)", rpcErr.Code))
+	case rpcErr.Message == "":
+		result.Problems = append(result.Problems, "the error for an unknown method has no message")
+	}
+
+	if initialized.Capabilities.Tools != nil {
+		err := client.Request(ctx, "tools/call", map[string]any{}, nil)
+		switch {
+		case err == nil:
+			result.Problems = append(result.Problems, "tools/call without a tool name was answered with a result instead of an error")
+		case !errors.As(err, &rpcErr):
+			result.Problems = append(result.Problems, fmt.Sprintf("tools/call without a tool name got no JSON-RPC error response: %v", err))
+		case rpcErr.Message == "":
+			result.Problems = append(result.Problems, "the error for tools/call without a tool name has no message")
+		}
+	}
+	return result
+}
+
+// checkCancellation cancels an unknown request and one in flight; the server must ignore the
+// first, keep answering after both and not answer the cancelled request. A request the server
+// answered before reading its cancellation says nothing, so the request only counts as in
+// flight when a ping sent after the cancellation is answered first. Error responses count as
+// answers, since failing ping is a check of its own.
+func checkCancellation(ctx context.Context, client *Client, initialized *InitializeResult) ConformanceCheck {
+	var result ConformanceCheck
+	unknown := cancelledParams{RequestID: json.RawMessage(`"mcphub-conformance-unknown"`), Reason: "conformance check"}
+	if err := client.Notify(ctx, NotificationCancelled, unknown); err != nil {
+		result.Problems = append(result.Problems, fmt.Sprintf("failed to send the cancellation: %v", err))
+		return result
+	}
+	if err := client.Ping(ctx); !answered(err) {
+		result.Problems = append(result.Problems, fmt.Sprintf("no answer after cancelling an unknown request: %v", err))
+		return result
+	}
+
+	method := "ping"
+	if initialized.Capabilities.Tools != nil {
+		method = "tools/list"
+	}
+	request, response, err := client.newRequest(method, nil)
+	if err != nil {
+		result.Problems = append(result.Problems, fmt.Sprintf("failed to send %s: %v", method, err))
+		return result
+	}
+	defer client.forget(string(request.ID))
+	if _, waits := client.transport.(*HTTPTransport); waits {
+		// The HTTP transport waits for the response in Send
+		go client.transport.Send(ctx, request)
+	} else if err := client.transport.Send(ctx, request); err != nil {
+		result.Problems = append(result.Problems, fmt.Sprintf("failed to send %s: %v", method, err))
+		return result
+	}
+	cancelled := cancelledParams{RequestID: request.ID, Reason: "conformance check"}
+	if err := client.Notify(ctx, NotificationCancelled, cancelled); err != nil {
+		result.Problems = append(result.Problems, fmt.Sprintf("failed to send the cancellation: %v", err))
+		return result
+	}
+
+	if err := client.Ping(ctx); !answered(err) {
+		result.Problems = append(result.Problems, fmt.Sprintf("no answer after cancelling %s: %v", method, err))
+		return result
+	}
+	select {
+	case <-response:
+		// Answered before the cancellation was read
+		return result
+	default:
+	}
+	// A second round trip gives a response the server still sent time to arrive
+	if err := client.Ping(ctx); !answered(err) {
+		result.Problems = append(result.Problems, fmt.Sprintf("no answer after cancelling %s: %v", method, err))
+		return result
+	}
+	select {
+	case reply, ok := <-response:
+		if ok {
+			result.Problems = append(result.Problems, fmt.Sprintf("answered %s (id %s) after it was cancelled", method, reply.ID))
+		}
+	default:
+	}
+	return result
+}
+
+// answered reports whether a request got a response, a result or a JSON-RPC error
+func answered(err error) bool {
+	var rpcErr *Error
+	return err == nil || errors.As(err, &rpcErr)
+}
@@ -0,0 +1,324 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// GatewayInfo identifies the gateway to its clients
+var GatewayInfo = Implementation{Name: "mcphub-gateway", Version: "1.0.0"}
+
+// Gateway is an MCP server that aggregates other servers. Their tools and prompts are
+// offered as "<server>.<name>" and their resources under their own URIs; list results
+// are merged and requests are routed to the server they belong to. Servers can be added
+// and removed at any time, which clients learn through list_changed notifications.
+type Gateway struct {
+	*Server
+	// Logf, when set, reports servers failing to answer list requests
+	Logf func(format string, args ...any)
+
+	mu       sync.Mutex
+	backends map[string]*Client
+	// resources maps the URIs listed by each server to it, for routing reads
+	resources map[string]string
+}
+
+// NewGateway creates a gateway without servers; serve it with Connect
+func NewGateway() *Gateway {
+	g := &Gateway{
+		Server: NewServer(GatewayInfo, ServerCapabilities{
+			Tools:     &Capability{ListChanged: true},
+			Resources: &Capability{ListChanged: true, Subscribe: true},
+			Prompts:   &Capability{ListChanged: true},
+			Logging:   &Capability{},
+		}),
+		backends:  map[string]*Client{},
+		resources: map[string]string{},
+	}
+	g.HandleRequest("tools/list", g.listTools)
+	g.HandleRequest("tools/call", g.callTool)
+	g.HandleRequest("resources/list", g.listResources)
+	g.HandleRequest("resources/templates/list", g.listResourceTemplates)
+	g.HandleRequest("resources/read", g.readResource)
+	g.HandleRequest("resources/subscribe", g.subscribe)
+	g.HandleRequest("resources/unsubscribe", g.unsubscribe)
+	g.HandleRequest("prompts/list", g.listPrompts)
+	g.HandleRequest("prompts/get", g.getPrompt)
+	g.HandleRequest("logging/setLevel", g.setLevel)
+	return g
+}
+
+// Add offers the tools, resources and prompts of an initialized client as server,
+// replacing a server of the same name. Its notifications are passed on to the clients.
+func (g *Gateway) Add(server string, client *Client) {
+	client.OnNotification(func(method string, params json.RawMessage) {
+		g.mu.Lock()
+		current := g.backends[server] == client
+		g.mu.Unlock()
+		if current {
+			g.forward(server, method, params)
+		}
+	})
+	g.mu.Lock()
+	g.backends[server] = client
+	g.mu.Unlock()
+	g.listsChanged()
+}
+
+// Remove stops offering server; closing its client is left to the caller
+func (g *Gateway) Remove(server string) {
+	g.mu.Lock()
+	_, ok := g.backends[server]
+	delete(g.backends, server)
+	for uri, owner := range g.resources {
+		if owner == server {
+			delete(g.resources, uri)
+		}
+	}
+	g.mu.Unlock()
+	if ok {
+		g.listsChanged()
+	}
+}
+
+// Servers returns the names of the aggregated servers, sorted
+func (g *Gateway) Servers() []string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	names := make([]string, 0, len(g.backends))
+	for name := range g.backends {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (g *Gateway) listsChanged() {
+	g.Notify(NotificationToolsListChanged, nil)
+	g.Notify(NotificationResourcesListChanged, nil)
+	g.Notify(NotificationPromptsListChanged, nil)
+}
+
+// forward passes a server notification on to the clients. Log messages are attributed to
+// the server.
+func (g *Gateway) forward(server, method string, params json.RawMessage) {
+	switch method {
+	case NotificationMessage:
+		var message LoggingMessage
+		if json.Unmarshal(params, &message) == nil {
+			message.Logger = strings.TrimSuffix(server+"/"+message.Logger, "/")
+			g.Notify(method, message)
+		}
+	case NotificationToolsListChanged, NotificationResourcesListChanged, NotificationPromptsListChanged,
+		NotificationResourceUpdated:
+		g.Notify(method, params)
+	}
+}
+
+// each calls list for every server declaring the capability selected picks, in name
+// order. Servers failing to answer are left out, and logged, rather than failing the
+// whole list.
+func (g *Gateway) each(selected func(ServerCapabilities) bool, list func(server string, client *Client) error) {
+	for _, server := range g.Servers() {
+		g.mu.Lock()
+		client := g.backends[server]
+		g.mu.Unlock()
+		if client == nil {
+			continue
+		}
+		if initialized := client.InitializeResult(); initialized != nil && !selected(initialized.Capabilities) {
+			continue
+		}
+		if err := list(server, client); err != nil && g.Logf != nil {
+			g.Logf("%s: %v", server, err)
+		}
+	}
+}
+
+// route splits a namespaced name into its server's client and the name on that server.
+// The longest matching server name wins, so server names may contain dots.
+func (g *Gateway) route(name string) (*Client, string, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	var match string
+	for server := range g.backends {
+		if strings.HasPrefix(name, server+".") && len(server) > len(match) {
+			match = server
+		}
+	}
+	if match == "" {
+		return nil, "", &Error{Code: CodeInvalidParams, Message: "unknown server in " + name + "; names are <server>.<name>"}
+	}
+	return g.backends[match], strings.TrimPrefix(name, match+"."), nil
+}
+
+func (g *Gateway) listTools(ctx context.Context, params json.RawMessage) (any, error) {
+	result := listToolsResult{Tools: []Tool{}}
+	g.each(hasTools, func(server string, client *Client) error {
+		tools, err := client.ListTools(ctx)
+		for _, tool := range tools {
+			tool.Name = server + "." + tool.Name
+			result.Tools = append(result.Tools, tool)
+		}
+		return err
+	})
+	return result, nil
+}
+
+func (g *Gateway) callTool(ctx context.Context, params json.RawMessage) (any, error) {
+	var call callToolParams
+	if err := json.Unmarshal(params, &call); err != nil {
+		return nil, &Error{Code: CodeInvalidParams, Message: "invalid tools/call parameters"}
+	}
+	client, name, err := g.route(call.Name)
+	if err != nil {
+		return nil, err
+	}
+	return client.CallTool(ctx, name, call.Arguments)
+}
+
+func (g *Gateway) listResources(ctx context.Context, params json.RawMessage) (any, error) {
+	result := listResourcesResult{Resources: []Resource{}}
+	g.each(hasResources, func(server string, client *Client) error {
+		resources, err := client.ListResources(ctx)
+		g.mu.Lock()
+		for _, resource := range resources {
+			g.resources[resource.URI] = server
+		}
+		g.mu.Unlock()
+		for _, resource := range resources {
+			resource.Name = server + "." + resource.Name
+			result.Resources = append(result.Resources, resource)
+		}
+		return err
+	})
+	return result, nil
+}
+
+func (g *Gateway) listResourceTemplates(ctx context.Context, params json.RawMessage) (any, error) {
+	result := listResourceTemplatesResult{ResourceTemplates: []ResourceTemplate{}}
+	g.each(hasResources, func(server string, client *Client) error {
+		templates, err := client.ListResourceTemplates(ctx)
+		for _, template := range templates {
+			template.Name = server + "." + template.Name
+			result.ResourceTemplates = append(result.ResourceTemplates, template)
+		}
+		return err
+	})
+	return result, nil
+}
+
+// resourceServer finds the client serving uri: the server that listed it or, for
+// resources from templates and links, the first server that can read it
+func (g *Gateway) resourceServer(ctx context.Context, uri string) (*Client, error) {
+	g.mu.Lock()
+	client := g.backends[g.resources[uri]]
+	g.mu.Unlock()
+	if client != nil {
+		return client, nil
+	}
+
+	var found *Client
+	g.each(hasResources, func(server string, candidate *Client) error {
+		if found != nil {
+			return nil
+		}
+		if _, err := candidate.ReadResource(ctx, uri); err != nil {
+			return nil
+		}
+		found = candidate
+		g.mu.Lock()
+		g.resources[uri] = server
+		g.mu.Unlock()
+		return nil
+	})
+	if found == nil {
+		return nil, &Error{Code: CodeInvalidParams, Message: "no server has the resource " + uri}
+	}
+	return found, nil
+}
+
+func (g *Gateway) readResource(ctx context.Context, params json.RawMessage) (any, error) {
+	var read resourceParams
+	if err := json.Unmarshal(params, &read); err != nil {
+		return nil, &Error{Code: CodeInvalidParams, Message: "invalid resources/read parameters"}
+	}
+	client, err := g.resourceServer(ctx, read.URI)
+	if err != nil {
+		return nil, err
+	}
+	contents, err := client.ReadResource(ctx, read.URI)
+	if err != nil {
+		return nil, err
+	}
+	return readResourceResult{Contents: contents}, nil
+}
+
+func (g *Gateway) subscribe(ctx context.Context, params json.RawMessage) (any, error) {
+	var subscription resourceParams
+	if err := json.Unmarshal(params, &subscription); err != nil {
+		return nil, &Error{Code: CodeInvalidParams, Message: "invalid resources/subscribe parameters"}
+	}
+	client, err := g.resourceServer(ctx, subscription.URI)
+	if err != nil {
+		return nil, err
+	}
+	return struct{}{}, client.Subscribe(ctx, subscription.URI)
+}
+
+func (g *Gateway) unsubscribe(ctx context.Context, params json.RawMessage) (any, error) {
+	var subscription resourceParams
+	if err := json.Unmarshal(params, &subscription); err != nil {
+		return nil, &Error{Code: CodeInvalidParams, Message: "invalid resources/unsubscribe parameters"}
+	}
+	client, err := g.resourceServer(ctx, subscription.URI)
+	if err != nil {
+		return nil, err
+	}
+	return struct{}{}, client.Unsubscribe(ctx, subscription.URI)
+}
+
+func (g *Gateway) listPrompts(ctx context.Context, params json.RawMessage) (any, error) {
+	result := listPromptsResult{Prompts: []Prompt{}}
+	g.each(hasPrompts, func(server string, client *Client) error {
+		prompts, err := client.ListPrompts(ctx)
+		for _, prompt := range prompts {
+			prompt.Name = server + "." + prompt.Name
+			result.Prompts = append(result.Prompts, prompt)
+		}
+		return err
+	})
+	return result, nil
+}
+
+func (g *Gateway) getPrompt(ctx context.Context, params json.RawMessage) (any, error) {
+	var get getPromptParams
+	if err := json.Unmarshal(params, &get); err != nil {
+		return nil, &Error{Code: CodeInvalidParams, Message: "invalid prompts/get parameters"}
+	}
+	client, name, err := g.route(get.Name)
+	if err != nil {
+		return nil, err
+	}
+	return client.GetPrompt(ctx, name, get.Arguments)
+}
+
+// setLevel sets the logging level of every server that logs
+func (g *Gateway) setLevel(ctx context.Context, params json.RawMessage) (any, error) {
+	var level setLevelParams
+	if err := json.Unmarshal(params, &level); err != nil {
+		return nil, &Error{Code: CodeInvalidParams, Message: "invalid logging/setLevel parameters"}
+	}
+	var errs []error
+	g.each(hasLogging, func(server string, client *Client) error {
+		if err := client.SetLoggingLevel(ctx, level.Level); err != nil {
+			errs = append(errs, err)
+		}
+		return nil
+	})
+	return struct{}{}, errors.Join(errs...)
+}
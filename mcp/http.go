@@ -0,0 +1,279 @@
+package mcp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// Headers of the streamable HTTP transport
+const (
+	sessionHeader         = "Mcp-Session-Id"
+	protocolVersionHeader = "MCP-Protocol-Version"
+)
+
+// HTTPTransport implements the streamable HTTP transport: every message is POSTed to the
+// endpoint, which answers with JSON or with an SSE stream of messages
+type HTTPTransport struct {
+	endpoint string
+	client   *http.Client
+	inbox    *inbox
+
+	mu              sync.Mutex
+	sessionID       string
+	protocolVersion string
+	ctx             context.Context
+	cancel          context.CancelFunc
+}
+
+// NewHTTPTransport connects to the streamable HTTP endpoint at endpoint, e.g. http://localhost:8000/mcp
+func NewHTTPTransport(endpoint string) *HTTPTransport {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &HTTPTransport{endpoint: endpoint, client: http.DefaultClient, inbox: newInbox(), ctx: ctx, cancel: cancel}
+}
+
+func (t *HTTPTransport) setProtocolVersion(version string) {
+	t.mu.Lock()
+	t.protocolVersion = version
+	t.mu.Unlock()
+}
+
+func (t *HTTPTransport) Send(ctx context.Context, message *Message) error {
+	data, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.endpoint, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json, text/event-stream")
+	t.setHeaders(req)
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return err
+	}
+	if id := resp.Header.Get(sessionHeader); id != "" {
+		t.mu.Lock()
+		t.sessionID = id
+		t.mu.Unlock()
+	}
+
+	if resp.StatusCode == http.StatusAccepted {
+		resp.Body.Close()
+		return nil
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("server answered %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mediaType != "text/event-stream" {
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return err
+		}
+		t.inbox.deliverData(body)
+		return nil
+	}
+
+	// The stream carries the response and any requests or notifications before it; it is
+	// read in the background so Send returns as soon as the server accepted the message
+	go func() {
+		defer resp.Body.Close()
+		readEvents(resp.Body, func(event, data string) bool {
+			if event == "" || event == "message" {
+				t.inbox.deliverData([]byte(data))
+			}
+			return true
+		})
+	}()
+	return nil
+}
+
+func (t *HTTPTransport) setHeaders(req *http.Request) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.sessionID != "" {
+		req.Header.Set(sessionHeader, t.sessionID)
+	}
+	if t.protocolVersion != "" {
+		req.Header.Set(protocolVersionHeader, t.protocolVersion)
+	}
+}
+
+func (t *HTTPTransport) Receive() (*Message, error) {
+	return t.inbox.receive()
+}
+
+// Close ends the session on the server, when it issued one
+func (t *HTTPTransport) Close() error {
+	t.mu.Lock()
+	sessionID := t.sessionID
+	t.mu.Unlock()
+	if sessionID != "" {
+		if req, err := http.NewRequestWithContext(t.ctx, http.MethodDelete, t.endpoint, nil); err == nil {
+			t.setHeaders(req)
+			if resp, err := t.client.Do(req); err == nil {
+				resp.Body.Close()
+			}
+		}
+	}
+	t.cancel()
+	t.inbox.close(nil)
+	return nil
+}
+
+// SSETransport implements the HTTP+SSE transport of protocol version 2024-11-05: the server
+// streams messages on a GET connection whose first "endpoint" event names the URL that
+// client messages are POSTed to
+type SSETransport struct {
+	client   *http.Client
+	inbox    *inbox
+	endpoint string
+	cancel   context.CancelFunc
+}
+
+// NewSSETransport opens the event stream at streamURL, e.g. http://localhost:8000/sse, and
+// waits for the server to announce its message endpoint
+func NewSSETransport(ctx context.Context, streamURL string) (*SSETransport, error) {
+	base, err := url.Parse(streamURL)
+	if err != nil {
+		return nil, err
+	}
+
+	streamCtx, cancel := context.WithCancel(context.Background())
+	req, err := http.NewRequestWithContext(streamCtx, http.MethodGet, streamURL, nil)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+
+	t := &SSETransport{client: http.DefaultClient, inbox: newInbox(), cancel: cancel}
+	resp, err := t.client.Do(req)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		cancel()
+		return nil, fmt.Errorf("server answered %s", resp.Status)
+	}
+
+	endpoint := make(chan string, 1)
+	go func() {
+		defer resp.Body.Close()
+		err := readEvents(resp.Body, func(event, data string) bool {
+			switch event {
+			case "endpoint":
+				if ref, err := base.Parse(strings.TrimSpace(data)); err == nil {
+					select {
+					case endpoint <- ref.String():
+					default:
+					}
+				}
+			case "", "message":
+				t.inbox.deliverData([]byte(data))
+			}
+			return true
+		})
+		t.inbox.close(err)
+	}()
+
+	select {
+	case t.endpoint = <-endpoint:
+		return t, nil
+	case <-t.inbox.done:
+		cancel()
+		return nil, fmt.Errorf("event stream ended before the server announced its endpoint: %w", t.inbox.err)
+	case <-ctx.Done():
+		cancel()
+		return nil, ctx.Err()
+	}
+}
+
+func (t *SSETransport) Send(ctx context.Context, message *Message) error {
+	data, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.endpoint, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("server answered %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+func (t *SSETransport) Receive() (*Message, error) {
+	return t.inbox.receive()
+}
+
+func (t *SSETransport) Close() error {
+	t.cancel()
+	t.inbox.close(nil)
+	return nil
+}
+
+// readEvents parses a server-sent event stream, calling handle for every event until it
+// returns false or the stream ends
+func readEvents(r io.Reader, handle func(event, data string) bool) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+
+	var event string
+	var data []string
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+			if len(data) > 0 && !handle(event, strings.Join(data, "\n")) {
+				return nil
+			}
+			event, data = "", nil
+		case strings.HasPrefix(line, ":"):
+			// Comment, used as keep-alive
+		default:
+			field, value, _ := strings.Cut(line, ":")
+			value = strings.TrimPrefix(value, " ")
+			switch field {
+			case "event":
+				event = value
+			case "data":
+				data = append(data, value)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	if len(data) > 0 {
+		handle(event, strings.Join(data, "\n"))
+	}
+	return ErrClosed
+}
@@ -0,0 +1,65 @@
+package mcp
+
+import (
+	"context"
+	"errors"
+)
+
+// Introspection is what a server reports about itself: its identity and the tools,
+// resources and prompts it offers
+type Introspection struct {
+	ProtocolVersion   string             `json:"protocol_version"`
+	ServerInfo        Implementation     `json:"server_info"`
+	Instructions      string             `json:"instructions,omitempty"`
+	Capabilities      ServerCapabilities `json:"capabilities"`
+	Tools             []Tool             `json:"tools,omitempty"`
+	Resources         []Resource         `json:"resources,omitempty"`
+	ResourceTemplates []ResourceTemplate `json:"resource_templates,omitempty"`
+	Prompts           []Prompt           `json:"prompts,omitempty"`
+}
+
+// Introspect lists everything an initialized server declares. Lists of capabilities the
+// server did not declare are skipped; servers that declare them but answer "method not
+// found" yield empty lists.
+func Introspect(ctx context.Context, client *Client) (*Introspection, error) {
+	initialized := client.InitializeResult()
+	if initialized == nil {
+		return nil, errors.New("the client is not initialized")
+	}
+
+	result := &Introspection{
+		ProtocolVersion: initialized.ProtocolVersion,
+		ServerInfo:      initialized.ServerInfo,
+		Instructions:    initialized.Instructions,
+		Capabilities:    initialized.Capabilities,
+	}
+
+	var err error
+	if initialized.Capabilities.Tools != nil {
+		if result.Tools, err = client.ListTools(ctx); ignoreMethodNotFound(err) != nil {
+			return nil, err
+		}
+	}
+	if initialized.Capabilities.Resources != nil {
+		if result.Resources, err = client.ListResources(ctx); ignoreMethodNotFound(err) != nil {
+			return nil, err
+		}
+		if result.ResourceTemplates, err = client.ListResourceTemplates(ctx); ignoreMethodNotFound(err) != nil {
+			return nil, err
+		}
+	}
+	if initialized.Capabilities.Prompts != nil {
+		if result.Prompts, err = client.ListPrompts(ctx); ignoreMethodNotFound(err) != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+func ignoreMethodNotFound(err error) error {
+	var rpcErr *Error
+	if errors.As(err, &rpcErr) && rpcErr.Code == CodeMethodNotFound {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,911 @@
+package mcp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// handler answers one request method of the test server
+type handler func(params json.RawMessage) (any, *Error)
+
+// testServer is an MCP server speaking over an in-memory stdio connection
+type testServer struct {
+	handlers map[string]handler
+	out      io.WriteCloser
+	mu       sync.Mutex
+	// received are the notifications and responses sent by the client
+	received chan *Message
+}
+
+func newTestServer(handlers map[string]handler) *testServer {
+	return &testServer{handlers: handlers, received: make(chan *Message, 16)}
+}
+
+// connect starts serving and returns the client side of the connection, closed with the test
+func (s *testServer) connect(t *testing.T) *Client {
+	t.Helper()
+	client := NewClient(s.transport())
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+// transport starts serving and returns the client side of the connection
+func (s *testServer) transport() Transport {
+	serverIn, clientOut := io.Pipe()
+	clientIn, serverOut := io.Pipe()
+	s.out = serverOut
+
+	go func() {
+		defer serverOut.Close()
+		reader := bufio.NewReader(serverIn)
+		for {
+			line, err := reader.ReadBytes('\n')
+			if err != nil {
+				return
+			}
+			var message Message
+			if json.Unmarshal(line, &message) != nil {
+				continue
+			}
+			if !message.IsRequest() {
+				s.received <- &message
+				continue
+			}
+			go s.serve(&message)
+		}
+	}()
+
+	return NewStdioTransport(clientIn, clientOut, nil)
+}
+
+func (s *testServer) serve(request *Message) {
+	response := &Message{JSONRPC: "2.0", ID: request.ID}
+	if handle, ok := s.handlers[request.Method]; ok {
+		result, rpcErr := handle(request.Params)
+		if rpcErr != nil {
+			response.Error = rpcErr
+		} else {
+			response.Result, _ = json.Marshal(result)
+		}
+	} else {
+		response.Error = &Error{Code: CodeMethodNotFound, Message: "method not found"}
+	}
+	s.send(response)
+}
+
+func (s *testServer) send(message *Message) {
+	data, _ := json.Marshal(message)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.out.Write(append(data, '\n'))
+}
+
+// next returns the next notification or response from the client with method (any response when "")
+func (s *testServer) next(t *testing.T, method string) *Message {
+	t.Helper()
+	for {
+		select {
+		case message := <-s.received:
+			if message.Method == method {
+				return message
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("the client sent no %q", method)
+			return nil
+		}
+	}
+}
+
+func initializeHandler(capabilities ServerCapabilities) handler {
+	return func(params json.RawMessage) (any, *Error) {
+		return InitializeResult{
+			ProtocolVersion: LatestProtocolVersion,
+			Capabilities:    capabilities,
+			ServerInfo:      Implementation{Name: "test", Version: "1.2.3"},
+		}, nil
+	}
+}
+
+func testContext(t *testing.T) context.Context {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	t.Cleanup(cancel)
+	return ctx
+}
+
+func TestIntrospect(t *testing.T) {
+	client := newTestServer(map[string]handler{
+		"initialize": initializeHandler(ServerCapabilities{Tools: &Capability{}, Prompts: &Capability{}}),
+		"tools/list": func(params json.RawMessage) (any, *Error) {
+			var page paginatedParams
+			json.Unmarshal(params, &page)
+			if page.Cursor == "" {
+				return listToolsResult{Tools: []Tool{{Name: "create_issue", InputSchema: json.RawMessage(`{"type":"object"}`)}}, NextCursor: "2"}, nil
+			}
+			return listToolsResult{Tools: []Tool{{Name: "close_issue", InputSchema: json.RawMessage(`{"type":"object"}`)}}}, nil
+		},
+	}).connect(t)
+	ctx := testContext(t)
+
+	info, err := client.Initialize(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, "test", info.ServerInfo.Name)
+
+	introspection, err := Introspect(ctx, client)
+	assert.NoError(t, err)
+	assert.Equal(t, "1.2.3", introspection.ServerInfo.Version)
+	if assert.Len(t, introspection.Tools, 2) {
+		assert.Equal(t, "create_issue", introspection.Tools[0].Name)
+		assert.Equal(t, "close_issue", introspection.Tools[1].Name)
+	}
+	// Declared but not implemented: an empty list rather than an error
+	assert.Empty(t, introspection.Prompts)
+	// Not declared: never asked for
+	assert.Nil(t, introspection.Resources)
+}
+
+func TestInitializeRejectsUnknownProtocolVersion(t *testing.T) {
+	client := newTestServer(map[string]handler{
+		"initialize": func(params json.RawMessage) (any, *Error) {
+			return InitializeResult{ProtocolVersion: "1999-01-01"}, nil
+		},
+	}).connect(t)
+	_, err := client.Initialize(testContext(t))
+	assert.ErrorContains(t, err, "unsupported protocol version")
+}
+
+func TestClientCalls(t *testing.T) {
+	server := newTestServer(map[string]handler{
+		"initialize": initializeHandler(ServerCapabilities{Tools: &Capability{}, Resources: &Capability{}}),
+		"tools/call": func(params json.RawMessage) (any, *Error) {
+			var call callToolParams
+			json.Unmarshal(params, &call)
+			if call.Name != "echo" {
+				return nil, &Error{Code: CodeInvalidParams, Message: "unknown tool " + call.Name}
+			}
+			return CallToolResult{Content: []Content{{Type: "text", Text: string(call.Arguments)}}}, nil
+		},
+		"resources/read": func(params json.RawMessage) (any, *Error) {
+			var read resourceParams
+			json.Unmarshal(params, &read)
+			return readResourceResult{Contents: []ResourceContents{{URI: read.URI, Text: "hello"}}}, nil
+		},
+	})
+	client := server.connect(t)
+	ctx := testContext(t)
+
+	_, err := client.Initialize(ctx)
+	assert.NoError(t, err)
+	assert.NotNil(t, server.next(t, "notifications/initialized"))
+
+	result, err := client.CallTool(ctx, "echo", json.RawMessage(`{"text":"hi"}`))
+	assert.NoError(t, err)
+	assert.Equal(t, []Content{{Type: "text", Text: `{"text":"hi"}`}}, result.Content)
+
+	_, err = client.CallTool(ctx, "missing", nil)
+	var rpcErr *Error
+	assert.True(t, errors.As(err, &rpcErr))
+	assert.Equal(t, CodeInvalidParams, rpcErr.Code)
+
+	contents, err := client.ReadResource(ctx, "file:///a.txt")
+	assert.NoError(t, err)
+	assert.Equal(t, "hello", contents[0].Text)
+
+	// Capabilities the server did not declare are refused without asking it
+	_, err = client.GetPrompt(ctx, "summarize", nil)
+	assert.ErrorIs(t, err, ErrNotSupported)
+	assert.ErrorIs(t, client.SetLoggingLevel(ctx, "debug"), ErrNotSupported)
+}
+
+func TestClientRepeatedCursor(t *testing.T) {
+	// The server sends its clients back to the first page
+	next := map[string]string{"": "a", "a": "b", "b": "a"}
+	client := newTestServer(map[string]handler{
+		"initialize": initializeHandler(ServerCapabilities{Tools: &Capability{}}),
+		"tools/list": func(params json.RawMessage) (any, *Error) {
+			var page paginatedParams
+			json.Unmarshal(params, &page)
+			return listToolsResult{Tools: []Tool{{Name: "tool-" + page.Cursor}}, NextCursor: next[page.Cursor]}, nil
+		},
+	}).connect(t)
+	ctx := testContext(t)
+
+	_, err := client.Initialize(ctx)
+	assert.NoError(t, err)
+	_, err = client.ListTools(ctx)
+	assert.EqualError(t, err, `tools/list returned the cursor "a" twice`)
+}
+
+func TestClientNotificationsAndServerRequests(t *testing.T) {
+	server := newTestServer(nil)
+	var declared initializeParams
+	server.handlers = map[string]handler{
+		"initialize": func(params json.RawMessage) (any, *Error) {
+			json.Unmarshal(params, &declared)
+			return initializeHandler(ServerCapabilities{Logging: &Capability{}})(params)
+		},
+		"logging/setLevel": func(params json.RawMessage) (any, *Error) {
+			server.send(&Message{JSONRPC: "2.0", Method: NotificationMessage, Params: json.RawMessage(`{"level":"info","data":"ready"}`)})
+			return struct{}{}, nil
+		},
+	}
+	client := server.connect(t)
+	ctx := testContext(t)
+
+	logs := make(chan LoggingMessage, 1)
+	client.OnNotification(func(method string, params json.RawMessage) {
+		if method == NotificationMessage {
+			var message LoggingMessage
+			json.Unmarshal(params, &message)
+			logs <- message
+		}
+	})
+	client.SetRoots([]Root{{URI: "file:///work", Name: "work"}})
+
+	_, err := client.Initialize(ctx)
+	assert.NoError(t, err)
+	assert.NotNil(t, declared.Capabilities.Roots)
+	assert.Nil(t, declared.Capabilities.Sampling)
+
+	assert.NoError(t, client.SetLoggingLevel(ctx, "info"))
+	select {
+	case message := <-logs:
+		assert.Equal(t, `"ready"`, string(message.Data))
+	case <-time.After(5 * time.Second):
+		t.Fatal("no log notification")
+	}
+
+	// The server asks for the client's roots and gets them
+	server.send(&Message{JSONRPC: "2.0", ID: json.RawMessage(`"r1"`), Method: "roots/list"})
+	response := server.next(t, "")
+	assert.Equal(t, `"r1"`, string(response.ID))
+	assert.JSONEq(t, `{"roots":[{"uri":"file:///work","name":"work"}]}`, string(response.Result))
+
+	// Unknown requests are answered with method not found
+	server.send(&Message{JSONRPC: "2.0", ID: json.RawMessage(`2`), Method: "sampling/createMessage"})
+	response = server.next(t, "")
+	if assert.NotNil(t, response.Error) {
+		assert.Equal(t, CodeMethodNotFound, response.Error.Code)
+	}
+}
+
+func TestClientCancellation(t *testing.T) {
+	release := make(chan struct{})
+	server := newTestServer(map[string]handler{
+		"initialize": initializeHandler(ServerCapabilities{Tools: &Capability{}}),
+		"tools/call": func(params json.RawMessage) (any, *Error) {
+			<-release
+			return CallToolResult{}, nil
+		},
+	})
+	defer close(release)
+	client := server.connect(t)
+
+	_, err := client.Initialize(testContext(t))
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = client.CallTool(ctx, "slow", nil)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	cancelled := server.next(t, NotificationCancelled)
+	var params cancelledParams
+	assert.NoError(t, json.Unmarshal(cancelled.Params, &params))
+	assert.Equal(t, "2", string(params.RequestID))
+}
+
+func TestHTTPClientCancellation(t *testing.T) {
+	release := make(chan struct{})
+	cancelled := make(chan Message, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var message Message
+		json.NewDecoder(r.Body).Decode(&message)
+		switch message.Method {
+		case "tools/call":
+			// The response is held back until the test ends
+			<-release
+		case NotificationCancelled:
+			cancelled <- message
+			w.WriteHeader(http.StatusAccepted)
+		default:
+			w.WriteHeader(http.StatusAccepted)
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	client := NewClient(NewHTTPTransport(server.URL + "/mcp"))
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := client.CallTool(ctx, "slow", nil)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	select {
+	case message := <-cancelled:
+		var params cancelledParams
+		assert.NoError(t, json.Unmarshal(message.Params, &params))
+		assert.Equal(t, "1", string(params.RequestID))
+	case <-time.After(5 * time.Second):
+		t.Fatal("no notifications/cancelled was sent")
+	}
+}
+
+func TestStdioSendCancellation(t *testing.T) {
+	// The server never reads its stdin, so every write blocks
+	_, clientOut := io.Pipe()
+	clientIn, serverOut := io.Pipe()
+	defer serverOut.Close()
+	client := NewClient(NewStdioTransport(clientIn, clientOut, nil))
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := client.CallTool(ctx, "slow", nil)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	// The connection was given up instead of leaving later requests stuck behind the write
+	err = client.Ping(testContext(t))
+	assert.Error(t, err)
+	assert.NotErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestHTTPTransport(t *testing.T) {
+	var mu sync.Mutex
+	var headers []http.Header
+	deleted := false
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		headers = append(headers, r.Header.Clone())
+		if r.Method == http.MethodDelete {
+			deleted = true
+		}
+		mu.Unlock()
+		if r.Method == http.MethodDelete {
+			return
+		}
+
+		var message Message
+		json.NewDecoder(r.Body).Decode(&message)
+		switch message.Method {
+		case "initialize":
+			w.Header().Set(sessionHeader, "session-1")
+			w.Header().Set("Content-Type", "application/json")
+			result, _ := json.Marshal(InitializeResult{ProtocolVersion: LatestProtocolVersion, Capabilities: ServerCapabilities{Tools: &Capability{}}})
+			json.NewEncoder(w).Encode(Message{JSONRPC: "2.0", ID: message.ID, Result: result})
+		case "tools/list":
+			// Streamed answer with a notification before the response
+			w.Header().Set("Content-Type", "text/event-stream")
+			fmt.Fprintf(w, "event: message\ndata: %s\n\n", `{"jsonrpc":"2.0","method":"notifications/progress","params":{"progressToken":1,"progress":1}}`)
+			fmt.Fprintf(w, "event: message\ndata: {\"jsonrpc\":\"2.0\",\"id\":%s,\"result\":{\"tools\":[{\"name\":\"a\",\"inputSchema\":{}}]}}\n\n", message.ID)
+		default:
+			w.WriteHeader(http.StatusAccepted)
+		}
+	}))
+	defer server.Close()
+
+	client := NewClient(NewHTTPTransport(server.URL + "/mcp"))
+	progress := make(chan string, 1)
+	client.OnNotification(func(method string, params json.RawMessage) { progress <- method })
+	ctx := testContext(t)
+
+	_, err := client.Initialize(ctx)
+	assert.NoError(t, err)
+	tools, err := client.ListTools(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, tools, 1)
+	assert.Equal(t, NotificationProgress, <-progress)
+	client.Close()
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.True(t, deleted)
+	// The session and protocol version are sent once known
+	assert.Empty(t, headers[0].Get(sessionHeader))
+	last := headers[len(headers)-1]
+	assert.Equal(t, "session-1", last.Get(sessionHeader))
+	assert.Equal(t, LatestProtocolVersion, last.Get(protocolVersionHeader))
+}
+
+func TestSSETransport(t *testing.T) {
+	messages := make(chan []byte, 4)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			w.Header().Set("Content-Type", "text/event-stream")
+			fmt.Fprint(w, "event: endpoint\ndata: /messages?session=1\n\n")
+			w.(http.Flusher).Flush()
+			for {
+				select {
+				case data := <-messages:
+					fmt.Fprintf(w, "event: message\ndata: %s\n\n", data)
+					w.(http.Flusher).Flush()
+				case <-r.Context().Done():
+					return
+				}
+			}
+		case http.MethodPost:
+			assert.Equal(t, "/messages", r.URL.Path)
+			var message Message
+			json.NewDecoder(r.Body).Decode(&message)
+			if message.Method == "initialize" {
+				result, _ := json.Marshal(InitializeResult{ProtocolVersion: "2024-11-05"})
+				data, _ := json.Marshal(Message{JSONRPC: "2.0", ID: message.ID, Result: result})
+				messages <- data
+			}
+			w.WriteHeader(http.StatusAccepted)
+		}
+	}))
+	defer server.Close()
+
+	ctx := testContext(t)
+	transport, err := NewSSETransport(ctx, server.URL+"/sse")
+	if !assert.NoError(t, err) {
+		return
+	}
+	client := NewClient(transport)
+	defer client.Close()
+
+	info, err := client.Initialize(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, "2024-11-05", info.ProtocolVersion)
+}
+
+func TestReadEvents(t *testing.T) {
+	stream := "event: endpoint\ndata: /messages?session=1\n\n: keep-alive\n\ndata: {\"a\":\ndata: 1}\n\n"
+	var events []string
+	err := readEvents(strings.NewReader(stream), func(event, data string) bool {
+		events = append(events, event+"|"+data)
+		return true
+	})
+	assert.ErrorIs(t, err, ErrClosed)
+	assert.Equal(t, []string{"endpoint|/messages?session=1", "|{\"a\":\n1}"}, events)
+}
+
+func TestSchemaParseValue(t *testing.T) {
+	schema, err := ParseSchema(json.RawMessage(`{
+		"type": "object",
+		"properties": {
+			"title": {"type": "string"},
+			"count": {"type": "integer"},
+			"ratio": {"type": ["number", "null"]},
+			"draft": {"type": "boolean"},
+			"labels": {"type": "array", "items": {"type": "string"}},
+			"state": {"type": "string", "enum": ["open", "closed"]},
+			"extra": {}
+		},
+		"required": ["title", "count"]
+	}`))
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, []string{"count", "title", "draft", "extra", "labels", "ratio", "state"}, schema.PropertyNames())
+	assert.Equal(t, "number", schema.Properties["ratio"].Kind())
+
+	cases := []struct {
+		property string
+		input    string
+		want     any
+	}{
+		{"title", "42", "42"},
+		{"count", "42", int64(42)},
+		{"ratio", "0.5", 0.5},
+		{"draft", "yes", true},
+		{"labels", "bug, ui", []any{"bug", "ui"}},
+		{"labels", `["a,b"]`, []any{"a,b"}},
+		{"state", "open", "open"},
+		{"extra", `{"a": 1}`, map[string]any{"a": float64(1)}},
+		{"extra", "plain text", "plain text"},
+	}
+	for _, c := range cases {
+		value, err := schema.Properties[c.property].ParseValue(c.input)
+		assert.NoError(t, err, c.property)
+		assert.Equal(t, c.want, value, c.property)
+	}
+
+	for property, input := range map[string]string{"count": "4.5", "draft": "maybe", "state": "merged", "labels": `{"a": 1}`} {
+		_, err := schema.Properties[property].ParseValue(input)
+		assert.Error(t, err, property)
+	}
+	assert.Equal(t, "open, closed", schema.Properties["state"].EnumValues())
+}
+
+func TestBridge(t *testing.T) {
+	var mu sync.Mutex
+	started := 0
+	bridge := NewBridge(func(ctx context.Context) (Transport, error) {
+		mu.Lock()
+		started++
+		mu.Unlock()
+		server := newTestServer(nil)
+		server.handlers = map[string]handler{
+			"initialize": initializeHandler(ServerCapabilities{Tools: &Capability{}}),
+			"tools/list": func(params json.RawMessage) (any, *Error) {
+				// Streamed to the client ahead of the response
+				server.send(&Message{JSONRPC: "2.0", Method: NotificationMessage, Params: json.RawMessage(`{"level":"info","data":"listing"}`)})
+				return listToolsResult{Tools: []Tool{{Name: "echo", InputSchema: json.RawMessage(`{}`)}}}, nil
+			},
+		}
+		return server.transport(), nil
+	})
+	defer bridge.Close()
+	server := httptest.NewServer(bridge)
+	defer server.Close()
+	ctx := testContext(t)
+
+	// Streamable HTTP
+	client := NewClient(NewHTTPTransport(server.URL + BridgePath))
+	logs := make(chan string, 1)
+	client.OnNotification(func(method string, params json.RawMessage) { logs <- method })
+	_, err := client.Initialize(ctx)
+	assert.NoError(t, err)
+	tools, err := client.ListTools(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, tools, 1)
+	assert.Equal(t, NotificationMessage, <-logs)
+
+	// Legacy SSE, with a server of its own
+	sse, err := NewSSETransport(ctx, server.URL+BridgeSSEPath)
+	if !assert.NoError(t, err) {
+		return
+	}
+	sseClient := NewClient(sse)
+	_, err = sseClient.Initialize(ctx)
+	assert.NoError(t, err)
+	tools, err = sseClient.ListTools(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, tools, 1)
+	assert.Equal(t, 2, bridge.Sessions())
+
+	mu.Lock()
+	assert.Equal(t, 2, started)
+	mu.Unlock()
+
+	// Closing the client deletes its session
+	client.Close()
+	assert.Eventually(t, func() bool { return bridge.Sessions() == 1 }, time.Second, 10*time.Millisecond)
+	sseClient.Close()
+	assert.Eventually(t, func() bool { return bridge.Sessions() == 0 }, time.Second, 10*time.Millisecond)
+
+	// Requests need a known session, except initialize
+	req, _ := http.NewRequest(http.MethodPost, server.URL+BridgePath, strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"ping"}`))
+	req.Header.Set(sessionHeader, "gone")
+	resp, err := http.DefaultClient.Do(req)
+	if assert.NoError(t, err) {
+		resp.Body.Close()
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	}
+	resp, err = http.Post(server.URL+BridgePath, "application/json", strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"ping"}`))
+	if assert.NoError(t, err) {
+		resp.Body.Close()
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	}
+}
+
+func TestBridgeMaxSessions(t *testing.T) {
+	gate := make(chan struct{})
+	var started atomic.Int32
+	var fail atomic.Bool
+	bridge := NewBridge(func(ctx context.Context) (Transport, error) {
+		started.Add(1)
+		if fail.Load() {
+			return nil, fmt.Errorf("no server")
+		}
+		<-gate
+		server := newTestServer(map[string]handler{"initialize": initializeHandler(ServerCapabilities{})})
+		return server.transport(), nil
+	})
+	bridge.MaxSessions = 1
+	defer bridge.Close()
+	server := httptest.NewServer(bridge)
+	defer server.Close()
+
+	initialize := func() (int, string) {
+		resp, err := http.Post(server.URL+BridgePath, "application/json", strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{}}`))
+		if !assert.NoError(t, err) {
+			return 0, ""
+		}
+		resp.Body.Close()
+		return resp.StatusCode, resp.Header.Get(sessionHeader)
+	}
+
+	// While the first server starts, its slot is taken
+	first := make(chan string, 1)
+	go func() {
+		status, session := initialize()
+		assert.Equal(t, http.StatusOK, status)
+		first <- session
+	}()
+	assert.Eventually(t, func() bool { return started.Load() == 1 }, time.Second, 10*time.Millisecond)
+	status, _ := initialize()
+	assert.Equal(t, http.StatusServiceUnavailable, status)
+	close(gate)
+	session := <-first
+	assert.Equal(t, int32(1), started.Load())
+
+	req, _ := http.NewRequest(http.MethodDelete, server.URL+BridgePath, nil)
+	req.Header.Set(sessionHeader, session)
+	resp, err := http.DefaultClient.Do(req)
+	if assert.NoError(t, err) {
+		resp.Body.Close()
+	}
+
+	// A server that fails to start gives its slot back
+	fail.Store(true)
+	status, _ = initialize()
+	assert.Equal(t, http.StatusBadGateway, status)
+	status, _ = initialize()
+	assert.Equal(t, http.StatusBadGateway, status)
+	assert.Equal(t, int32(3), started.Load())
+}
+
+func TestGateway(t *testing.T) {
+	backend := func(name string, capabilities ServerCapabilities) *Client {
+		server := newTestServer(map[string]handler{
+			"initialize": initializeHandler(capabilities),
+			"tools/list": func(params json.RawMessage) (any, *Error) {
+				return listToolsResult{Tools: []Tool{{Name: "echo", InputSchema: json.RawMessage(`{}`)}}}, nil
+			},
+			"tools/call": func(params json.RawMessage) (any, *Error) {
+				var call callToolParams
+				json.Unmarshal(params, &call)
+				return CallToolResult{Content: []Content{{Type: "text", Text: name + ":" + call.Name}}}, nil
+			},
+			"resources/list": func(params json.RawMessage) (any, *Error) {
+				return listResourcesResult{Resources: []Resource{{URI: "file:///" + name, Name: "file"}}}, nil
+			},
+			"resources/read": func(params json.RawMessage) (any, *Error) {
+				var read resourceParams
+				json.Unmarshal(params, &read)
+				return readResourceResult{Contents: []ResourceContents{{URI: read.URI, Text: name}}}, nil
+			},
+		})
+		client := server.connect(t)
+		_, err := client.Initialize(testContext(t))
+		assert.NoError(t, err)
+		return client
+	}
+
+	gateway := NewGateway()
+	gateway.Add("alpha", backend("alpha", ServerCapabilities{Tools: &Capability{}}))
+	gateway.Add("beta.x", backend("beta.x", ServerCapabilities{Tools: &Capability{}, Resources: &Capability{}}))
+	assert.Equal(t, []string{"alpha", "beta.x"}, gateway.Servers())
+
+	// Served over stdio, as for clients that launch the gateway
+	serverIn, clientOut := io.Pipe()
+	clientIn, serverOut := io.Pipe()
+	go ServeStdio(gateway.Connect(), serverIn, serverOut)
+	client := NewClient(NewStdioTransport(clientIn, clientOut, nil))
+	defer client.Close()
+	changes := make(chan string, 8)
+	client.OnNotification(func(method string, params json.RawMessage) { changes <- method })
+	ctx := testContext(t)
+
+	info, err := client.Initialize(ctx)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, GatewayInfo, info.ServerInfo)
+
+	tools, err := client.ListTools(ctx)
+	assert.NoError(t, err)
+	var names []string
+	for _, tool := range tools {
+		names = append(names, tool.Name)
+	}
+	assert.Equal(t, []string{"alpha.echo", "beta.x.echo"}, names)
+
+	result, err := client.CallTool(ctx, "beta.x.echo", nil)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "beta.x:echo", result.Content[0].Text)
+	}
+	_, err = client.CallTool(ctx, "gamma.echo", nil)
+	var rpcErr *Error
+	assert.True(t, errors.As(err, &rpcErr) && rpcErr.Code == CodeInvalidParams)
+
+	// Only beta.x declares resources, and reads go to it
+	resources, err := client.ListResources(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, []Resource{{URI: "file:///beta.x", Name: "beta.x.file"}}, resources)
+	contents, err := client.ReadResource(ctx, "file:///beta.x")
+	if assert.NoError(t, err) {
+		assert.Equal(t, "beta.x", contents[0].Text)
+	}
+
+	// Removing a server changes the lists
+	gateway.Remove("alpha")
+	assert.Equal(t, NotificationToolsListChanged, <-changes)
+	tools, err = client.ListTools(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, tools, 1)
+}
+
+func TestCheckConformance(t *testing.T) {
+	failed := func(checks []ConformanceCheck) map[string][]string {
+		problems := map[string][]string{}
+		for _, check := range checks {
+			if !check.Passed() {
+				problems[check.Name] = check.Problems
+			}
+		}
+		return problems
+	}
+
+	server := NewServer(Implementation{Name: "good", Version: "1.0.0"}, ServerCapabilities{Tools: &Capability{}})
+	server.HandleRequest("tools/list", func(ctx context.Context, params json.RawMessage) (any, error) {
+		return listToolsResult{Tools: []Tool{{Name: "echo", InputSchema: json.RawMessage(`{"type": "object", "properties": {"text": {"type": "string"}}}`)}}}, nil
+	})
+	server.HandleRequest("tools/call", func(ctx context.Context, params json.RawMessage) (any, error) {
+		var call callToolParams
+		if json.Unmarshal(params, &call) != nil || call.Name != "echo" {
+			return nil, &Error{Code: CodeInvalidParams, Message: "unknown tool"}
+		}
+		return CallToolResult{}, nil
+	})
+	client := NewClient(server.Connect())
+	defer client.Close()
+	_, err := client.Initialize(testContext(t))
+	assert.NoError(t, err)
+	checks := CheckConformance(testContext(t), client)
+	assert.Len(t, checks, 6)
+	assert.Empty(t, failed(checks))
+	assert.True(t, ConformancePassed(checks))
+
+	bad := newTestServer(map[string]handler{
+		"initialize": func(params json.RawMessage) (any, *Error) {
+			return InitializeResult{
+				ProtocolVersion: LatestProtocolVersion,
+				Capabilities:    ServerCapabilities{Tools: &Capability{}, Logging: &Capability{}},
+				ServerInfo:      Implementation{Name: "bad"},
+			}, nil
+		},
+		"tools/list": func(params json.RawMessage) (any, *Error) {
+			return listToolsResult{Tools: []Tool{
+				{Name: "search", InputSchema: json.RawMessage(`{"type": "object", "properties": {"query": {"type": "text"}}, "required": "query"}`)},
+				{Name: "search", InputSchema: json.RawMessage(`{"type": "string"}`)},
+			}}, nil
+		},
+		"prompts/list": func(params json.RawMessage) (any, *Error) {
+			return listPromptsResult{Prompts: []Prompt{{Name: "greet"}}}, nil
+		},
+		"tools/call": func(params json.RawMessage) (any, *Error) {
+			return CallToolResult{IsError: true}, nil
+		},
+		"mcphub/conformance/unknown": func(params json.RawMessage) (any, *Error) {
+			return nil, &Error{Code: CodeInternalError, Message: "oops"}
+		},
+	})
+	client = bad.connect(t)
+	_, err = client.Initialize(testContext(t))
+	assert.NoError(t, err)
+	checks = CheckConformance(testContext(t), client)
+	assert.False(t, ConformancePassed(checks))
+	problems := failed(checks)
+	// The test server ignores cancellation, which is only caught when it answers late
+	delete(problems, "cancellation")
+	assert.Equal(t, map[string][]string{
+		HandshakeCheck: {"serverInfo.version is missing from the initialize result"},
+		"ping":         {"ping: method not found (code -32601)"},
+		"capability honesty": {
+			"prompts/list lists prompts but the prompts capability is not declared",
+			"declares logging but logging/setLevel fails: method not found (code -32601)",
+		},
+		"tool schemas": {
+			"search inputSchema: properties.query.type \"text\" is not a JSON Schema type",
+			"search inputSchema: required must be a list of property names",
+			"search is listed more than once",
+			"search inputSchema: type must be \"object\"",
+		},
+		"JSON-RPC errors": {
+			"an unknown method failed with code -32603 instead of -32601 (method not found)",
+			"tools/call without a tool name was answered with a result instead of an error",
+		},
+	}, problems)
+
+	// A server that still answers a request in flight after it was cancelled
+	release := make(chan struct{})
+	pings := 0
+	stubborn := newTestServer(map[string]handler{
+		"initialize": initializeHandler(ServerCapabilities{Tools: &Capability{}}),
+		"tools/list": func(params json.RawMessage) (any, *Error) {
+			<-release
+			return listToolsResult{}, nil
+		},
+		"ping": func(params json.RawMessage) (any, *Error) {
+			// The third ping follows the cancellation of tools/list and its first ping
+			if pings++; pings == 3 {
+				close(release)
+				time.Sleep(50 * time.Millisecond)
+			}
+			return struct{}{}, nil
+		},
+	})
+	client = stubborn.connect(t)
+	initialized, err := client.Initialize(testContext(t))
+	assert.NoError(t, err)
+	check := checkCancellation(testContext(t), client, initialized)
+	assert.Equal(t, []string{"answered tools/list (id 3) after it was cancelled"}, check.Problems)
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	tools := func(names ...string) handler {
+		return func(params json.RawMessage) (any, *Error) {
+			result := listToolsResult{}
+			for _, name := range names {
+				result.Tools = append(result.Tools, Tool{Name: name, InputSchema: json.RawMessage(`{"type":"object"}`)})
+			}
+			return result, nil
+		}
+	}
+	original := newTestServer(map[string]handler{
+		"initialize": initializeHandler(ServerCapabilities{Tools: &Capability{}}),
+		"tools/list": tools("echo"),
+	})
+
+	var recording bytes.Buffer
+	recorder := NewRecorder(&recording)
+	client := NewClient(recorder.Record(original.transport()))
+	client.SetRoots([]Root{{URI: "file:///work"}})
+	ctx := testContext(t)
+	_, err := client.Initialize(ctx)
+	assert.NoError(t, err)
+	_, err = client.ListTools(ctx)
+	assert.NoError(t, err)
+	original.send(&Message{JSONRPC: "2.0", ID: json.RawMessage(`"r1"`), Method: "roots/list"})
+	original.next(t, "")
+	client.Close()
+	assert.NoError(t, recorder.Err())
+	assert.Equal(t, 1, recorder.Sessions())
+
+	sessions, err := ReadRecording(&recording)
+	assert.NoError(t, err)
+	if !assert.Len(t, sessions, 1) {
+		return
+	}
+	session := sessions[0]
+	assert.Equal(t, FromClient, session[0].From)
+	assert.Equal(t, "initialize", session[0].Message.Method)
+	assert.Equal(t, FromServer, session[1].From)
+	assert.Equal(t, FromClient, session[len(session)-1].From)
+	assert.JSONEq(t, `{"roots":[{"uri":"file:///work"}]}`, string(session[len(session)-1].Message.Result))
+
+	// The new build lists another tool and asks for the roots, which are answered as recorded
+	var replayed *testServer
+	replayed = newTestServer(map[string]handler{
+		"initialize": initializeHandler(ServerCapabilities{Tools: &Capability{}}),
+		"tools/list": func(params json.RawMessage) (any, *Error) {
+			replayed.send(&Message{JSONRPC: "2.0", ID: json.RawMessage(`7`), Method: "roots/list"})
+			return tools("echo", "reverse")(params)
+		},
+	})
+	transport := replayed.transport()
+	defer transport.Close()
+	results := Replay(ctx, transport, session, 5*time.Second)
+	if assert.Len(t, results, 2) {
+		assert.Equal(t, "initialize", results[0].Request.Method)
+		assert.JSONEq(t, string(results[0].Recorded.Result), string(results[0].Replayed.Result))
+		assert.Equal(t, "tools/list", results[1].Request.Method)
+		assert.Contains(t, string(results[1].Replayed.Result), "reverse")
+		assert.NotContains(t, string(results[1].Recorded.Result), "reverse")
+	}
+	answer := replayed.next(t, "")
+	assert.Equal(t, `7`, string(answer.ID))
+	assert.JSONEq(t, `{"roots":[{"uri":"file:///work"}]}`, string(answer.Result))
+
+	_, err = ReadRecording(strings.NewReader("{\"session\":1}\n"))
+	assert.EqualError(t, err, "line 1 is not a recorded message")
+}
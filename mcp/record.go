@@ -0,0 +1,135 @@
+package mcp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Senders of recorded messages
+const (
+	FromClient = "client"
+	FromServer = "server"
+)
+
+// RecordedMessage is one message of a recorded session, stored as a line of a recording
+type RecordedMessage struct {
+	// Session numbers the sessions of a recording from 1
+	Session int `json:"session"`
+	// From is FromClient or FromServer
+	From    string    `json:"from"`
+	Time    time.Time `json:"time"`
+	Message *Message  `json:"message"`
+}
+
+// Recorder writes the messages of the sessions it records to a recording, one JSON object
+// per line, as they pass
+type Recorder struct {
+	mu       sync.Mutex
+	encoder  *json.Encoder
+	sessions int
+	err      error
+}
+
+// NewRecorder records sessions to w
+func NewRecorder(w io.Writer) *Recorder {
+	return &Recorder{encoder: json.NewEncoder(w)}
+}
+
+// Record wraps the transport to a server so the messages of its session are recorded
+func (r *Recorder) Record(transport Transport) Transport {
+	r.mu.Lock()
+	r.sessions++
+	session := r.sessions
+	r.mu.Unlock()
+	return &recordedTransport{recorder: r, session: session, transport: transport}
+}
+
+// Sessions returns the number of sessions recorded so far
+func (r *Recorder) Sessions() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.sessions
+}
+
+// Err returns the first error writing the recording
+func (r *Recorder) Err() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.err
+}
+
+func (r *Recorder) write(session int, from string, message *Message) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil {
+		return
+	}
+	if err := r.encoder.Encode(RecordedMessage{Session: session, From: from, Time: time.Now(), Message: message}); err != nil {
+		r.err = fmt.Errorf("failed to write the recording: %w", err)
+	}
+}
+
+// recordedTransport records the messages of one session on their way
+type recordedTransport struct {
+	recorder  *Recorder
+	session   int
+	transport Transport
+}
+
+func (t *recordedTransport) Send(ctx context.Context, message *Message) error {
+	t.recorder.write(t.session, FromClient, message)
+	return t.transport.Send(ctx, message)
+}
+
+func (t *recordedTransport) Receive() (*Message, error) {
+	message, err := t.transport.Receive()
+	if err == nil {
+		t.recorder.write(t.session, FromServer, message)
+	}
+	return message, err
+}
+
+func (t *recordedTransport) Close() error {
+	return t.transport.Close()
+}
+
+// ReadRecording reads a recording made by a Recorder and returns the messages of each
+// session in the order they passed, sessions in the order of their numbers
+func ReadRecording(r io.Reader) ([][]RecordedMessage, error) {
+	sessions := map[int][]RecordedMessage{}
+	reader := bufio.NewReaderSize(r, 64*1024)
+	for line := 1; ; line++ {
+		data, err := reader.ReadBytes('\n')
+		if len(bytes.TrimSpace(data)) > 0 {
+			var recorded RecordedMessage
+			if jsonErr := json.Unmarshal(data, &recorded); jsonErr != nil || recorded.Message == nil {
+				return nil, fmt.Errorf("line %d is not a recorded message", line)
+			}
+			sessions[recorded.Session] = append(sessions[recorded.Session], recorded)
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	numbers := make([]int, 0, len(sessions))
+	for number := range sessions {
+		numbers = append(numbers, number)
+	}
+	sort.Ints(numbers)
+	result := make([][]RecordedMessage, 0, len(numbers))
+	for _, number := range numbers {
+		result = append(result, sessions[number])
+	}
+	return result, nil
+}
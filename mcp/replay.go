@@ -0,0 +1,119 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ReplayedRequest is a recorded client request with the response it got in the recording
+// and the one it got when replayed
+type ReplayedRequest struct {
+	Request  *Message
+	Recorded *Message
+	// Replayed is nil when the server did not answer in time
+	Replayed *Message
+}
+
+// Replay sends the client messages of a recorded session to a server over transport, in the
+// recorded order. Each request answered in the recording waits up to timeout for its
+// response; requests left unanswered, such as cancelled ones, are sent without waiting and
+// not returned. Requests from the server are answered as the client answered them in the
+// recording, method by method in order.
+func Replay(ctx context.Context, transport Transport, session []RecordedMessage, timeout time.Duration) []ReplayedRequest {
+	recorded := map[string]*Message{}
+	answers := map[string][]*Message{}
+	serverRequests := map[string]string{}
+	for _, entry := range session {
+		message := entry.Message
+		switch {
+		case entry.From == FromServer && message.IsResponse():
+			recorded[string(message.ID)] = message
+		case entry.From == FromServer && message.IsRequest():
+			serverRequests[string(message.ID)] = message.Method
+		}
+	}
+	for _, entry := range session {
+		if method, ok := serverRequests[string(entry.Message.ID)]; ok && entry.From == FromClient && entry.Message.IsResponse() {
+			answers[method] = append(answers[method], entry.Message)
+		}
+	}
+
+	var mu sync.Mutex
+	waiting := map[string]chan *Message{}
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			message, err := transport.Receive()
+			if err != nil {
+				return
+			}
+			switch {
+			case message.IsRequest():
+				mu.Lock()
+				var answer *Message
+				if queued := answers[message.Method]; len(queued) > 0 {
+					answer, answers[message.Method] = queued[0], queued[1:]
+				}
+				mu.Unlock()
+				transport.Send(ctx, replayAnswer(message, answer))
+			case message.IsResponse():
+				mu.Lock()
+				response := waiting[string(message.ID)]
+				delete(waiting, string(message.ID))
+				mu.Unlock()
+				if response != nil {
+					response <- message
+				}
+			}
+		}
+	}()
+
+	var results []ReplayedRequest
+	for _, entry := range session {
+		message := entry.Message
+		if entry.From != FromClient || message.IsResponse() {
+			continue
+		}
+		answer := recorded[string(message.ID)]
+		if !message.IsRequest() || answer == nil {
+			transport.Send(ctx, message)
+			continue
+		}
+
+		response := make(chan *Message, 1)
+		mu.Lock()
+		waiting[string(message.ID)] = response
+		mu.Unlock()
+		result := ReplayedRequest{Request: message, Recorded: answer}
+		if err := transport.Send(ctx, message); err == nil {
+			select {
+			case result.Replayed = <-response:
+			case <-time.After(timeout):
+			case <-closed:
+			case <-ctx.Done():
+			}
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// replayAnswer answers a server request with the client's recorded answer. Without one,
+// pings are answered and other requests fail.
+func replayAnswer(request, recorded *Message) *Message {
+	answer := &Message{JSONRPC: "2.0", ID: request.ID}
+	switch {
+	case recorded != nil:
+		answer.Result = recorded.Result
+		answer.Error = recorded.Error
+	case request.Method == "ping":
+		answer.Result = json.RawMessage(`{}`)
+	default:
+		answer.Error = &Error{Code: CodeMethodNotFound, Message: fmt.Sprintf("the recording has no answer to %s", request.Method)}
+	}
+	return answer
+}
@@ -0,0 +1,306 @@
+package mcp
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Schema is the part of JSON Schema that describes tool and prompt inputs
+type Schema struct {
+	// Type is a type name or a list of them, such as ["string", "null"]
+	Type        json.RawMessage    `json:"type,omitempty"`
+	Description string             `json:"description,omitempty"`
+	Properties  map[string]*Schema `json:"properties,omitempty"`
+	Required    []string           `json:"required,omitempty"`
+	Enum        []json.RawMessage  `json:"enum,omitempty"`
+	Default     json.RawMessage    `json:"default,omitempty"`
+	Items       *Schema            `json:"items,omitempty"`
+}
+
+// ParseSchema decodes a JSON schema; an empty one accepts anything
+func ParseSchema(raw json.RawMessage) (*Schema, error) {
+	schema := &Schema{}
+	if len(raw) == 0 {
+		return schema, nil
+	}
+	if err := json.Unmarshal(raw, schema); err != nil {
+		return nil, fmt.Errorf("invalid schema: %w", err)
+	}
+	return schema, nil
+}
+
+// Kind is the first type of the schema other than null, or "" when it has none
+func (s *Schema) Kind() string {
+	var name string
+	if json.Unmarshal(s.Type, &name) == nil {
+		return name
+	}
+	var names []string
+	json.Unmarshal(s.Type, &names)
+	for _, name := range names {
+		if name != "null" {
+			return name
+		}
+	}
+	return ""
+}
+
+// IsRequired reports whether the object schema requires property
+func (s *Schema) IsRequired(property string) bool {
+	for _, name := range s.Required {
+		if name == property {
+			return true
+		}
+	}
+	return false
+}
+
+// PropertyNames lists the properties of an object schema, required ones first, each
+// group sorted by name
+func (s *Schema) PropertyNames() []string {
+	names := make([]string, 0, len(s.Properties))
+	for name := range s.Properties {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if s.IsRequired(names[i]) != s.IsRequired(names[j]) {
+			return s.IsRequired(names[i])
+		}
+		return names[i] < names[j]
+	})
+	return names
+}
+
+// ParseValue converts what a user typed into a value of the schema's type. Arrays take
+// JSON or, for scalar items, comma separated values; objects and untyped values take JSON,
+// and untyped text that is not JSON is a string.
+func (s *Schema) ParseValue(input string) (any, error) {
+	var value any
+	switch kind := s.Kind(); kind {
+	case "string":
+		value = input
+	case "integer":
+		number, err := strconv.ParseInt(input, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not an integer", input)
+		}
+		value = number
+	case "number":
+		number, err := strconv.ParseFloat(input, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a number", input)
+		}
+		value = number
+	case "boolean":
+		switch strings.ToLower(input) {
+		case "true", "yes", "y":
+			value = true
+		case "false", "no", "n":
+			value = false
+		default:
+			return nil, fmt.Errorf("%q is not a boolean (true or false)", input)
+		}
+	case "array":
+		if json.Unmarshal([]byte(input), &value) == nil {
+			if _, ok := value.([]any); !ok {
+				return nil, fmt.Errorf("%s is not an array", input)
+			}
+			break
+		}
+		if s.Items == nil || s.Items.Kind() == "array" || s.Items.Kind() == "object" {
+			return nil, fmt.Errorf("%q is not a JSON array", input)
+		}
+		var items []any
+		for _, part := range strings.Split(input, ",") {
+			item, err := s.Items.ParseValue(strings.TrimSpace(part))
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+		}
+		value = items
+	case "object":
+		var object map[string]any
+		if err := json.Unmarshal([]byte(input), &object); err != nil {
+			return nil, fmt.Errorf("%q is not a JSON object", input)
+		}
+		value = object
+	default:
+		if json.Unmarshal([]byte(input), &value) != nil {
+			value = input
+		}
+	}
+
+	if len(s.Enum) > 0 && !s.allows(value) {
+		return nil, fmt.Errorf("%q is not one of %s", input, s.EnumValues())
+	}
+	return value, nil
+}
+
+// allows reports whether value is one of the schema's enum values
+func (s *Schema) allows(value any) bool {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return false
+	}
+	for _, allowed := range s.Enum {
+		var normalized any
+		if json.Unmarshal(allowed, &normalized) != nil {
+			continue
+		}
+		if content, err := json.Marshal(normalized); err == nil && string(content) == string(encoded) {
+			return true
+		}
+	}
+	return false
+}
+
+// EnumValues lists the allowed values of the schema, comma separated
+func (s *Schema) EnumValues() string {
+	values := make([]string, len(s.Enum))
+	for i, value := range s.Enum {
+		var text string
+		if json.Unmarshal(value, &text) == nil {
+			values[i] = text
+		} else {
+			values[i] = string(value)
+		}
+	}
+	return strings.Join(values, ", ")
+}
+
+// schemaTypes are the type names JSON Schema defines
+var schemaTypes = map[string]bool{
+	"null": true, "boolean": true, "object": true, "array": true, "number": true, "string": true, "integer": true,
+}
+
+// ValidateObjectSchema lists what keeps raw from being a valid JSON Schema for an object,
+// as tool input and output schemas must be. Only the keywords' shapes are checked, not
+// that a draft knows them all.
+func ValidateObjectSchema(raw json.RawMessage) []string {
+	var schema map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &schema); err != nil || schema == nil {
+		return []string{"is not a JSON object"}
+	}
+	problems := validateSchema("", raw)
+	var kind string
+	if json.Unmarshal(schema["type"], &kind) != nil || kind != "object" {
+		problems = append(problems, `type must be "object"`)
+	}
+	return problems
+}
+
+// validateSchema checks the keywords of the schema at path, and of its subschemas
+func validateSchema(path string, raw json.RawMessage) []string {
+	var flag bool
+	if json.Unmarshal(raw, &flag) == nil {
+		// true and false are schemas accepting anything and nothing
+		return nil
+	}
+	var schema map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &schema); err != nil || schema == nil {
+		return []string{problemAt(path, "is not a schema (an object or a boolean)")}
+	}
+
+	var problems []string
+	fail := func(keyword, problem string) {
+		problems = append(problems, problemAt(schemaPath(path, keyword), problem))
+	}
+	for keyword, value := range schema {
+		switch keyword {
+		case "type":
+			var name string
+			var names []string
+			switch {
+			case json.Unmarshal(value, &name) == nil:
+				names = []string{name}
+			case json.Unmarshal(value, &names) != nil || len(names) == 0:
+				fail(keyword, "must be a type name or a list of them")
+			}
+			for _, name := range names {
+				if !schemaTypes[name] {
+					fail(keyword, fmt.Sprintf("%q is not a JSON Schema type", name))
+				}
+			}
+		case "properties", "patternProperties", "$defs", "definitions", "dependentSchemas":
+			var subschemas map[string]json.RawMessage
+			if json.Unmarshal(value, &subschemas) != nil || subschemas == nil {
+				fail(keyword, "must be an object of schemas")
+				continue
+			}
+			for name, subschema := range subschemas {
+				problems = append(problems, validateSchema(schemaPath(schemaPath(path, keyword), name), subschema)...)
+			}
+		case "items":
+			var list []json.RawMessage
+			if json.Unmarshal(value, &list) == nil {
+				for i, subschema := range list {
+					problems = append(problems, validateSchema(schemaPath(schemaPath(path, keyword), strconv.Itoa(i)), subschema)...)
+				}
+				continue
+			}
+			problems = append(problems, validateSchema(schemaPath(path, keyword), value)...)
+		case "additionalProperties", "additionalItems", "unevaluatedProperties", "unevaluatedItems",
+			"contains", "propertyNames", "not", "if", "then", "else":
+			problems = append(problems, validateSchema(schemaPath(path, keyword), value)...)
+		case "allOf", "anyOf", "oneOf", "prefixItems":
+			var list []json.RawMessage
+			if json.Unmarshal(value, &list) != nil || len(list) == 0 {
+				fail(keyword, "must be a non-empty list of schemas")
+				continue
+			}
+			for i, subschema := range list {
+				problems = append(problems, validateSchema(schemaPath(schemaPath(path, keyword), strconv.Itoa(i)), subschema)...)
+			}
+		case "required":
+			var names []string
+			if json.Unmarshal(value, &names) != nil {
+				fail(keyword, "must be a list of property names")
+			}
+		case "enum":
+			var values []json.RawMessage
+			if json.Unmarshal(value, &values) != nil {
+				fail(keyword, "must be a list of values")
+			}
+		case "minimum", "maximum", "exclusiveMinimum", "exclusiveMaximum":
+			var number float64
+			if json.Unmarshal(value, &number) != nil && json.Unmarshal(value, &flag) != nil {
+				fail(keyword, "must be a number")
+			}
+		case "multipleOf":
+			var number float64
+			if json.Unmarshal(value, &number) != nil || number <= 0 {
+				fail(keyword, "must be a number greater than 0")
+			}
+		case "minLength", "maxLength", "minItems", "maxItems", "minProperties", "maxProperties", "minContains", "maxContains":
+			var count uint64
+			if json.Unmarshal(value, &count) != nil {
+				fail(keyword, "must be a non-negative integer")
+			}
+		case "title", "description", "pattern", "format", "$schema", "$id", "$ref", "$anchor", "$comment":
+			var text string
+			if json.Unmarshal(value, &text) != nil {
+				fail(keyword, "must be a string")
+			}
+		}
+	}
+	sort.Strings(problems)
+	return problems
+}
+
+func schemaPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+func problemAt(path, problem string) string {
+	if path == "" {
+		return problem
+	}
+	return path + " " + problem
+}
@@ -0,0 +1,234 @@
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// Server answers MCP requests with registered handlers. Each client session is connected
+// with Connect, which returns the transport the client's messages go through; it can be
+// served over HTTP with a Bridge or over standard input and output with ServeStdio.
+type Server struct {
+	info         Implementation
+	capabilities ServerCapabilities
+	// Instructions are returned to clients on initialize
+	Instructions string
+
+	mu       sync.Mutex
+	handlers map[string]RequestHandler
+	sessions map[*serverSession]bool
+}
+
+// NewServer creates a server declaring capabilities. Initialize and ping are answered by
+// the server itself.
+func NewServer(info Implementation, capabilities ServerCapabilities) *Server {
+	return &Server{
+		info:         info,
+		capabilities: capabilities,
+		handlers:     map[string]RequestHandler{},
+		sessions:     map[*serverSession]bool{},
+	}
+}
+
+// HandleRequest answers the client requests for method, e.g. "tools/list"
+func (s *Server) HandleRequest(method string, handler RequestHandler) {
+	s.mu.Lock()
+	s.handlers[method] = handler
+	s.mu.Unlock()
+}
+
+// Connect starts a client session. Messages sent on the returned transport are handled by
+// the server; responses and notifications are received from it.
+func (s *Server) Connect() Transport {
+	session := &serverSession{server: s, inbox: newInbox(), inFlight: map[string]context.CancelFunc{}}
+	s.mu.Lock()
+	s.sessions[session] = true
+	s.mu.Unlock()
+	return session
+}
+
+// Notify sends a notification to every initialized session
+func (s *Server) Notify(method string, params any) {
+	message := &Message{JSONRPC: "2.0", Method: method}
+	if params != nil {
+		data, err := json.Marshal(params)
+		if err != nil {
+			return
+		}
+		message.Params = data
+	}
+
+	s.mu.Lock()
+	sessions := make([]*serverSession, 0, len(s.sessions))
+	for session := range s.sessions {
+		sessions = append(sessions, session)
+	}
+	s.mu.Unlock()
+	for _, session := range sessions {
+		session.mu.Lock()
+		initialized := session.initialized
+		session.mu.Unlock()
+		if initialized {
+			session.inbox.deliver(message)
+		}
+	}
+}
+
+// serverSession is the server side of one client session
+type serverSession struct {
+	server *Server
+	inbox  *inbox // messages to the client
+
+	mu          sync.Mutex
+	inFlight    map[string]context.CancelFunc
+	initialized bool
+}
+
+func (t *serverSession) Send(ctx context.Context, message *Message) error {
+	select {
+	case <-t.inbox.done:
+		return ErrClosed
+	default:
+	}
+	switch {
+	case message.IsRequest():
+		// Registered before returning, so a cancellation sent next finds the request
+		ctx, cancel := context.WithCancel(context.Background())
+		t.mu.Lock()
+		t.inFlight[string(message.ID)] = cancel
+		t.mu.Unlock()
+		go t.handle(ctx, message)
+	case message.Method == "notifications/initialized":
+		t.mu.Lock()
+		t.initialized = true
+		t.mu.Unlock()
+	case message.Method == NotificationCancelled:
+		var params cancelledParams
+		if json.Unmarshal(message.Params, &params) == nil {
+			t.mu.Lock()
+			if cancel, ok := t.inFlight[string(params.RequestID)]; ok {
+				cancel()
+			}
+			t.mu.Unlock()
+		}
+	}
+	return nil
+}
+
+// handle answers a request, unless the client cancelled it
+func (t *serverSession) handle(ctx context.Context, request *Message) {
+	response := &Message{JSONRPC: "2.0", ID: request.ID}
+	result, err := t.server.answer(ctx, request)
+	if err == nil {
+		response.Result, err = json.Marshal(result)
+	}
+	if err != nil {
+		var rpcErr *Error
+		if !errors.As(err, &rpcErr) {
+			rpcErr = &Error{Code: CodeInternalError, Message: err.Error()}
+		}
+		response.Result = nil
+		response.Error = rpcErr
+	}
+	// The response is queued under the lock cancellations take, so a request is either
+	// answered before its cancellation is processed or not at all
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if cancel, ok := t.inFlight[string(request.ID)]; ok {
+		delete(t.inFlight, string(request.ID))
+		defer cancel()
+	}
+	if ctx.Err() != nil {
+		// Cancelled by the client, which expects no response
+		return
+	}
+	t.inbox.deliver(response)
+}
+
+func (s *Server) answer(ctx context.Context, request *Message) (any, error) {
+	switch request.Method {
+	case "initialize":
+		var params initializeParams
+		if err := json.Unmarshal(request.Params, &params); err != nil {
+			return nil, &Error{Code: CodeInvalidParams, Message: "invalid initialize parameters"}
+		}
+		version := params.ProtocolVersion
+		if !supportedVersion(version) {
+			version = LatestProtocolVersion
+		}
+		return InitializeResult{
+			ProtocolVersion: version,
+			Capabilities:    s.capabilities,
+			ServerInfo:      s.info,
+			Instructions:    s.Instructions,
+		}, nil
+	case "ping":
+		return struct{}{}, nil
+	}
+
+	s.mu.Lock()
+	handler := s.handlers[request.Method]
+	s.mu.Unlock()
+	if handler == nil {
+		return nil, &Error{Code: CodeMethodNotFound, Message: "method not found: " + request.Method}
+	}
+	return handler(ctx, request.Params)
+}
+
+func (t *serverSession) Receive() (*Message, error) {
+	return t.inbox.receive()
+}
+
+func (t *serverSession) Close() error {
+	t.server.mu.Lock()
+	delete(t.server.sessions, t)
+	t.server.mu.Unlock()
+	t.mu.Lock()
+	for _, cancel := range t.inFlight {
+		cancel()
+	}
+	t.mu.Unlock()
+	t.inbox.close(nil)
+	return nil
+}
+
+// ServeStdio relays a session between a client on in and out, one JSON message per line,
+// and transport, until either side ends
+func ServeStdio(transport Transport, in io.Reader, out io.Writer) error {
+	defer transport.Close()
+	done := make(chan error, 1)
+	go func() {
+		encoder := json.NewEncoder(out)
+		for {
+			message, err := transport.Receive()
+			if err != nil {
+				done <- nil
+				return
+			}
+			if err := encoder.Encode(message); err != nil {
+				done <- fmt.Errorf("failed to write to the client: %w", err)
+				return
+			}
+		}
+	}()
+	go func() {
+		reader := bufio.NewReaderSize(in, 64*1024)
+		for {
+			line, err := reader.ReadBytes('\n')
+			var message Message
+			if len(line) > 0 && json.Unmarshal(line, &message) == nil {
+				transport.Send(context.Background(), &message)
+			}
+			if err != nil {
+				done <- nil
+				return
+			}
+		}
+	}()
+	return <-done
+}
@@ -0,0 +1,178 @@
+package mcp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// ErrClosed is returned once the connection to the server has ended
+var ErrClosed = errors.New("connection to the MCP server closed")
+
+// Transport carries JSON-RPC messages between the client and a server
+type Transport interface {
+	// Send delivers one message to the server
+	Send(ctx context.Context, message *Message) error
+	// Receive blocks until the server sends a message or the connection ends
+	Receive() (*Message, error)
+	// Close ends the connection
+	Close() error
+}
+
+// inbox queues received messages for Receive until the connection ends
+type inbox struct {
+	messages chan *Message
+	done     chan struct{}
+	once     sync.Once
+	err      error
+}
+
+func newInbox() *inbox {
+	return &inbox{messages: make(chan *Message, 64), done: make(chan struct{})}
+}
+
+// deliver queues message, dropping it when the connection already ended
+func (b *inbox) deliver(message *Message) {
+	select {
+	case b.messages <- message:
+	case <-b.done:
+	}
+}
+
+// deliverData decodes a single message or a batch and queues it
+func (b *inbox) deliverData(data []byte) {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 {
+		return
+	}
+	if data[0] == '[' {
+		var batch []*Message
+		if json.Unmarshal(data, &batch) == nil {
+			for _, message := range batch {
+				b.deliver(message)
+			}
+		}
+		return
+	}
+	var message Message
+	if json.Unmarshal(data, &message) == nil {
+		b.deliver(&message)
+	}
+}
+
+// close ends the connection with err (ErrClosed when nil); later calls are ignored
+func (b *inbox) close(err error) {
+	b.once.Do(func() {
+		if err == nil {
+			err = ErrClosed
+		}
+		b.err = err
+		close(b.done)
+	})
+}
+
+func (b *inbox) receive() (*Message, error) {
+	// Drain what already arrived before reporting the end of the connection
+	select {
+	case message := <-b.messages:
+		return message, nil
+	default:
+	}
+	select {
+	case message := <-b.messages:
+		return message, nil
+	case <-b.done:
+		return nil, b.err
+	}
+}
+
+// StdioTransport talks to a server over its standard input and output: one JSON message
+// per line. Lines that are not JSON-RPC messages, such as stray log output, are skipped.
+type StdioTransport struct {
+	in    io.WriteCloser
+	inbox *inbox
+	// writing holds a token while a message is written, so waiting for a write stuck on a
+	// server that stopped reading can be cancelled
+	writing chan struct{}
+	closer  func() error
+}
+
+// NewStdioTransport reads messages from out (the server's stdout) and writes them to in
+// (its stdin). closer, when set, is called on Close after stdin is closed.
+func NewStdioTransport(out io.Reader, in io.WriteCloser, closer func() error) *StdioTransport {
+	t := &StdioTransport{in: in, inbox: newInbox(), writing: make(chan struct{}, 1), closer: closer}
+	go t.read(out)
+	return t
+}
+
+func (t *StdioTransport) read(out io.Reader) {
+	reader := bufio.NewReaderSize(out, 64*1024)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if trimmed := bytes.TrimSpace(line); len(trimmed) > 0 && (trimmed[0] == '{' || trimmed[0] == '[') {
+			t.inbox.deliverData(trimmed)
+		}
+		if err != nil {
+			if err == io.EOF {
+				err = ErrClosed
+			}
+			t.inbox.close(err)
+			return
+		}
+	}
+}
+
+func (t *StdioTransport) Send(ctx context.Context, message *Message) error {
+	data, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+	select {
+	case t.writing <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	written := make(chan error, 1)
+	go func() {
+		_, err := t.in.Write(append(data, '\n'))
+		<-t.writing
+		written <- err
+	}()
+	select {
+	case err = <-written:
+	case <-ctx.Done():
+		select {
+		case err = <-written:
+		default:
+			// The server stopped reading, and a partly written message would corrupt the
+			// stream, so the connection is given up; closing stdin ends the write
+			t.Close()
+			return ctx.Err()
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write to the server: %w", err)
+	}
+	return nil
+}
+
+func (t *StdioTransport) Receive() (*Message, error) {
+	return t.inbox.receive()
+}
+
+func (t *StdioTransport) Close() error {
+	err := t.in.Close()
+	if t.closer != nil {
+		if closeErr := t.closer(); closeErr != nil {
+			err = closeErr
+		}
+	}
+	t.inbox.close(nil)
+	return err
+}
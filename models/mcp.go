@@ -1,14 +1,54 @@
 package models
 
+import (
+	"encoding/json"
+
+	"mcphub/mcp"
+)
+
 type MCPConfig struct {
-	Name        string     `json:"name"`
-	Version     string     `json:"version"`
-	Description string     `json:"description"`
-	Author      string     `json:"author"`
-	License     string     `json:"license"`
-	Keywords    []string   `json:"keywords"`
-	Repository  Repository `json:"repository"`
-	Run         RunConfig  `json:"run"`
+	Name        string             `json:"name"`
+	Version     string             `json:"version"`
+	Description string             `json:"description"`
+	Author      string             `json:"author"`
+	License     string             `json:"license"`
+	Keywords    []string           `json:"keywords"`
+	Repository  Repository         `json:"repository"`
+	Run         RunConfig          `json:"run"`
+	Platforms   []string           `json:"platforms,omitempty"`
+	Runtime     *RuntimeConfig     `json:"runtime,omitempty"`
+	Build       *BuildConfig       `json:"build,omitempty"`
+	Healthcheck *HealthcheckConfig `json:"healthcheck,omitempty"`
+	Env         []EnvVar           `json:"env,omitempty"`
+}
+
+// EnvVar is an environment variable the server reads. Defaults of non-secret variables are
+// baked into the image; required ones must be supplied when the container starts.
+type EnvVar struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Required    bool   `json:"required,omitempty"`
+	Default     string `json:"default,omitempty"`
+	Secret      bool   `json:"secret,omitempty"`
+}
+
+// RuntimeConfig selects the language runtime version used for the base images,
+// e.g. {"version": "22"} for node:22-alpine. Name defaults to the language of run.command.
+type RuntimeConfig struct {
+	Name    string `json:"name,omitempty"`
+	Version string `json:"version,omitempty"`
+}
+
+// BuildConfig customizes how the image is built. Dockerfile switches generation off entirely;
+// the other fields adjust the generated Dockerfile.
+type BuildConfig struct {
+	Dockerfile string            `json:"dockerfile,omitempty"`
+	BaseImage  string            `json:"base_image,omitempty"`
+	Packages   []string          `json:"packages,omitempty"`
+	Run        []string          `json:"run,omitempty"`
+	Args       map[string]string `json:"args,omitempty"`
+	// PackageManager picks the dependency installer when several lockfiles are present
+	PackageManager string `json:"package_manager,omitempty"`
 }
 
 type Repository struct {
@@ -16,10 +56,55 @@ type Repository struct {
 	URL  string `json:"url"`
 }
 
+// Transports an MCP server can be reached over
+const (
+	TransportStdio = "stdio"
+	TransportSSE   = "sse"
+	TransportHTTP  = "http"
+)
+
 type RunConfig struct {
 	Command string   `json:"command"`
 	Args    []string `json:"args"`
 	Port    int      `json:"port"`
+	// Transport is stdio, sse or http (streamable HTTP)
+	Transport string `json:"transport,omitempty"`
+	// Path is the endpoint of the sse and http transports
+	Path string `json:"path,omitempty"`
+}
+
+// EffectiveTransport returns the configured transport, defaulting to http for servers
+// with a port and stdio otherwise
+func (r RunConfig) EffectiveTransport() string {
+	if r.Transport != "" {
+		return r.Transport
+	}
+	if r.Port > 0 {
+		return TransportHTTP
+	}
+	return TransportStdio
+}
+
+// EndpointPath returns the configured endpoint path, defaulting to /sse or /mcp
+func (r RunConfig) EndpointPath() string {
+	if r.Path != "" {
+		return r.Path
+	}
+	if r.EffectiveTransport() == TransportSSE {
+		return "/sse"
+	}
+	return "/mcp"
+}
+
+// HealthcheckConfig tunes the container healthcheck. Without Path the server is probed
+// over its MCP transport; with Path a plain HTTP GET of that path must succeed.
+type HealthcheckConfig struct {
+	Disabled    bool   `json:"disabled,omitempty"`
+	Path        string `json:"path,omitempty"`
+	Interval    string `json:"interval,omitempty"`
+	Timeout     string `json:"timeout,omitempty"`
+	StartPeriod string `json:"start_period,omitempty"`
+	Retries     int    `json:"retries,omitempty"`
 }
 
 type DockerfileRequest struct {
@@ -27,11 +112,93 @@ type DockerfileRequest struct {
 }
 
 type DockerfileResponse struct {
-	ExtractedPath  string    `json:"extracted_path"`
-	DockerfilePath string    `json:"dockerfile_path"`
-	ImageName      string    `json:"image_name"`
-	TarFilePath    string    `json:"tar_file_path"`
-	Config         MCPConfig `json:"config"`
-	Success        bool      `json:"success"`
-	Message        string    `json:"message,omitempty"`
+	ExtractedPath  string          `json:"extracted_path"`
+	DockerfilePath string          `json:"dockerfile_path"`
+	ImageName      string          `json:"image_name"`
+	TarFilePath    string          `json:"tar_file_path"`
+	BuildLogPath   string          `json:"build_log_path"`
+	Artifacts      []ImageArtifact `json:"artifacts"`
+	SourceHash     string          `json:"source_hash"`
+	Config         MCPConfig       `json:"config"`
+	// Introspection is what the built server reported about itself, when it could be started
+	Introspection *mcp.Introspection `json:"introspection,omitempty"`
+	Success       bool               `json:"success"`
+	Message       string             `json:"message,omitempty"`
+}
+
+// ImageArtifact is a built image for one platform (empty for host-only builds)
+type ImageArtifact struct {
+	Platform     string `json:"platform,omitempty"`
+	Image        string `json:"image"`
+	TarFilePath  string `json:"tar_file_path,omitempty"`
+	BuildLogPath string `json:"build_log_path"`
+}
+
+// RegistryManifest describes a published MCP server in registries that store archives
+type RegistryManifest struct {
+	Name       string    `json:"name"`
+	Author     string    `json:"author"`
+	Version    string    `json:"version"`
+	SourceHash string    `json:"source_hash"`
+	Platforms  []string  `json:"platforms,omitempty"`
+	Config     MCPConfig `json:"config"`
+	PushedAt   string    `json:"pushed_at"`
+	// Introspection lists the server's tools, resources and prompts
+	Introspection *mcp.Introspection `json:"introspection,omitempty"`
+}
+
+// LockFile pins base images to the digests they resolved to when the lock was written
+type LockFile struct {
+	Images map[string]string `json:"images"`
+}
+
+// ClientServerConfig is a server entry in the configuration of an MCP host such as Claude
+// Desktop or VS Code; each host uses its own subset of the fields
+type ClientServerConfig struct {
+	Type      string            `json:"type,omitempty"`
+	Command   string            `json:"command,omitempty"`
+	Args      []string          `json:"args,omitempty"`
+	Env       map[string]string `json:"env,omitempty"`
+	URL       string            `json:"url,omitempty"`
+	ServerURL string            `json:"serverUrl,omitempty"`
+}
+
+// ClientInput is a value VS Code prompts for when it starts a server, referenced from
+// its entry as ${input:<id>}
+type ClientInput struct {
+	Type        string `json:"type"`
+	ID          string `json:"id"`
+	Description string `json:"description,omitempty"`
+	Password    bool   `json:"password,omitempty"`
+}
+
+// ToolTestFile lists tool calls shipped with a server to check its results, kept next to
+// mcp.json as mcp.tests.json or mcp.tests.yaml
+type ToolTestFile struct {
+	Tests []ToolTest `json:"tests"`
+}
+
+// ToolTest calls a tool with arguments and matches its result against Expect
+type ToolTest struct {
+	// Name describes the test; it defaults to the tool name
+	Name      string          `json:"name,omitempty"`
+	Tool      string          `json:"tool"`
+	Arguments json.RawMessage `json:"arguments,omitempty"`
+	Expect    ToolExpectation `json:"expect"`
+}
+
+// ToolExpectation matches a tool result. The text of a result is its text content, one
+// line per part. Every matcher given must match.
+type ToolExpectation struct {
+	// IsError is whether the tool reports an error; otherwise errors fail the test
+	IsError bool `json:"isError,omitempty"`
+	// Exact is the whole text, or for values other than strings the structured content
+	Exact json.RawMessage `json:"exact,omitempty"`
+	// Contains are strings the text must contain
+	Contains []string `json:"contains,omitempty"`
+	// Regex are regular expressions the text must match
+	Regex []string `json:"regex,omitempty"`
+	// JSON maps paths such as $.items[0].name, into the structured content or else the text
+	// parsed as JSON, to the values expected there
+	JSON map[string]json.RawMessage `json:"json,omitempty"`
 }
@@ -0,0 +1,210 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"mcphub/mcp"
+	"mcphub/models"
+)
+
+// NewImageBridge serves the stdio server in image over HTTP, running it in a throwaway
+// container per session. env holds NAME=value (or NAME, passed through) entries for the
+// server; its required variables must be given or set in this process's environment.
+// Server stderr is copied to logs.
+func NewImageBridge(rt *ContainerRuntime, image string, env []string, logs io.Writer) (*mcp.Bridge, error) {
+	labels, err := rt.ImageLabels(image)
+	if err != nil {
+		return nil, err
+	}
+	metadata, err := serverMetadata(image, labels)
+	if err != nil {
+		return nil, err
+	}
+	if metadata.Transport != models.TransportStdio {
+		return nil, fmt.Errorf("%s already serves %s on port %d; run it with mcphub run instead", image, metadata.Transport, metadata.Port)
+	}
+	if env, err = forwardEnv(metadata, env); err != nil {
+		return nil, err
+	}
+
+	args := []string{"run", "--rm", "-i"}
+	for _, entry := range env {
+		args = append(args, "-e", entry)
+	}
+	return mcp.NewBridge(func(ctx context.Context) (mcp.Transport, error) {
+		name := sessionContainerName()
+		return startStdioTransport(rt, append(append([]string{}, args...), "--name", name, image), name, logs)
+	}), nil
+}
+
+// BridgeLogPath is where the output of a bridge started in the background by `mcphub run`
+// is written: ~/.mcphub/logs/<name>-bridge.log
+func BridgeLogPath(name string) (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	dir := filepath.Join(home, ".mcphub", "logs")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, name+"-bridge.log"), nil
+}
+
+// BackgroundBridge is a bridge started in the background by `mcphub run --bridge`. Its
+// record is kept in ~/.mcphub/bridges/<name>.json while it runs.
+type BackgroundBridge struct {
+	Name  string `json:"name"`
+	Image string `json:"image"`
+	PID   int    `json:"pid"`
+	// URL is the base URL the bridge serves mcp.BridgePath and mcp.BridgeSSEPath under
+	URL string `json:"url"`
+}
+
+// bridgesDir holds the records of the background bridges
+func bridgesDir() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".mcphub", "bridges"), nil
+}
+
+// bridgeRecordPath returns the record of the background bridge name
+func bridgeRecordPath(name string) (string, error) {
+	dir, err := bridgesDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, name+".json"), nil
+}
+
+// SaveBackgroundBridge records a bridge started in the background
+func SaveBackgroundBridge(bridge *BackgroundBridge) error {
+	path, err := bridgeRecordPath(bridge.Name)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	content, err := json.MarshalIndent(bridge, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, content, 0644)
+}
+
+// FindBackgroundBridge returns the running background bridge name, or nil when there is none
+func FindBackgroundBridge(name string) (*BackgroundBridge, error) {
+	path, err := bridgeRecordPath(name)
+	if err != nil {
+		return nil, err
+	}
+	content, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var bridge BackgroundBridge
+	if err := json.Unmarshal(content, &bridge); err != nil {
+		return nil, fmt.Errorf("invalid bridge record %s: %w", path, err)
+	}
+	if !processRunning(bridge.PID) {
+		// The bridge exited without removing its record
+		os.Remove(path)
+		return nil, nil
+	}
+	return &bridge, nil
+}
+
+// BackgroundBridges lists the running background bridges
+func BackgroundBridges() ([]*BackgroundBridge, error) {
+	dir, err := bridgesDir()
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var bridges []*BackgroundBridge
+	for _, entry := range entries {
+		name, isRecord := strings.CutSuffix(entry.Name(), ".json")
+		if !isRecord || entry.IsDir() {
+			continue
+		}
+		bridge, err := FindBackgroundBridge(name)
+		if err != nil {
+			return nil, err
+		}
+		if bridge != nil {
+			bridges = append(bridges, bridge)
+		}
+	}
+	return bridges, nil
+}
+
+// RemoveBackgroundBridge deletes the record of the bridge name, unless another process
+// than pid has replaced it
+func RemoveBackgroundBridge(name string, pid int) {
+	bridge, err := FindBackgroundBridge(name)
+	if err == nil && bridge != nil && bridge.PID == pid {
+		if path, err := bridgeRecordPath(name); err == nil {
+			os.Remove(path)
+		}
+	}
+}
+
+// Stop ends the bridge and its sessions and deletes its record
+func (b *BackgroundBridge) Stop() error {
+	process, err := os.FindProcess(b.PID)
+	if err == nil {
+		err = process.Signal(syscall.SIGTERM)
+	}
+	if err != nil && !errors.Is(err, os.ErrProcessDone) {
+		return fmt.Errorf("failed to stop the bridge %s (PID %d): %w", b.Name, b.PID, err)
+	}
+	path, err := bridgeRecordPath(b.Name)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// AttachBridge connects to a background bridge over streamable HTTP. Every attachment
+// is a bridge session with a server container of its own.
+func AttachBridge(ctx context.Context, bridge *BackgroundBridge) (*Session, error) {
+	client := mcp.NewClient(mcp.NewHTTPTransport(bridge.URL + mcp.BridgePath))
+	info, err := client.Initialize(ctx)
+	if err != nil {
+		client.Close()
+		return nil, fmt.Errorf("the bridge %s did not answer: %w", bridge.Name, err)
+	}
+	return &Session{Client: client, Info: info, Metadata: &ImageMetadata{Transport: models.TransportHTTP}}, nil
+}
+
+// processRunning reports whether the process pid exists
+func processRunning(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+	process, err := os.FindProcess(pid)
+	return err == nil && process.Signal(syscall.Signal(0)) == nil
+}
@@ -0,0 +1,169 @@
+package services
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// DefaultLogDir is where per-build log files are written
+const DefaultLogDir = "logs"
+
+// buildErrorTailLines is how much of the failing step's output is kept in the summary
+const buildErrorTailLines = 15
+
+// BuildOptions controls a single image build and where its output goes
+type BuildOptions struct {
+	// Labels are added to built images on top of those in the Dockerfile
+	Labels map[string]string
+	// Dockerfile is the Dockerfile path relative to the build context (defaults to Dockerfile)
+	Dockerfile string
+	// BuildArgs are passed to the build as --build-arg values
+	BuildArgs map[string]string
+	// Output receives live build output; without it the output only goes to the log file
+	Output io.Writer
+	// LogDir is the directory for per-build log files (defaults to "logs")
+	LogDir string
+}
+
+// BuildError describes a failed image build with the step that broke it
+type BuildError struct {
+	Err     error
+	Step    string
+	Tail    []string
+	LogPath string
+}
+
+func (e *BuildError) Error() string {
+	var msg strings.Builder
+	msg.WriteString(fmt.Sprintf("image build failed: %v", e.Err))
+	if e.Step != "" {
+		msg.WriteString(fmt.Sprintf("\n💥 Failing step: %s", e.Step))
+	}
+	if len(e.Tail) > 0 {
+		msg.WriteString("\n📄 Last output:")
+		for _, line := range e.Tail {
+			msg.WriteString("\n    " + line)
+		}
+	}
+	if e.LogPath != "" {
+		msg.WriteString(fmt.Sprintf("\n📝 Full log: %s", e.LogPath))
+	}
+	return msg.String()
+}
+
+func (e *BuildError) Unwrap() error {
+	return e.Err
+}
+
+// orDiscard returns w, or a writer discarding everything when w is nil
+func orDiscard(w io.Writer) io.Writer {
+	if w == nil {
+		return io.Discard
+	}
+	return w
+}
+
+// newBuildLog creates the log file for a build of imageName
+func newBuildLog(logDir, imageName string) (*os.File, error) {
+	if logDir == "" {
+		logDir = DefaultLogDir
+	}
+	if err := os.MkdirAll(logDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create log directory: %w", err)
+	}
+
+	safeName := strings.NewReplacer("/", "_", ":", "_").Replace(imageName)
+	fileName := fmt.Sprintf("%s-%s.log", safeName, time.Now().Format("20060102-150405"))
+	return os.Create(filepath.Join(logDir, fileName))
+}
+
+var (
+	// BuildKit plain progress: "#7 [3/5] RUN npm ci"
+	buildkitStepPattern = regexp.MustCompile(`^#(\d+) (\[.+\] .+)$`)
+	// BuildKit failure marker: "#7 ERROR: process ... did not complete successfully"
+	buildkitErrorPattern = regexp.MustCompile(`^#(\d+) ERROR:`)
+	// Legacy docker builder "Step 3/5 : RUN npm ci" and podman "STEP 3/5: RUN npm ci"
+	classicStepPattern = regexp.MustCompile(`^(?i:step) (\d+/\d+) ?: (.+)$`)
+)
+
+// summarizeBuildFailure finds the failing Dockerfile step in a build log and the output it produced
+func summarizeBuildFailure(log string) (string, []string) {
+	lines := strings.Split(strings.TrimRight(log, "\n"), "\n")
+
+	// BuildKit output interleaves steps, so locate the step that reported the error
+	steps := map[string]string{}
+	failedID := ""
+	for _, line := range lines {
+		if m := buildkitStepPattern.FindStringSubmatch(line); m != nil {
+			steps[m[1]] = m[2]
+		}
+		if m := buildkitErrorPattern.FindStringSubmatch(line); m != nil && failedID == "" {
+			failedID = m[1]
+		}
+	}
+	if failedID != "" {
+		prefix := "#" + failedID + " "
+		var output []string
+		for _, line := range lines {
+			if strings.HasPrefix(line, prefix) && line != prefix+steps[failedID] {
+				output = append(output, strings.TrimPrefix(line, prefix))
+			}
+		}
+		return steps[failedID], lastLines(output, buildErrorTailLines)
+	}
+
+	// Sequential builders: the last step started is the one that failed
+	lastStep := -1
+	step := ""
+	for i, line := range lines {
+		if m := classicStepPattern.FindStringSubmatch(strings.TrimSpace(line)); m != nil {
+			lastStep = i
+			step = fmt.Sprintf("[%s] %s", m[1], m[2])
+		}
+	}
+	if lastStep >= 0 {
+		return step, lastLines(nonEmpty(lines[lastStep+1:]), buildErrorTailLines)
+	}
+
+	return "", lastLines(nonEmpty(lines), buildErrorTailLines)
+}
+
+func lastLines(lines []string, n int) []string {
+	if len(lines) > n {
+		return lines[len(lines)-n:]
+	}
+	return lines
+}
+
+func nonEmpty(lines []string) []string {
+	var result []string
+	for _, line := range lines {
+		if strings.TrimSpace(line) != "" {
+			result = append(result, line)
+		}
+	}
+	return result
+}
+
+// readLog reads back a finished build log for summarizing
+func readLog(path string) string {
+	file, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+
+	var log strings.Builder
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		log.WriteString(scanner.Text() + "\n")
+	}
+	return log.String()
+}
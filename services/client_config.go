@@ -0,0 +1,245 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+
+	"mcphub/models"
+)
+
+// MCP hosts whose configuration client-config writes
+const (
+	ClientClaudeDesktop = "claude-desktop"
+	ClientVSCode        = "vscode"
+	ClientCursor        = "cursor"
+	ClientWindsurf      = "windsurf"
+)
+
+// ClientTargets lists the supported MCP hosts
+var ClientTargets = []string{ClientClaudeDesktop, ClientVSCode, ClientCursor, ClientWindsurf}
+
+// ClientConfig is the part of an MCP host's configuration that lists servers
+type ClientConfig struct {
+	Target  string
+	Servers map[string]models.ClientServerConfig
+	// Inputs are the VS Code inputs the servers' placeholders refer to
+	Inputs []models.ClientInput
+	// Placeholders are the environment variables to fill in by hand, by server
+	Placeholders map[string][]string
+}
+
+// NewClientConfig starts an empty configuration for target
+func NewClientConfig(target string) (*ClientConfig, error) {
+	for _, supported := range ClientTargets {
+		if target == supported {
+			return &ClientConfig{Target: target, Servers: map[string]models.ClientServerConfig{}, Placeholders: map[string][]string{}}, nil
+		}
+	}
+	return nil, fmt.Errorf("unsupported target %q (use %s)", target, strings.Join(ClientTargets, ", "))
+}
+
+// ClientConfigPath returns the file target reads its servers from. VS Code's is the
+// workspace's .vscode/mcp.json, relative to the current directory.
+func ClientConfigPath(target string) (string, error) {
+	if target == ClientVSCode {
+		return filepath.Join(".vscode", "mcp.json"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	switch target {
+	case ClientClaudeDesktop:
+		switch runtime.GOOS {
+		case "darwin":
+			return filepath.Join(home, "Library", "Application Support", "Claude", "claude_desktop_config.json"), nil
+		case "windows":
+			if appData := os.Getenv("APPDATA"); appData != "" {
+				return filepath.Join(appData, "Claude", "claude_desktop_config.json"), nil
+			}
+			return filepath.Join(home, "AppData", "Roaming", "Claude", "claude_desktop_config.json"), nil
+		default:
+			return filepath.Join(home, ".config", "Claude", "claude_desktop_config.json"), nil
+		}
+	case ClientCursor:
+		return filepath.Join(home, ".cursor", "mcp.json"), nil
+	case ClientWindsurf:
+		return filepath.Join(home, ".codeium", "windsurf", "mcp_config.json"), nil
+	}
+	return "", fmt.Errorf("unsupported target %q", target)
+}
+
+// serversKey is the key of the server entries in the target's file
+func (c *ClientConfig) serversKey() string {
+	if c.Target == ClientVSCode {
+		return "servers"
+	}
+	return "mcpServers"
+}
+
+// ClientServerName names the entry of the server in image after its mcp.json, or the
+// image's repository when it has none
+func ClientServerName(image string, metadata *ImageMetadata) string {
+	if metadata.Config != nil && metadata.Config.Name != "" {
+		return metadata.Config.Name
+	}
+	name := image
+	if slash := strings.LastIndex(name, "/"); slash >= 0 {
+		name = name[slash+1:]
+	}
+	name, _, _ = strings.Cut(name, "@")
+	name, _, _ = strings.Cut(name, ":")
+	return name
+}
+
+// Add configures the server in image as name. Stdio servers are started by the host with
+// `<binary> run -i`; their required and secret environment variables are passed through
+// from entry placeholders (VS Code inputs). HTTP and SSE servers are expected to be running
+// with mcphub run and are reached at address, the host address their port is published on,
+// or on localhost at the port in their labels when address is empty.
+func (c *ClientConfig) Add(name, binary, image string, metadata *ImageMetadata, address string) error {
+	if metadata.Transport == "" {
+		return fmt.Errorf("%s was not built by MCPHub; its transport is unknown", image)
+	}
+
+	if metadata.Transport == models.TransportStdio {
+		entry := models.ClientServerConfig{Command: binary, Args: []string{"run", "-i", "--rm"}}
+		if c.Target == ClientVSCode {
+			entry.Type = "stdio"
+		}
+		for _, env := range metadata.Env {
+			if !env.Required && !env.Secret {
+				// Defaults of optional variables are in the image; secrets have none there
+				continue
+			}
+			if entry.Env == nil {
+				entry.Env = map[string]string{}
+			}
+			entry.Args = append(entry.Args, "-e", env.Name)
+			if c.Target == ClientVSCode {
+				entry.Env[env.Name] = "${input:" + env.Name + "}"
+				c.addInput(models.ClientInput{Type: "promptString", ID: env.Name, Description: env.Description, Password: env.Secret})
+			} else {
+				entry.Env[env.Name] = "<" + env.Name + ">"
+				c.Placeholders[name] = append(c.Placeholders[name], env.Name)
+			}
+		}
+		entry.Args = append(entry.Args, image)
+		c.Servers[name] = entry
+		return nil
+	}
+
+	if metadata.Port <= 0 {
+		return fmt.Errorf("%s uses the %s transport but declares no port", image, metadata.Transport)
+	}
+	if address == "" {
+		address = fmt.Sprintf("localhost:%d", metadata.Port)
+	}
+	url := fmt.Sprintf("http://%s%s", address, metadata.Path)
+	switch c.Target {
+	case ClientClaudeDesktop:
+		// Claude Desktop launches local servers only, so remote ones go through mcp-remote
+		c.Servers[name] = models.ClientServerConfig{Command: "npx", Args: []string{"-y", "mcp-remote", url}}
+	case ClientVSCode:
+		c.Servers[name] = models.ClientServerConfig{Type: metadata.Transport, URL: url}
+	case ClientWindsurf:
+		c.Servers[name] = models.ClientServerConfig{ServerURL: url}
+	default:
+		c.Servers[name] = models.ClientServerConfig{URL: url}
+	}
+	return nil
+}
+
+func (c *ClientConfig) addInput(input models.ClientInput) {
+	for _, existing := range c.Inputs {
+		if existing.ID == input.ID {
+			return
+		}
+	}
+	c.Inputs = append(c.Inputs, input)
+}
+
+// JSON renders the configuration as a document for the target's file
+func (c *ClientConfig) JSON() ([]byte, error) {
+	document := map[string]any{c.serversKey(): c.Servers}
+	if len(c.Inputs) > 0 {
+		document["inputs"] = c.Inputs
+	}
+	return json.MarshalIndent(document, "", "  ")
+}
+
+// Merge adds the servers to the file at path, replacing entries of the same name and
+// keeping everything else. An existing file is first copied to a timestamped .bak file,
+// whose path is returned. replaced lists the entries that were overwritten.
+func (c *ClientConfig) Merge(path string) (backup string, replaced []string, err error) {
+	document := map[string]any{}
+	mode := os.FileMode(0644)
+	existing, err := os.ReadFile(path)
+	switch {
+	case err == nil:
+		if len(strings.TrimSpace(string(existing))) > 0 {
+			if err := json.Unmarshal(existing, &document); err != nil {
+				return "", nil, fmt.Errorf("failed to read %s (comments are not supported): %w", path, err)
+			}
+		}
+		if info, err := os.Stat(path); err == nil {
+			mode = info.Mode().Perm()
+		}
+		backup = fmt.Sprintf("%s.%s.bak", path, time.Now().Format("20060102-150405"))
+		if err := os.WriteFile(backup, existing, mode); err != nil {
+			return "", nil, fmt.Errorf("failed to back up %s: %w", path, err)
+		}
+	case os.IsNotExist(err):
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return "", nil, err
+		}
+	default:
+		return "", nil, err
+	}
+
+	servers, _ := document[c.serversKey()].(map[string]any)
+	if servers == nil {
+		servers = map[string]any{}
+	}
+	for name, entry := range c.Servers {
+		if _, ok := servers[name]; ok {
+			replaced = append(replaced, name)
+		}
+		servers[name] = entry
+	}
+	document[c.serversKey()] = servers
+	sort.Strings(replaced)
+
+	if len(c.Inputs) > 0 {
+		inputs, _ := document["inputs"].([]any)
+		known := map[string]bool{}
+		for _, input := range inputs {
+			if fields, ok := input.(map[string]any); ok {
+				if id, ok := fields["id"].(string); ok {
+					known[id] = true
+				}
+			}
+		}
+		for _, input := range c.Inputs {
+			if !known[input.ID] {
+				inputs = append(inputs, input)
+			}
+		}
+		document["inputs"] = inputs
+	}
+
+	content, err := json.MarshalIndent(document, "", "  ")
+	if err != nil {
+		return "", nil, err
+	}
+	if err := os.WriteFile(path, append(content, '\n'), mode); err != nil {
+		return "", nil, fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return backup, replaced, nil
+}
@@ -0,0 +1,74 @@
+package services
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"mcphub/models"
+)
+
+var envNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// validateConfig rejects transports, build steps, healthcheck settings and environment
+// variables the generated image cannot honor
+func validateConfig(config *models.MCPConfig) error {
+	switch config.Run.Transport {
+	case "", models.TransportStdio:
+	case models.TransportSSE, models.TransportHTTP:
+		if config.Run.Port <= 0 {
+			return fmt.Errorf("run.transport %q needs run.port", config.Run.Transport)
+		}
+	default:
+		return fmt.Errorf("unsupported run.transport %q (use stdio, sse or http)", config.Run.Transport)
+	}
+	if config.Run.Path != "" && !strings.HasPrefix(config.Run.Path, "/") {
+		return fmt.Errorf("run.path %q must start with /", config.Run.Path)
+	}
+
+	for _, env := range config.Env {
+		if !envNamePattern.MatchString(env.Name) {
+			return fmt.Errorf("invalid environment variable name %q", env.Name)
+		}
+	}
+
+	if build := config.Build; build != nil {
+		// A blank step would render as a bare RUN, which fails the build without saying why
+		for i, step := range build.Run {
+			if strings.TrimSpace(step) == "" {
+				return fmt.Errorf("build.run step %d is empty", i+1)
+			}
+		}
+		for i, pkg := range build.Packages {
+			if strings.TrimSpace(pkg) == "" {
+				return fmt.Errorf("build.packages entry %d is empty", i+1)
+			}
+		}
+	}
+
+	health := config.Healthcheck
+	if health == nil {
+		return nil
+	}
+	if health.Path != "" && !strings.HasPrefix(health.Path, "/") {
+		return fmt.Errorf("healthcheck.path %q must start with /", health.Path)
+	}
+	for name, value := range map[string]string{"interval": health.Interval, "timeout": health.Timeout, "start_period": health.StartPeriod} {
+		if value == "" {
+			continue
+		}
+		duration, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("invalid healthcheck.%s %q: use a duration such as 30s", name, value)
+		}
+		// Docker rejects shorter durations; only the start period may be zero
+		if duration < time.Millisecond && (name != "start_period" || duration != 0) {
+			return fmt.Errorf("healthcheck.%s %q must be at least 1ms", name, value)
+		}
+	}
+	if health.Retries < 0 {
+		return fmt.Errorf("healthcheck.retries must not be negative")
+	}
+	return nil
+}
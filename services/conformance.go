@@ -0,0 +1,38 @@
+package services
+
+import (
+	"context"
+
+	"mcphub/mcp"
+)
+
+// CheckImageConformance runs the MCP conformance suite against image in a throwaway
+// container. env holds NAME=value (or NAME, passed through) entries for the server; its
+// required variables must be given or set in this process's environment. A server that
+// cannot be started or fails the handshake fails the handshake check.
+func CheckImageConformance(ctx context.Context, rt *ContainerRuntime, image string, env []string) ([]mcp.ConformanceCheck, error) {
+	env, err := serverEnv(rt, image, env)
+	if err != nil {
+		return nil, err
+	}
+	session, err := StartSession(ctx, rt, image, env)
+	if err != nil {
+		return []mcp.ConformanceCheck{{Name: mcp.HandshakeCheck, Problems: []string{err.Error()}}}, nil
+	}
+	defer session.Close()
+	return mcp.CheckConformance(ctx, session.Client), nil
+}
+
+// serverEnv checks that image was built by MCPHub and that its required variables are given
+// or set, returning env with the set ones passed through
+func serverEnv(rt *ContainerRuntime, image string, env []string) ([]string, error) {
+	labels, err := rt.ImageLabels(image)
+	if err != nil {
+		return nil, err
+	}
+	metadata, err := serverMetadata(image, labels)
+	if err != nil {
+		return nil, err
+	}
+	return forwardEnv(metadata, env)
+}
@@ -1,89 +1,264 @@
 package services
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
 	"strings"
+	"unicode"
 
 	"mcphub/models"
 )
 
-type DockerfileGenerator struct{}
+// Non-root account every generated image runs as
+const (
+	runtimeUser = "mcp"
+	runtimeUID  = 10001
+)
+
+// DockerfileGenerator renders Dockerfiles from the built-in templates, overridden by the
+// templates in templatesDir when one is set
+type DockerfileGenerator struct {
+	templatesDir string
+}
 
 func NewDockerfileGenerator() *DockerfileGenerator {
 	return &DockerfileGenerator{}
 }
 
-func (dg *DockerfileGenerator) Generate(config *models.MCPConfig) string {
+// NewDockerfileGeneratorWithTemplates returns a generator using the custom templates in dir
+// (see loadTemplates); an empty dir uses the built-in templates only
+func NewDockerfileGeneratorWithTemplates(dir string) *DockerfileGenerator {
+	return &DockerfileGenerator{templatesDir: dir}
+}
+
+// Generate returns a multi-stage Dockerfile: a builder stage installs dependencies or compiles
+// the server and a slim runtime stage receives only the result and runs it as a non-root user
+func (dg *DockerfileGenerator) Generate(config *models.MCPConfig) (string, error) {
+	return dg.GenerateForProject(config, nil)
+}
+
+// GenerateForProject is Generate with the runtime version and base image lock detected
+// from the project's files
+func (dg *DockerfileGenerator) GenerateForProject(config *models.MCPConfig, project *ProjectInfo) (string, error) {
+	rt := runtimeFor(config)
+	tmpl, err := dg.loadTemplates(rt)
+	if err != nil {
+		return "", err
+	}
+
 	var dockerfile strings.Builder
+	if err := tmpl.ExecuteTemplate(&dockerfile, dockerfileTemplate, dg.templateData(config, project, rt)); err != nil {
+		return "", fmt.Errorf("failed to render Dockerfile template: %w", err)
+	}
+	return dockerfile.String(), nil
+}
 
-	// Determine base image
-	baseImage := dg.getBaseImage(config.Run.Command)
-	dockerfile.WriteString(fmt.Sprintf("FROM %s\n\n", baseImage))
+// templateData computes everything the templates render, already escaped for the Dockerfile
+func (dg *DockerfileGenerator) templateData(config *models.MCPConfig, project *ProjectInfo, rt *languageRuntime) *DockerfileData {
+	build := config.Build
+	if build == nil {
+		build = &models.BuildConfig{}
+	}
 
-	// Set working directory
-	dockerfile.WriteString("WORKDIR /app\n\n")
+	builderImage, runtimeImage := dg.BaseImages(config, project)
+	data := &DockerfileData{
+		Config:          config,
+		Runtime:         defaultString(rt.Name, "default"),
+		BuilderImage:    singleLine(dg.pinImage(builderImage, project)),
+		RuntimeImage:    singleLine(dg.pinImage(runtimeImage, project)),
+		BuildArgs:       dg.buildArgNames(build.Args),
+		BuilderPackages: dg.packagesCommand(builderImage, build.Packages),
+		RuntimePackages: dg.packagesCommand(runtimeImage, build.Packages),
+		Setup:           rt.Setup,
+		Install:         dg.installStep(rt, project),
+		Env:             dg.envDefaults(config.Env),
+		CreateUser:      dg.createUserCommand(runtimeImage),
+		Copy:            dg.runtimeCopy(rt, config.Run),
+		User:            runtimeUser,
+		Port:            config.Run.Port,
+		Healthcheck:     dg.healthcheck(config, runtimeImage),
+		Cmd:             dg.formatCommand(rt.command(config.Run)),
+	}
+	if rt.Build != nil {
+		data.Build = rt.Build(config.Run)
+	}
 
-	// Metadata
-	dockerfile.WriteString(fmt.Sprintf("LABEL name=\"%s\"\n", config.Name))
-	dockerfile.WriteString(fmt.Sprintf("LABEL version=\"%s\"\n", config.Version))
-	dockerfile.WriteString(fmt.Sprintf("LABEL description=\"%s\"\n", config.Description))
-	if config.Author != "" {
-		dockerfile.WriteString(fmt.Sprintf("LABEL author=\"%s\"\n", config.Author))
+	// Metadata: OCI annotations and what MCPHub needs to run the server
+	labels := MetadataLabels(config)
+	for _, key := range sortedKeys(labels) {
+		data.Labels = append(data.Labels, fmt.Sprintf("%s=%s", key, labelValue(labels[key])))
 	}
-	dockerfile.WriteString("\n")
 
-	// Copy app files
-	dockerfile.WriteString("COPY . .\n\n")
+	// Custom steps run as root in the final image
+	for _, step := range build.Run {
+		data.RunSteps = append(data.RunSteps, dg.runStep(step))
+	}
+	return data
+}
 
-	// Dependency installation
-	dg.addInstallCommands(&dockerfile, config.Run.Command)
+// BaseImages returns the builder and runtime stage images. The runtime version comes from
+// mcp.json or, failing that, the project's version files. A custom base image replaces the
+// runtime image, and also the builder image for interpreted languages whose stages share one.
+func (dg *DockerfileGenerator) BaseImages(config *models.MCPConfig, project *ProjectInfo) (string, string) {
+	version := ""
+	if project != nil {
+		version = project.RuntimeVersion
+	}
+	if config.Runtime != nil && config.Runtime.Version != "" {
+		version = config.Runtime.Version
+	}
+
+	rt := runtimeFor(config)
+	version = rt.imageVersion(version)
+
+	builderImage := rt.builderImage(version)
+	runtimeImage := rt.runtimeImage(version)
+	if config.Build == nil || config.Build.BaseImage == "" {
+		return builderImage, runtimeImage
+	}
+	if builderImage == runtimeImage {
+		return config.Build.BaseImage, config.Build.BaseImage
+	}
+	return builderImage, config.Build.BaseImage
+}
 
-	// Expose port
-	if config.Run.Port > 0 {
-		dockerfile.WriteString(fmt.Sprintf("EXPOSE %d\n\n", config.Run.Port))
+// pinImage appends the locked digest of image, if any
+func (dg *DockerfileGenerator) pinImage(image string, project *ProjectInfo) string {
+	if project == nil || project.Lock == nil || strings.Contains(image, "@") {
+		return image
+	}
+	if digest := project.Lock.Images[image]; digest != "" {
+		return image + "@" + digest
+	}
+	return image
+}
 
-		// Optional healthcheck
-		dockerfile.WriteString("HEALTHCHECK --interval=30s --timeout=3s --start-period=5s --retries=3 \\\n")
-		dockerfile.WriteString(fmt.Sprintf("  CMD curl -f http://localhost:%d/health || exit 1\n\n", config.Run.Port))
+func defaultString(value, fallback string) string {
+	if value == "" {
+		return fallback
 	}
+	return value
+}
 
-	// Set CMD
-	cmdArgs := append([]string{config.Run.Command}, config.Run.Args...)
-	dockerfile.WriteString(fmt.Sprintf("CMD %s\n", dg.formatCommand(cmdArgs)))
+// installStep copies the sources into the builder stage and installs dependencies. With a
+// detected install plan the manifests and lockfiles are copied first so the install layer
+// stays cached until they change; without one, every known manager is tried in turn.
+func (dg *DockerfileGenerator) installStep(rt *languageRuntime, project *ProjectInfo) *InstallStep {
+	if project == nil {
+		return &InstallStep{Command: strings.TrimPrefix(rt.FallbackInstall, "RUN ")}
+	}
+	plan := project.Install
+	if plan == nil {
+		return &InstallStep{}
+	}
+	command := strings.Join(plan.Commands, " && ")
+	if plan.NeedsSource {
+		return &InstallStep{Command: command}
+	}
 
-	return dockerfile.String()
+	step := &InstallStep{Command: command, Project: strings.Join(plan.ProjectCommands, " && ")}
+	var files []string
+	for _, file := range plan.Files {
+		if file == ".yarn" {
+			step.CopyFirst = append(step.CopyFirst, "COPY .yarn ./.yarn")
+			continue
+		}
+		files = append(files, file)
+	}
+	step.CopyFirst = append(step.CopyFirst, fmt.Sprintf("COPY %s ./", strings.Join(files, " ")))
+	return step
 }
 
-func (dg *DockerfileGenerator) getBaseImage(command string) string {
-	switch command {
-	case "node":
-		return "node:18-alpine"
-	case "python", "python3":
-		return "python:3.11-slim"
-	case "go":
-		return "golang:1.21-alpine"
-	default:
-		return "ubuntu:22.04"
+// runtimeCopy copies the builder output into the runtime stage, owned by the runtime user
+func (dg *DockerfileGenerator) runtimeCopy(rt *languageRuntime, run models.RunConfig) []string {
+	chown := fmt.Sprintf("--chown=%s:%s", runtimeUser, runtimeUser)
+	if rt.Copy == nil {
+		return []string{copyApp(chown)}
 	}
+	return rt.Copy(run, chown)
 }
 
-func (dg *DockerfileGenerator) addInstallCommands(dockerfile *strings.Builder, command string) {
-	switch command {
-	case "node":
-		dockerfile.WriteString("RUN if [ -f package.json ]; then npm install --only=production; fi\n")
-		dockerfile.WriteString("RUN if [ -f yarn.lock ]; then yarn install --production; fi\n\n")
-	case "python", "python3":
-		dockerfile.WriteString("RUN if [ -f requirements.txt ]; then pip install --no-cache-dir -r requirements.txt; fi\n")
-		dockerfile.WriteString("RUN if [ -f pyproject.toml ]; then pip install uv && uv pip install --system .; fi\n")
-		dockerfile.WriteString("RUN if [ -f Pipfile ]; then pip install pipenv && pipenv install --system --deploy; fi\n\n")
-	case "go":
-		dockerfile.WriteString("RUN if [ -f go.mod ]; then go mod download; fi\n")
-		dockerfile.WriteString("RUN if [ -f go.mod ]; then go build -o main .; fi\n\n")
-	default:
-		dockerfile.WriteString("# Add any custom installation commands here\n\n")
+// envDefaults sets the default values of non-secret environment variables; secrets are
+// never baked into the image
+func (dg *DockerfileGenerator) envDefaults(env []models.EnvVar) []string {
+	var defaults []string
+	for _, variable := range env {
+		if variable.Secret || variable.Default == "" || !envNamePattern.MatchString(variable.Name) {
+			continue
+		}
+		defaults = append(defaults, fmt.Sprintf("%s=%s", variable.Name, labelValue(variable.Default)))
 	}
+	return defaults
 }
 
+// buildArgNames returns the build arguments declared in each stage; values are passed with --build-arg
+func (dg *DockerfileGenerator) buildArgNames(args map[string]string) []string {
+	var names []string
+	for _, name := range sortedKeys(args) {
+		names = append(names, singleLine(name))
+	}
+	return names
+}
+
+// packagesCommand installs extra system packages with the package manager of the image's distribution
+func (dg *DockerfileGenerator) packagesCommand(image string, packages []string) string {
+	if len(packages) == 0 {
+		return ""
+	}
+	var quoted []string
+	for _, pkg := range packages {
+		quoted = append(quoted, shellQuote(pkg))
+	}
+	list := strings.Join(quoted, " ")
+	if strings.Contains(image, "alpine") {
+		return "apk add --no-cache " + list
+	}
+	return fmt.Sprintf("apt-get update && apt-get install -y --no-install-recommends %s && rm -rf /var/lib/apt/lists/*", list)
+}
+
+// createUserCommand adds the runtime user with the tools of the image's distribution
+func (dg *DockerfileGenerator) createUserCommand(image string) string {
+	if strings.Contains(image, "alpine") {
+		return fmt.Sprintf("addgroup -S -g %d %s && adduser -S -D -u %d -G %s -h /home/%s %s",
+			runtimeUID, runtimeUser, runtimeUID, runtimeUser, runtimeUser, runtimeUser)
+	}
+	return fmt.Sprintf("groupadd --system --gid %d %s && useradd --system --uid %d --gid %s --create-home --shell /usr/sbin/nologin %s",
+		runtimeUID, runtimeUser, runtimeUID, runtimeUser, runtimeUser)
+}
+
+// splitGoRun splits "go run [build flags] <package|files...> [program args]" arguments into
+// the build target and the program arguments. Anything else builds the current package.
+func splitGoRun(args []string) ([]string, []string) {
+	if len(args) == 0 || args[0] != "run" {
+		return []string{"."}, args
+	}
+	args = args[1:]
+
+	var target []string
+	for len(args) > 0 && strings.HasPrefix(args[0], "-") {
+		target = append(target, args[0])
+		args = args[1:]
+	}
+
+	hasPackage := false
+	for len(args) > 0 && (strings.HasSuffix(args[0], ".go") || !hasPackage && isGoPackagePath(args[0])) {
+		target = append(target, args[0])
+		hasPackage = hasPackage || !strings.HasSuffix(args[0], ".go")
+		args = args[1:]
+	}
+	if len(target) == 0 || strings.HasPrefix(target[len(target)-1], "-") {
+		target = append(target, ".")
+	}
+	return target, args
+}
+
+func isGoPackagePath(arg string) bool {
+	return arg == "." || strings.HasPrefix(arg, "./") || strings.HasPrefix(arg, "../")
+}
+
+// formatCommand returns the exec (JSON) form of a command, which Docker does not
+// interpret further, so quotes, backslashes and newlines in arguments survive as is
 func (dg *DockerfileGenerator) formatCommand(cmdArgs []string) string {
 	if len(cmdArgs) == 0 {
 		return "[\"\"]"
@@ -91,8 +266,71 @@ func (dg *DockerfileGenerator) formatCommand(cmdArgs []string) string {
 
 	var quotedArgs []string
 	for _, arg := range cmdArgs {
-		quotedArgs = append(quotedArgs, fmt.Sprintf("\"%s\"", arg))
+		quotedArgs = append(quotedArgs, jsonString(arg))
 	}
 
 	return fmt.Sprintf("[%s]", strings.Join(quotedArgs, ", "))
 }
+
+// runStep returns a RUN instruction for a build.run step. Multi-line steps, and steps that
+// would continue onto the next line or open a heredoc, use the exec form so they reach the
+// shell as is instead of ending or extending the instruction.
+func (dg *DockerfileGenerator) runStep(step string) string {
+	if !strings.ContainsAny(step, "\r\n") && !endsWithContinuation(step) && !strings.Contains(step, "<<") {
+		return "RUN " + step
+	}
+	return "RUN " + dg.formatCommand([]string{"/bin/sh", "-c", step})
+}
+
+// jsonString quotes value as a JSON string without HTML escaping
+func jsonString(value string) string {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	encoder.Encode(value)
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+// labelValue double-quotes value for a LABEL or ENV instruction. Backslashes, quotes and "$" are
+// escaped so the value is taken literally, and line breaks become a literal "\n" so
+// multi-line descriptions stay on one instruction line.
+func labelValue(value string) string {
+	var quoted strings.Builder
+	quoted.WriteByte('"')
+	for _, c := range strings.ReplaceAll(value, "\r\n", "\n") {
+		switch {
+		case c == '\\' || c == '"' || c == '$':
+			quoted.WriteRune('\\')
+			quoted.WriteRune(c)
+		case c == '\n' || c == '\r':
+			quoted.WriteString(`\n`)
+		case unicode.IsControl(c):
+		default:
+			quoted.WriteRune(c)
+		}
+	}
+	quoted.WriteByte('"')
+	return quoted.String()
+}
+
+// singleLine replaces control characters, line breaks included, with spaces and drops a
+// trailing line continuation so a value interpolated into an instruction cannot start or
+// swallow another one
+func singleLine(value string) string {
+	value = strings.Map(func(c rune) rune {
+		if unicode.IsControl(c) {
+			return ' '
+		}
+		return c
+	}, value)
+	for endsWithContinuation(value) {
+		value = strings.TrimRight(value, " \t")
+		value = strings.TrimSuffix(value, `\`)
+	}
+	return value
+}
+
+// endsWithContinuation reports whether a Dockerfile line ending in line continues on the next one
+func endsWithContinuation(line string) bool {
+	return strings.HasSuffix(strings.TrimRight(line, " \t"), `\`)
+}
@@ -0,0 +1,125 @@
+package services
+
+import (
+	"embed"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"mcphub/models"
+)
+
+// builtinTemplates holds the default Dockerfile templates compiled into the binary
+//
+//go:embed templates/*.tmpl
+var builtinTemplates embed.FS
+
+// dockerfileTemplate is the template rendered to produce a Dockerfile
+const dockerfileTemplate = "Dockerfile"
+
+// DockerfileData is what the Dockerfile templates render. Values are already escaped for
+// the instruction they appear in.
+type DockerfileData struct {
+	Config *models.MCPConfig
+	// Runtime is the name of the server's language runtime, e.g. node or python ("default" when unknown)
+	Runtime string
+	// BuilderImage and RuntimeImage are the base images, pinned by digest when locked
+	BuilderImage string
+	RuntimeImage string
+	// BuildArgs are the names of the build arguments declared in both stages
+	BuildArgs []string
+	// BuilderPackages and RuntimePackages install build.packages (a RUN command, empty for none)
+	BuilderPackages string
+	RuntimePackages string
+	// Setup, Build, Copy and RunSteps are complete Dockerfile instructions
+	Setup   []string
+	Install *InstallStep
+	Build   []string
+	// Labels and Env are KEY="value" pairs for LABEL and ENV instructions
+	Labels     []string
+	Env        []string
+	CreateUser string
+	Copy       []string
+	RunSteps   []string
+	User       string
+	Port       int
+	// Healthcheck is nil when the server gets no HEALTHCHECK instruction
+	Healthcheck *HealthcheckStep
+	// Cmd is the exec form of the server's command
+	Cmd string
+}
+
+// InstallStep copies the sources into the builder stage and installs dependencies
+type InstallStep struct {
+	// CopyFirst are COPY instructions for the manifests and lockfiles needed by Command;
+	// when empty all sources are copied before it
+	CopyFirst []string
+	// Command is the install command run after CopyFirst (empty for none)
+	Command string
+	// Project installs the project itself after all sources are copied (empty for none)
+	Project string
+}
+
+// HealthcheckStep is the HEALTHCHECK instruction of the runtime stage
+type HealthcheckStep struct {
+	Disabled bool
+	// Options are the --interval, --timeout, --start-period and --retries flags
+	Options string
+	// Command is the exec form of the probe
+	Command string
+}
+
+// templateFuncs are available to custom templates for quoting their own values
+var templateFuncs = template.FuncMap{
+	"join":       strings.Join,
+	"quote":      labelValue,
+	"json":       jsonString,
+	"shellQuote": shellQuote,
+}
+
+// loadTemplates parses the built-in templates, then the *.tmpl files of the custom templates
+// directory and of its subdirectory named after the runtime (e.g. node/, python/ or default/).
+// Blocks defined with {{define}} in later files replace those of earlier ones.
+func (dg *DockerfileGenerator) loadTemplates(rt *languageRuntime) (*template.Template, error) {
+	tmpl, err := template.New(dockerfileTemplate).Funcs(templateFuncs).ParseFS(builtinTemplates, "templates/*.tmpl")
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse built-in templates: %w", err)
+	}
+	if dg.templatesDir == "" {
+		return tmpl, nil
+	}
+
+	info, err := os.Stat(dg.templatesDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read templates directory: %w", err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("templates path %s is not a directory", dg.templatesDir)
+	}
+
+	for _, dir := range []string{dg.templatesDir, filepath.Join(dg.templatesDir, defaultString(rt.Name, "default"))} {
+		files, err := filepath.Glob(filepath.Join(dir, "*.tmpl"))
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range files {
+			content, err := os.ReadFile(file)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read template %s: %w", file, err)
+			}
+			// Each file gets its own name so its top-level text never replaces a built-in block
+			if _, err := tmpl.New(file).Parse(string(content)); err != nil {
+				return nil, fmt.Errorf("failed to parse template %s: %w", file, err)
+			}
+		}
+	}
+	return tmpl, nil
+}
+
+// BuiltinTemplate returns the built-in Dockerfile template, a starting point for custom ones
+func BuiltinTemplate() string {
+	content, _ := builtinTemplates.ReadFile("templates/Dockerfile.tmpl")
+	return string(content)
+}
@@ -0,0 +1,314 @@
+package services
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"mcphub/models"
+)
+
+// DockerignoreFileName is the file listing paths left out of the build context
+const DockerignoreFileName = ".dockerignore"
+
+// commonIgnorePatterns keep version control data, editor files, logs and secrets out of
+// every generated image
+var commonIgnorePatterns = []string{
+	".git",
+	".hg",
+	".svn",
+	".idea",
+	".vscode",
+	"**/.DS_Store",
+	"logs",
+	"**/*.log",
+	".env",
+	".env.*",
+	"!.env.example",
+	"**/*.pem",
+	"**/*.key",
+}
+
+// secretPatterns match the base names of files that look like credentials
+var secretPatterns = []string{".env", ".env.*", "*.pem", "*.key", "*.p12", "*.pfx", "id_rsa", "id_ecdsa", "id_ed25519", ".netrc", "credentials.json"}
+
+// secretExceptions are templates that match secretPatterns but hold no secrets
+var secretExceptions = []string{".env.example", ".env.sample", ".env.template"}
+
+// GenerateDockerignore returns the .dockerignore for a generated build: the common patterns,
+// those of the server's runtime, then the project's own .dockerignore (if any) so its
+// patterns and "!" exceptions take precedence
+func GenerateDockerignore(config *models.MCPConfig, projectIgnore string) string {
+	rt := runtimeFor(config)
+
+	var content strings.Builder
+	content.WriteString("# Generated by mcphub\n")
+	for _, pattern := range commonIgnorePatterns {
+		content.WriteString(pattern + "\n")
+	}
+	if len(rt.Ignore) > 0 {
+		content.WriteString(fmt.Sprintf("\n# %s\n", defaultString(rt.Name, "runtime")))
+		for _, pattern := range rt.Ignore {
+			content.WriteString(pattern + "\n")
+		}
+	}
+	if strings.TrimSpace(projectIgnore) != "" {
+		content.WriteString("\n# From the project's .dockerignore\n")
+		content.WriteString(strings.TrimRight(projectIgnore, "\n") + "\n")
+	}
+	return content.String()
+}
+
+// writeDockerignore merges the generated patterns into the project's .dockerignore
+func writeDockerignore(config *models.MCPConfig, dir string) error {
+	ignorePath := filepath.Join(dir, DockerignoreFileName)
+	projectIgnore, err := os.ReadFile(ignorePath)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read %s: %w", DockerignoreFileName, err)
+	}
+	if strings.HasPrefix(string(projectIgnore), "# Generated by mcphub\n") {
+		// Already merged by an earlier run on the same directory
+		return nil
+	}
+	if err := os.WriteFile(ignorePath, []byte(GenerateDockerignore(config, string(projectIgnore))), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", DockerignoreFileName, err)
+	}
+	return nil
+}
+
+// ContextFile is a file sent to the container runtime as part of the build context
+type ContextFile struct {
+	// Path is slash-separated and relative to the context directory
+	Path string
+	Size int64
+}
+
+// ContextReport summarizes the build context after .dockerignore is applied
+type ContextReport struct {
+	Files []ContextFile
+	Size  int64
+	// Secrets are context files that look like credentials
+	Secrets []string
+}
+
+// InspectBuildContext lists the files of dir that the build context will contain and flags
+// the ones that look like secrets
+func InspectBuildContext(dir string) (*ContextReport, error) {
+	files, err := buildContextFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &ContextReport{Files: files}
+	for _, file := range files {
+		report.Size += file.Size
+		if looksLikeSecret(file.Path) {
+			report.Secrets = append(report.Secrets, file.Path)
+		}
+	}
+	return report, nil
+}
+
+// buildContextFiles walks dir and returns the files .dockerignore does not exclude, sorted.
+// Like the container runtimes, the Dockerfile and .dockerignore are always sent.
+func buildContextFiles(dir string) ([]ContextFile, error) {
+	matcher, err := readDockerignore(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []ContextFile
+	err = filepath.Walk(dir, func(walkPath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, walkPath)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if rel != "Dockerfile" && rel != DockerignoreFileName && matcher.Excluded(rel) {
+			return nil
+		}
+		files = append(files, ContextFile{Path: rel, Size: info.Size()})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+	return files, nil
+}
+
+func looksLikeSecret(file string) bool {
+	base := path.Base(file)
+	for _, exception := range secretExceptions {
+		if base == exception {
+			return false
+		}
+	}
+	for _, pattern := range secretPatterns {
+		if matched, _ := path.Match(pattern, base); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// ignoreMatcher applies .dockerignore patterns in order; the last matching one decides
+type ignoreMatcher struct {
+	patterns []ignorePattern
+}
+
+type ignorePattern struct {
+	regexp    *regexp.Regexp
+	exclusion bool
+}
+
+// readDockerignore loads the .dockerignore of dir; without one nothing is excluded
+func readDockerignore(dir string) (*ignoreMatcher, error) {
+	file, err := os.Open(filepath.Join(dir, DockerignoreFileName))
+	if os.IsNotExist(err) {
+		return &ignoreMatcher{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", DockerignoreFileName, err)
+	}
+	defer file.Close()
+
+	var lines []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", DockerignoreFileName, err)
+	}
+	return newIgnoreMatcher(lines)
+}
+
+// newIgnoreMatcher compiles .dockerignore lines with Docker's rules: patterns are relative to
+// the context root, "*" and "?" stay within a path segment, "**" spans segments, a leading
+// "!" re-includes paths and a pattern matching a directory covers everything below it
+func newIgnoreMatcher(lines []string) (*ignoreMatcher, error) {
+	matcher := &ignoreMatcher{}
+	for _, line := range lines {
+		pattern := strings.TrimSpace(line)
+		if pattern == "" || strings.HasPrefix(pattern, "#") {
+			continue
+		}
+
+		exclusion := strings.HasPrefix(pattern, "!")
+		if exclusion {
+			pattern = strings.TrimSpace(pattern[1:])
+		}
+		pattern = strings.TrimPrefix(path.Clean(filepath.ToSlash(pattern)), "/")
+		if pattern == "" || pattern == "." {
+			continue
+		}
+
+		compiled, err := compileIgnorePattern(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s pattern %q: %w", DockerignoreFileName, line, err)
+		}
+		matcher.patterns = append(matcher.patterns, ignorePattern{regexp: compiled, exclusion: exclusion})
+	}
+	return matcher, nil
+}
+
+func compileIgnorePattern(pattern string) (*regexp.Regexp, error) {
+	var expr strings.Builder
+	expr.WriteString("^")
+
+	runes := []rune(pattern)
+	for i := 0; i < len(runes); i++ {
+		switch c := runes[i]; c {
+		case '*':
+			if i+1 < len(runes) && runes[i+1] == '*' {
+				i++
+				if i+1 < len(runes) && runes[i+1] == '/' {
+					i++
+					expr.WriteString("(.*/)?")
+				} else {
+					expr.WriteString(".*")
+				}
+			} else {
+				expr.WriteString("[^/]*")
+			}
+		case '?':
+			expr.WriteString("[^/]")
+		case '[':
+			end := i + 1
+			for end < len(runes) && runes[end] != ']' {
+				end++
+			}
+			if end == len(runes) {
+				return nil, fmt.Errorf("unterminated character class")
+			}
+			class := string(runes[i+1 : end])
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			expr.WriteString("[" + class + "]")
+			i = end
+		case '\\':
+			if i+1 < len(runes) {
+				i++
+				expr.WriteString(regexp.QuoteMeta(string(runes[i])))
+			}
+		default:
+			expr.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+
+	expr.WriteString("$")
+	return regexp.Compile(expr.String())
+}
+
+// Excluded reports whether the slash-separated path is left out of the build context
+func (m *ignoreMatcher) Excluded(file string) bool {
+	excluded := false
+	for _, pattern := range m.patterns {
+		if pattern.matches(file) {
+			excluded = !pattern.exclusion
+		}
+	}
+	return excluded
+}
+
+// matches checks the path and each of its parent directories
+func (p ignorePattern) matches(file string) bool {
+	for candidate := file; ; {
+		if p.regexp.MatchString(candidate) {
+			return true
+		}
+		parent := path.Dir(candidate)
+		if parent == "." || parent == "/" || parent == candidate {
+			return false
+		}
+		candidate = parent
+	}
+}
+
+// formatSize renders a byte count for humans, e.g. "12.3 MB"
+func formatSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(size)/float64(div), "KMGTPE"[exp])
+}
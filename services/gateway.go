@@ -0,0 +1,150 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"mcphub/mcp"
+)
+
+// gatewayAttachTimeout bounds connecting to a server joining the gateway
+const gatewayAttachTimeout = 30 * time.Second
+
+// ManagedContainers lists the running containers started by `mcphub run`
+func (r *ContainerRuntime) ManagedContainers() ([]string, error) {
+	output, err := r.Command("ps", "--filter", "label="+LabelManaged+"=true", "--format", "{{.Names}}").Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list containers: %w", err)
+	}
+	var names []string
+	for _, line := range strings.Split(string(output), "\n") {
+		if name := strings.TrimSpace(line); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
+
+// ServerAddress returns the host address a running container of image started by
+// `mcphub run` publishes port on, or "" when there is none
+func (r *ContainerRuntime) ServerAddress(image string, port int) string {
+	output, err := r.Command("ps", "--filter", "label="+LabelManaged+"=true", "--filter", "ancestor="+image, "--format", "{{.Names}}").Output()
+	if err != nil {
+		return ""
+	}
+	for _, line := range strings.Split(string(output), "\n") {
+		if name := strings.TrimSpace(line); name != "" {
+			if address, err := r.publishedAddress(name, port); err == nil {
+				return address
+			}
+		}
+	}
+	return ""
+}
+
+// SyncGateway keeps gateway in sync with the running servers started by `mcphub run`,
+// containers and background bridges (only those named in servers, when given), until ctx
+// ends, looking every interval. Servers that start are attached; those that stop or stop
+// answering are removed.
+func SyncGateway(ctx context.Context, rt *ContainerRuntime, gateway *mcp.Gateway, servers []string, interval time.Duration, logf func(format string, args ...any)) {
+	selected := map[string]bool{}
+	for _, server := range servers {
+		selected[server] = true
+	}
+	sessions := map[string]*Session{}
+	// failures holds the last error of each server that could not be attached, reported once
+	failures := map[string]string{}
+	defer func() {
+		for name, session := range sessions {
+			gateway.Remove(name)
+			session.Close()
+		}
+	}()
+
+	for {
+		running, err := runningServers(rt)
+		if err != nil {
+			logf("⚠️  %v", err)
+		} else {
+			wanted := map[string]bool{}
+			var names []string
+			for name := range running {
+				if len(selected) == 0 || selected[name] {
+					wanted[name] = true
+					names = append(names, name)
+				}
+			}
+			sort.Strings(names)
+
+			for name, session := range sessions {
+				if wanted[name] && answers(ctx, session) {
+					continue
+				}
+				gateway.Remove(name)
+				session.Close()
+				delete(sessions, name)
+				logf("➖ %s left the gateway", name)
+			}
+
+			for _, name := range names {
+				if sessions[name] != nil {
+					continue
+				}
+				attachCtx, cancel := context.WithTimeout(ctx, gatewayAttachTimeout)
+				session, err := running[name](attachCtx)
+				cancel()
+				if err != nil {
+					if failures[name] != err.Error() {
+						failures[name] = err.Error()
+						logf("⚠️  Failed to attach %s: %v", name, err)
+					}
+					continue
+				}
+				delete(failures, name)
+				sessions[name] = session
+				gateway.Add(name, session.Client)
+				logf("➕ %s joined the gateway (%s %s)", name, session.Info.ServerInfo.Name, session.Info.ServerInfo.Version)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+	}
+}
+
+// runningServers returns how to attach each running server started by `mcphub run`, by
+// name. A container takes precedence over a background bridge of the same name.
+func runningServers(rt *ContainerRuntime) (map[string]func(ctx context.Context) (*Session, error), error) {
+	containers, err := rt.ManagedContainers()
+	if err != nil {
+		return nil, err
+	}
+	bridges, err := BackgroundBridges()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list the bridges: %w", err)
+	}
+
+	running := map[string]func(ctx context.Context) (*Session, error){}
+	for _, name := range containers {
+		running[name] = func(ctx context.Context) (*Session, error) { return AttachSession(ctx, rt, name) }
+	}
+	for _, bridge := range bridges {
+		if running[bridge.Name] == nil {
+			running[bridge.Name] = func(ctx context.Context) (*Session, error) { return AttachBridge(ctx, bridge) }
+		}
+	}
+	return running, nil
+}
+
+// answers pings the server of a session
+func answers(ctx context.Context, session *Session) bool {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	return session.Client.Ping(ctx) == nil
+}
@@ -0,0 +1,117 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"mcphub/models"
+)
+
+// Healthcheck timing used when mcp.json does not set it
+const (
+	defaultHealthInterval    = "30s"
+	defaultHealthTimeout     = "5s"
+	defaultHealthStartPeriod = "10s"
+	defaultHealthRetries     = 3
+)
+
+// MCP messages the built-in probe of http servers sends
+const (
+	healthcheckInitialize  = `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2025-03-26","capabilities":{},"clientInfo":{"name":"mcphub-healthcheck","version":"1.0.0"}}}`
+	healthcheckInitialized = `{"jsonrpc":"2.0","method":"notifications/initialized"}`
+	healthcheckPing        = `{"jsonrpc":"2.0","id":2,"method":"ping"}`
+)
+
+// healthcheckSessionFile keeps the session of the http probe from one run to the next
+const healthcheckSessionFile = "/tmp/mcphub-health-session"
+
+// healthcheck returns the HEALTHCHECK instruction's parts. Servers on the sse or http transport
+// are probed over MCP with tools the runtime image already has (busybox wget on Alpine,
+// bash elsewhere), so nothing like curl has to be installed. stdio servers get none.
+func (dg *DockerfileGenerator) healthcheck(config *models.MCPConfig, image string) *HealthcheckStep {
+	health := config.Healthcheck
+	if health == nil {
+		health = &models.HealthcheckConfig{}
+	}
+	if health.Disabled {
+		return &HealthcheckStep{Disabled: true}
+	}
+
+	probe := healthcheckCommand(config.Run, health.Path, strings.Contains(image, "alpine"))
+	if probe == nil {
+		return nil
+	}
+
+	retries := health.Retries
+	if retries <= 0 {
+		retries = defaultHealthRetries
+	}
+	return &HealthcheckStep{
+		Options: fmt.Sprintf("--interval=%s --timeout=%s --start-period=%s --retries=%d",
+			healthDuration(health.Interval, defaultHealthInterval),
+			healthDuration(health.Timeout, defaultHealthTimeout),
+			healthDuration(health.StartPeriod, defaultHealthStartPeriod),
+			retries),
+		Command: dg.formatCommand(probe),
+	}
+}
+
+// healthcheckCommand returns the probe for a server: an MCP ping (after an initialize on the
+// first run) that must return a result on the http transport, the endpoint event on sse, or
+// a successful GET of path when one is configured. It returns nil when the server has no
+// port to probe.
+func healthcheckCommand(run models.RunConfig, path string, alpine bool) []string {
+	transport := run.EffectiveTransport()
+	if run.Port <= 0 || transport == models.TransportStdio {
+		return nil
+	}
+
+	url := fmt.Sprintf("http://127.0.0.1:%d%s", run.Port, defaultString(path, run.EndpointPath()))
+	if alpine {
+		switch {
+		case path != "" || transport == models.TransportSSE:
+			// The SSE stream never ends, so only the response status is checked
+			return []string{"wget", "-q", "--spider", url}
+		default:
+			return []string{"sh", "-c", sessionProbe(fmt.Sprintf(
+				`post() { wget -S -q -O - --header 'Content-Type: application/json' --header 'Accept: application/json, text/event-stream' ${1:+--header "Mcp-Session-Id: $1"} --post-data "$2" %s 2>&1; }`,
+				shellQuote(url)))}
+		}
+	}
+
+	// bash talks HTTP/1.0 over /dev/tcp so the server closes the connection after responding
+	connect := fmt.Sprintf("exec 3<>/dev/tcp/127.0.0.1/%d && ", run.Port)
+	get := "printf 'GET %s HTTP/1.0\\r\\nHost: localhost\\r\\nAccept: text/event-stream, */*\\r\\n\\r\\n' "
+	switch {
+	case path != "":
+		return []string{"bash", "-c", connect + get + shellQuote(path) + " >&3 && head -n 1 <&3 | grep -q ' 2[0-9][0-9] '"}
+	case transport == models.TransportSSE:
+		return []string{"bash", "-c", connect + get + shellQuote(run.EndpointPath()) + " >&3 && grep -q '^event: *endpoint' <&3"}
+	default:
+		return []string{"bash", "-c", sessionProbe(fmt.Sprintf(
+			`post() { exec 3<>/dev/tcp/127.0.0.1/%d && printf 'POST %%s HTTP/1.0\r\nHost: localhost\r\nContent-Type: application/json\r\nAccept: application/json, text/event-stream\r\n%%bContent-Length: %%d\r\n\r\n%%s' %s "${1:+Mcp-Session-Id: $1\r\n}" ${#2} "$2" >&3 && cat <&3; }`,
+			run.Port, shellQuote(run.EndpointPath())))}
+	}
+}
+
+// sessionProbe is the probe of http servers around a shell function post, which sends its
+// second argument in the session given by the first (if any) and prints the response with
+// its headers. The session of the first run is pinged by the next ones, so servers do not
+// keep a session per probe; a failed ping starts a new one.
+func sessionProbe(post string) string {
+	return post + "; " + fmt.Sprintf(
+		`f=%s; id=$(cat $f 2>/dev/null); [ -n "$id" ] && post "$id" '%s' | grep -q '"result"' && exit 0; `+
+			`r=$(post '' '%s') && echo "$r" | grep -q '"result"' || exit 1; `+
+			`id=$(echo "$r" | sed -n 's/^ *[Mm]cp-[Ss]ession-[Ii]d: *//p' | tr -d '\r'); echo "$id" > $f; `+
+			`[ -z "$id" ] || post "$id" '%s' > /dev/null; exit 0`,
+		healthcheckSessionFile, healthcheckPing, healthcheckInitialize, healthcheckInitialized)
+}
+
+// healthDuration returns value when it is a valid, non-negative duration and fallback otherwise
+func healthDuration(value, fallback string) string {
+	if duration, err := time.ParseDuration(value); err != nil || duration < 0 {
+		return fallback
+	}
+	return value
+}
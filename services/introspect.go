@@ -0,0 +1,120 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"mcphub/mcp"
+)
+
+// LabelIntrospection holds what the server reported about itself after it was built, as JSON
+const LabelIntrospection = "io.mcphub.introspection"
+
+// introspectionTimeout bounds starting the server and listing what it offers
+const introspectionTimeout = 90 * time.Second
+
+// IntrospectImage starts image, performs the MCP handshake over its transport and lists
+// its tools, resources and prompts
+func IntrospectImage(ctx context.Context, rt *ContainerRuntime, image string) (*mcp.Introspection, error) {
+	session, err := StartSession(ctx, rt, image, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer session.Close()
+	return mcp.Introspect(ctx, session.Client)
+}
+
+// introspect starts the freshly built host platform image and returns what it offers. An
+// image that already carries an introspection from identical sources is not started again.
+// Failures only warn, since servers needing credentials or external services may not start
+// in isolation.
+func (zp *ZipProcessor) introspect(host *imageBuild) *mcp.Introspection {
+	if zp.options.SkipIntrospection {
+		return nil
+	}
+	if host == nil {
+		fmt.Fprintln(zp.progress, "⚠️  Skipping introspection: no image was built for this machine's platform")
+		return nil
+	}
+
+	if host.cached {
+		var recorded mcp.Introspection
+		if label := zp.runtime.ImageLabel(host.Image, LabelIntrospection); label != "" && json.Unmarshal([]byte(label), &recorded) == nil {
+			return &recorded
+		}
+	}
+
+	fmt.Fprintf(zp.progress, "🔍 Introspecting %s...\n", host.Image)
+	ctx, cancel := context.WithTimeout(context.Background(), introspectionTimeout)
+	defer cancel()
+	result, err := IntrospectImage(ctx, zp.runtime, host.Image)
+	if err != nil {
+		fmt.Fprintf(zp.progress, "⚠️  Introspection failed, publishing without it: %v\n", err)
+		return nil
+	}
+	fmt.Fprintf(zp.progress, "🧰 %s %s offers %d tools, %d resources and %d prompts\n",
+		defaultString(result.ServerInfo.Name, "The server"), result.ServerInfo.Version,
+		len(result.Tools), len(result.Resources)+len(result.ResourceTemplates), len(result.Prompts))
+	return result
+}
+
+// SearchMatch is a server that matches a search query
+type SearchMatch struct {
+	Server      string
+	Version     string
+	Description string
+	// Tools, Resources and Prompts are the names of the matching ones
+	Tools     []string
+	Resources []string
+	Prompts   []string
+}
+
+// SearchServer matches query against a server's name, description and introspection. Every
+// word of the query must appear, case-insensitively, in the server's own fields or in a
+// single tool, resource or prompt. It returns nil when nothing matches.
+func SearchServer(server, version, description string, introspection *mcp.Introspection, query string) *SearchMatch {
+	terms := strings.Fields(strings.ToLower(query))
+	match := &SearchMatch{Server: server, Version: version, Description: description}
+	matched := len(terms) == 0 || containsAll(terms, server, description)
+
+	if introspection != nil {
+		for _, tool := range introspection.Tools {
+			if containsAll(terms, tool.Name, tool.Title, tool.Description) {
+				match.Tools = append(match.Tools, tool.Name)
+			}
+		}
+		for _, resource := range introspection.Resources {
+			if containsAll(terms, resource.Name, resource.Title, resource.Description, resource.URI) {
+				match.Resources = append(match.Resources, resource.URI)
+			}
+		}
+		for _, template := range introspection.ResourceTemplates {
+			if containsAll(terms, template.Name, template.Title, template.Description, template.URITemplate) {
+				match.Resources = append(match.Resources, template.URITemplate)
+			}
+		}
+		for _, prompt := range introspection.Prompts {
+			if containsAll(terms, prompt.Name, prompt.Title, prompt.Description) {
+				match.Prompts = append(match.Prompts, prompt.Name)
+			}
+		}
+	}
+
+	if !matched && len(match.Tools)+len(match.Resources)+len(match.Prompts) == 0 {
+		return nil
+	}
+	return match
+}
+
+func containsAll(terms []string, fields ...string) bool {
+	text := strings.ToLower(strings.Join(fields, " "))
+	for _, term := range terms {
+		if !strings.Contains(text, term) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,99 @@
+package services
+
+import (
+	"encoding/xml"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// TestSuite is a named group of test results, reported as one JUnit test suite
+type TestSuite struct {
+	Name  string
+	Cases []TestCase
+}
+
+// TestCase is the result of one test. Failure describes why it failed, and Skipped why it
+// did not run; both are empty when it passed.
+type TestCase struct {
+	Name     string
+	Failure  string
+	Skipped  string
+	Duration time.Duration
+}
+
+type junitSuites struct {
+	XMLName  xml.Name     `xml:"testsuites"`
+	Tests    int          `xml:"tests,attr"`
+	Failures int          `xml:"failures,attr"`
+	Skipped  int          `xml:"skipped,attr"`
+	Time     string       `xml:"time,attr"`
+	Suites   []junitSuite `xml:"testsuite"`
+}
+
+type junitSuite struct {
+	Name     string      `xml:"name,attr"`
+	Tests    int         `xml:"tests,attr"`
+	Failures int         `xml:"failures,attr"`
+	Skipped  int         `xml:"skipped,attr"`
+	Time     string      `xml:"time,attr"`
+	Cases    []junitCase `xml:"testcase"`
+}
+
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitMessage `xml:"failure"`
+	Skipped   *junitMessage `xml:"skipped"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// WriteJUnit saves suites as JUnit XML, the test report format CI systems read
+func WriteJUnit(path string, suites []TestSuite) error {
+	report := junitSuites{}
+	var total time.Duration
+	for _, suite := range suites {
+		out := junitSuite{Name: suite.Name, Tests: len(suite.Cases)}
+		var elapsed time.Duration
+		for _, test := range suite.Cases {
+			result := junitCase{Name: test.Name, ClassName: suite.Name, Time: junitTime(test.Duration)}
+			switch {
+			case test.Failure != "":
+				message, _, _ := strings.Cut(test.Failure, "\n")
+				result.Failure = &junitMessage{Message: message, Text: test.Failure}
+				out.Failures++
+			case test.Skipped != "":
+				result.Skipped = &junitMessage{Message: test.Skipped}
+				out.Skipped++
+			}
+			elapsed += test.Duration
+			out.Cases = append(out.Cases, result)
+		}
+		out.Time = junitTime(elapsed)
+		report.Tests += out.Tests
+		report.Failures += out.Failures
+		report.Skipped += out.Skipped
+		total += elapsed
+		report.Suites = append(report.Suites, out)
+	}
+	report.Time = junitTime(total)
+
+	content, err := xml.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, append([]byte(xml.Header), append(content, '\n')...), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
+
+func junitTime(duration time.Duration) string {
+	return fmt.Sprintf("%.3f", duration.Seconds())
+}
@@ -0,0 +1,230 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"mcphub/mcp"
+	"mcphub/models"
+)
+
+// Labels MCPHub adds to the images it builds
+const (
+	// LabelSourceHash fingerprints the sources and Dockerfile an image was built from
+	LabelSourceHash = "io.mcphub.source-hash"
+	// LabelTransport is the MCP transport: stdio, sse or http
+	LabelTransport = "io.mcphub.transport"
+	// LabelPort is the container port of the sse and http transports
+	LabelPort = "io.mcphub.port"
+	// LabelPath is the endpoint path of the sse and http transports
+	LabelPath = "io.mcphub.path"
+	// LabelEnv lists the environment variables the server reads, as JSON
+	LabelEnv = "io.mcphub.env"
+	// LabelConfig is the server's mcp.json, as compact JSON
+	LabelConfig = "io.mcphub.config"
+	// LabelConfigDigest is the sha256 digest of LabelConfig
+	LabelConfigDigest = "io.mcphub.config-digest"
+	// LabelManaged marks the containers started by `mcphub run`
+	LabelManaged = "io.mcphub.managed"
+)
+
+// OCI image annotations (https://github.com/opencontainers/image-spec/blob/main/annotations.md)
+const (
+	LabelTitle       = "org.opencontainers.image.title"
+	LabelVersion     = "org.opencontainers.image.version"
+	LabelDescription = "org.opencontainers.image.description"
+	LabelAuthors     = "org.opencontainers.image.authors"
+	LabelLicenses    = "org.opencontainers.image.licenses"
+	LabelSource      = "org.opencontainers.image.source"
+	LabelRevision    = "org.opencontainers.image.revision"
+	LabelCreated     = "org.opencontainers.image.created"
+)
+
+// MetadataLabels returns the labels that describe config and how to run it. They depend on
+// mcp.json only, so the generator writes them into the Dockerfile.
+func MetadataLabels(config *models.MCPConfig) map[string]string {
+	labels := map[string]string{
+		LabelTitle:     config.Name,
+		LabelTransport: config.Run.EffectiveTransport(),
+	}
+	optional := map[string]string{
+		LabelVersion:     config.Version,
+		LabelDescription: config.Description,
+		LabelAuthors:     config.Author,
+		LabelLicenses:    config.License,
+		LabelSource:      config.Repository.URL,
+	}
+	for key, value := range optional {
+		if value != "" {
+			labels[key] = value
+		}
+	}
+
+	if config.Run.EffectiveTransport() != models.TransportStdio {
+		labels[LabelPath] = config.Run.EndpointPath()
+	}
+	if config.Run.Port > 0 {
+		labels[LabelPort] = strconv.Itoa(config.Run.Port)
+	}
+	public := publicConfig(config)
+	if len(public.Env) > 0 {
+		env, _ := json.Marshal(public.Env)
+		labels[LabelEnv] = string(env)
+	}
+
+	content, _ := json.Marshal(public)
+	digest := sha256.Sum256(content)
+	labels[LabelConfig] = string(content)
+	labels[LabelConfigDigest] = "sha256:" + hex.EncodeToString(digest[:])
+	return labels
+}
+
+// publicConfig returns a copy of config that is safe to publish in image labels: build
+// arguments keep their names but not their values, and secrets lose their defaults
+func publicConfig(config *models.MCPConfig) *models.MCPConfig {
+	public := *config
+	if config.Build != nil {
+		build := *config.Build
+		if len(build.Args) > 0 {
+			build.Args = make(map[string]string, len(config.Build.Args))
+			for name := range config.Build.Args {
+				build.Args[name] = ""
+			}
+		}
+		public.Build = &build
+	}
+	if len(config.Env) > 0 {
+		public.Env = make([]models.EnvVar, len(config.Env))
+		for i, env := range config.Env {
+			if env.Secret {
+				env.Default = ""
+			}
+			public.Env[i] = env
+		}
+	}
+	return &public
+}
+
+// BuildLabels returns the labels known only when building: the creation time and, when the
+// sources are a git checkout, the commit. They are passed to the build so the generated
+// Dockerfile and the source hash stay stable.
+func BuildLabels(dir string) map[string]string {
+	labels := map[string]string{LabelCreated: time.Now().UTC().Format(time.RFC3339)}
+	if revision := gitRevision(dir); revision != "" {
+		labels[LabelRevision] = revision
+	}
+	return labels
+}
+
+// gitRevision returns the commit checked out in dir, or "" when dir is not a git checkout
+func gitRevision(dir string) string {
+	gitDir := filepath.Join(dir, ".git")
+	head, err := os.ReadFile(filepath.Join(gitDir, "HEAD"))
+	if err != nil {
+		return ""
+	}
+	ref, isRef := strings.CutPrefix(strings.TrimSpace(string(head)), "ref: ")
+	if !isRef {
+		return ref
+	}
+
+	if commit, err := os.ReadFile(filepath.Join(gitDir, filepath.FromSlash(ref))); err == nil {
+		return strings.TrimSpace(string(commit))
+	}
+	packed, err := os.ReadFile(filepath.Join(gitDir, "packed-refs"))
+	if err != nil {
+		return ""
+	}
+	for _, line := range strings.Split(string(packed), "\n") {
+		if fields := strings.Fields(line); len(fields) == 2 && fields[1] == ref {
+			return fields[0]
+		}
+	}
+	return ""
+}
+
+// ImageMetadata is what an image's labels say about the MCP server inside it
+type ImageMetadata struct {
+	Title        string
+	Version      string
+	Description  string
+	Authors      string
+	Licenses     string
+	Source       string
+	Revision     string
+	Created      string
+	Transport    string
+	Port         int
+	Path         string
+	Env          []models.EnvVar
+	Config       *models.MCPConfig
+	ConfigDigest string
+	SourceHash   string
+	// Introspection is what the server reported when it was built, if it could be started
+	Introspection *mcp.Introspection
+}
+
+// ParseImageMetadata reads the labels of an image; images not built by MCPHub yield
+// only the OCI fields they carry
+func ParseImageMetadata(labels map[string]string) *ImageMetadata {
+	metadata := &ImageMetadata{
+		Title:        labels[LabelTitle],
+		Version:      labels[LabelVersion],
+		Description:  labels[LabelDescription],
+		Authors:      labels[LabelAuthors],
+		Licenses:     labels[LabelLicenses],
+		Source:       labels[LabelSource],
+		Revision:     labels[LabelRevision],
+		Created:      labels[LabelCreated],
+		Transport:    labels[LabelTransport],
+		Path:         labels[LabelPath],
+		ConfigDigest: labels[LabelConfigDigest],
+		SourceHash:   labels[LabelSourceHash],
+	}
+	metadata.Port, _ = strconv.Atoi(labels[LabelPort])
+	if env := labels[LabelEnv]; env != "" {
+		json.Unmarshal([]byte(env), &metadata.Env)
+	}
+	if content := labels[LabelIntrospection]; content != "" {
+		var introspection mcp.Introspection
+		if json.Unmarshal([]byte(content), &introspection) == nil {
+			metadata.Introspection = &introspection
+		}
+	}
+	if content := labels[LabelConfig]; content != "" {
+		var config models.MCPConfig
+		if json.Unmarshal([]byte(content), &config) == nil {
+			metadata.Config = &config
+		}
+	}
+	return metadata
+}
+
+// ResolveEnv checks the required variables of metadata against provided (NAME or NAME=value
+// entries). Those set in this process's environment are returned in forward, to be passed
+// through by name; the rest are missing.
+func (m *ImageMetadata) ResolveEnv(provided []string) (forward, missing []string) {
+	given := map[string]bool{}
+	for _, entry := range provided {
+		name, _, _ := strings.Cut(entry, "=")
+		given[name] = true
+	}
+
+	for _, env := range m.Env {
+		if !env.Required || given[env.Name] {
+			continue
+		}
+		if _, ok := os.LookupEnv(env.Name); ok {
+			forward = append(forward, env.Name)
+		} else {
+			missing = append(missing, env.Name)
+		}
+	}
+	return forward, missing
+}
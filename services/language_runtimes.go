@@ -0,0 +1,413 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+
+	"mcphub/models"
+)
+
+// languageRuntime describes how the generator builds and runs servers launched by one family
+// of commands
+type languageRuntime struct {
+	// Name is what runtime.name in mcp.json selects
+	Name string
+	// Commands are the run commands this runtime serves
+	Commands []string
+	// Language selects the project's version files and package managers
+	Language string
+	// BuilderImage and RuntimeImage are image patterns taking the version; an empty
+	// RuntimeImage reuses the builder image
+	BuilderImage   string
+	RuntimeImage   string
+	DefaultVersion string
+	// VersionParts is how many version components the image tags use, 0 for all of them
+	VersionParts int
+	// Setup are builder steps that run before the sources are copied
+	Setup []string
+	// Build returns the builder steps that run once dependencies are installed
+	Build func(run models.RunConfig) []string
+	// Copy returns the runtime stage steps that take over the builder output
+	Copy func(run models.RunConfig, chown string) []string
+	// Command returns the container command; nil runs the command from mcp.json as is
+	Command func(run models.RunConfig) []string
+	// FallbackInstall installs dependencies when the project files are not known
+	FallbackInstall string
+	// Ignore are .dockerignore patterns for local dependencies and build output
+	Ignore []string
+}
+
+var languageRuntimes = []*languageRuntime{
+	{
+		Name:           "node",
+		Commands:       []string{"node"},
+		Language:       "node",
+		BuilderImage:   "node:%s-alpine",
+		DefaultVersion: "18",
+		VersionParts:   1,
+		FallbackInstall: "RUN if [ -f yarn.lock ]; then yarn install --production --frozen-lockfile; \\\n" +
+			"    elif [ -f package.json ]; then npm install --omit=dev; fi",
+		Ignore: []string{"**/node_modules", "npm-debug.log*", "coverage", ".nyc_output"},
+	},
+	{
+		// npx runs the package pre-installed into /app instead of downloading it on start
+		Name:           "npx",
+		Commands:       []string{"npx"},
+		Language:       "node",
+		BuilderImage:   "node:%s-alpine",
+		DefaultVersion: "18",
+		VersionParts:   1,
+		Build: func(run models.RunConfig) []string {
+			pkg := launcherPackage(run.Args, []string{"--package", "-p"}, []string{"--call", "-c"})
+			if pkg == "" {
+				return nil
+			}
+			return []string{fmt.Sprintf("RUN npm install --omit=dev --no-save %s", shellQuote(pkg))}
+		},
+		FallbackInstall: "RUN if [ -f package.json ]; then npm install --omit=dev; fi",
+		Ignore:          []string{"**/node_modules", "npm-debug.log*", "coverage", ".nyc_output"},
+	},
+	{
+		Name:           "bun",
+		Commands:       []string{"bun", "bunx"},
+		Language:       "bun",
+		BuilderImage:   "oven/bun:%s",
+		DefaultVersion: "1",
+		Build: func(run models.RunConfig) []string {
+			if run.Command != "bunx" {
+				return nil
+			}
+			pkg := launcherPackage(run.Args, []string{"--package", "-p"}, nil)
+			if pkg == "" {
+				return nil
+			}
+			return []string{fmt.Sprintf("RUN bun add %s", shellQuote(pkg))}
+		},
+		FallbackInstall: "RUN if [ -f package.json ]; then bun install --production; fi",
+		Ignore:          []string{"**/node_modules", "npm-debug.log*", "coverage", ".nyc_output"},
+	},
+	{
+		// The module cache lives in /app so the runtime user owns it
+		Name:           "deno",
+		Commands:       []string{"deno"},
+		Language:       "deno",
+		BuilderImage:   "denoland/deno:%s",
+		DefaultVersion: "2.1.4",
+		Setup:          []string{"ENV DENO_DIR=/app/.deno"},
+		Build: func(run models.RunConfig) []string {
+			entry := denoEntrypoint(run.Args)
+			if entry == "" {
+				return nil
+			}
+			return []string{fmt.Sprintf("RUN deno cache %s", shellQuote(entry))}
+		},
+		Copy: func(run models.RunConfig, chown string) []string {
+			return []string{copyApp(chown), "ENV DENO_DIR=/app/.deno"}
+		},
+		Ignore: []string{".deno", "**/node_modules"},
+	},
+	{
+		Name:           "python",
+		Commands:       []string{"python", "python3"},
+		Language:       "python",
+		BuilderImage:   "python:%s-slim",
+		DefaultVersion: "3.11",
+		VersionParts:   2,
+		// Install into a virtualenv that the runtime stage copies as a whole
+		Setup: []string{
+			"RUN python -m venv /opt/venv",
+			"ENV PATH=\"/opt/venv/bin:$PATH\" VIRTUAL_ENV=/opt/venv",
+		},
+		Copy: func(run models.RunConfig, chown string) []string {
+			return []string{
+				"COPY --from=builder /opt/venv /opt/venv",
+				copyApp(chown),
+				"ENV PATH=\"/opt/venv/bin:$PATH\" VIRTUAL_ENV=/opt/venv PYTHONUNBUFFERED=1",
+			}
+		},
+		FallbackInstall: "RUN if [ -f requirements.txt ]; then pip install --no-cache-dir -r requirements.txt; \\\n" +
+			"    elif [ -f pyproject.toml ]; then pip install --no-cache-dir .; \\\n" +
+			"    elif [ -f Pipfile ]; then pip install --no-cache-dir pipenv && pipenv install --system --deploy; fi",
+		Ignore: []string{"**/__pycache__", "**/*.py[cod]", ".venv", "venv", ".pytest_cache", ".mypy_cache", ".ruff_cache", ".tox", "**/*.egg-info"},
+	},
+	{
+		// uv tools are installed outside the root home so the runtime user can run them and
+		// uvx finds the installed tool instead of resolving it again
+		Name:           "uvx",
+		Commands:       []string{"uvx"},
+		Language:       "python",
+		BuilderImage:   "ghcr.io/astral-sh/uv:python%s-bookworm-slim",
+		DefaultVersion: "3.12",
+		VersionParts:   2,
+		Setup: []string{
+			"ENV UV_TOOL_DIR=/opt/uv/tools UV_TOOL_BIN_DIR=/opt/uv/bin UV_PYTHON_DOWNLOADS=never",
+			"RUN mkdir -p /opt/uv",
+		},
+		Build: func(run models.RunConfig) []string {
+			pkg := launcherPackage(run.Args, []string{"--from"}, []string{"--with", "--with-requirements", "--with-editable", "--python", "-p", "--index", "--index-url", "--extra-index-url"})
+			if pkg == "" {
+				return nil
+			}
+			return []string{fmt.Sprintf("RUN uv tool install %s", shellQuote(pkg))}
+		},
+		Copy: func(run models.RunConfig, chown string) []string {
+			return []string{
+				"COPY --from=builder /opt/uv /opt/uv",
+				copyApp(chown),
+				"ENV UV_TOOL_DIR=/opt/uv/tools UV_TOOL_BIN_DIR=/opt/uv/bin UV_PYTHON_DOWNLOADS=never PATH=\"/opt/uv/bin:$PATH\" PYTHONUNBUFFERED=1",
+			}
+		},
+		Ignore: []string{"**/__pycache__", "**/*.py[cod]", ".venv", "venv", ".pytest_cache", ".mypy_cache", ".ruff_cache", ".tox", "**/*.egg-info"},
+	},
+	{
+		// The compiled binary is static, so the toolchain is not needed at runtime
+		Name:           "go",
+		Commands:       []string{"go"},
+		Language:       "go",
+		BuilderImage:   "golang:%s-alpine",
+		RuntimeImage:   "alpine:3.19",
+		DefaultVersion: "1.21",
+		VersionParts:   2,
+		Build: func(run models.RunConfig) []string {
+			target, _ := splitGoRun(run.Args)
+			return []string{fmt.Sprintf("RUN CGO_ENABLED=0 go build -trimpath -ldflags=\"-s -w\" -o /out/server %s", strings.Join(shellQuoteAll(target), " "))}
+		},
+		Copy: func(run models.RunConfig, chown string) []string {
+			return []string{"COPY --from=builder /out/server /app/server"}
+		},
+		Command: func(run models.RunConfig) []string {
+			_, programArgs := splitGoRun(run.Args)
+			return append([]string{"/app/server"}, programArgs...)
+		},
+		FallbackInstall: "RUN if [ -f go.mod ]; then go mod download; fi",
+		Ignore:          []string{"**/testdata"},
+	},
+	{
+		Name:           "rust",
+		Commands:       []string{"cargo"},
+		Language:       "rust",
+		BuilderImage:   "rust:%s-slim",
+		RuntimeImage:   "debian:bookworm-slim",
+		DefaultVersion: "1",
+		Build: func(run models.RunConfig) []string {
+			binary, _ := splitCargoRun(run.Args)
+			if binary != "" {
+				return []string{fmt.Sprintf("RUN cargo install --path . --root /out --bin %[1]s && cp /out/bin/%[1]s /out/server", shellQuote(binary))}
+			}
+			return []string{"RUN cargo install --path . --root /out && set -- /out/bin/* && \\\n" +
+				"    if [ \"$#\" -ne 1 ]; then echo \"several binaries built; pass --bin in run.args\" >&2; exit 1; fi && cp \"$1\" /out/server"}
+		},
+		Copy: func(run models.RunConfig, chown string) []string {
+			return []string{"COPY --from=builder /out/server /app/server"}
+		},
+		Command: func(run models.RunConfig) []string {
+			_, programArgs := splitCargoRun(run.Args)
+			return append([]string{"/app/server"}, programArgs...)
+		},
+		Ignore: []string{"target"},
+	},
+	{
+		// Projects with a Maven or Gradle wrapper are packaged; otherwise the jar is expected in the sources
+		Name:           "java",
+		Commands:       []string{"java"},
+		Language:       "java",
+		BuilderImage:   "eclipse-temurin:%s-jdk",
+		RuntimeImage:   "eclipse-temurin:%s-jre",
+		DefaultVersion: "21",
+		VersionParts:   1,
+		Build: func(run models.RunConfig) []string {
+			return []string{"RUN if [ -x ./mvnw ]; then ./mvnw -B -q -DskipTests package; \\\n" +
+				"    elif [ -x ./gradlew ]; then ./gradlew --no-daemon -q build -x test; fi"}
+		},
+		Ignore: []string{".gradle"},
+	},
+	{
+		// "dotnet run" is published as server.dll; a prebuilt .dll runs from the sources as is
+		Name:           "dotnet",
+		Commands:       []string{"dotnet"},
+		Language:       "dotnet",
+		BuilderImage:   "mcr.microsoft.com/dotnet/sdk:%s",
+		RuntimeImage:   "mcr.microsoft.com/dotnet/aspnet:%s",
+		DefaultVersion: "8.0",
+		VersionParts:   2,
+		Build: func(run models.RunConfig) []string {
+			if isPrebuiltDotnet(run.Args) {
+				return nil
+			}
+			return []string{"RUN dotnet publish -c Release -o /out -p:AssemblyName=server"}
+		},
+		Copy: func(run models.RunConfig, chown string) []string {
+			if isPrebuiltDotnet(run.Args) {
+				return []string{copyApp(chown)}
+			}
+			return []string{fmt.Sprintf("COPY --from=builder %s /out /app", chown)}
+		},
+		Command: func(run models.RunConfig) []string {
+			if isPrebuiltDotnet(run.Args) {
+				return append([]string{"dotnet"}, run.Args...)
+			}
+			return append([]string{"dotnet", "/app/server.dll"}, argsAfterSeparator(run.Args)...)
+		},
+		Ignore: []string{"**/obj"},
+	},
+}
+
+// defaultLanguageRuntime serves commands no runtime knows about
+var defaultLanguageRuntime = &languageRuntime{
+	BuilderImage: "ubuntu:22.04",
+	Build: func(run models.RunConfig) []string {
+		return []string{"# Add any custom installation commands here"}
+	},
+}
+
+// lookupLanguageRuntime returns the runtime named name or serving the command name
+func lookupLanguageRuntime(name string) *languageRuntime {
+	for _, rt := range languageRuntimes {
+		if rt.Name == name || contains(rt.Commands, name) {
+			return rt
+		}
+	}
+	return defaultLanguageRuntime
+}
+
+// runtimeFor returns the runtime serving run.command. runtime.name in mcp.json selects
+// another one, unless it names the command's own runtime or language (e.g. "node" for npx).
+func runtimeFor(config *models.MCPConfig) *languageRuntime {
+	rt := lookupLanguageRuntime(config.Run.Command)
+	if config.Runtime == nil || config.Runtime.Name == "" {
+		return rt
+	}
+	if rt != defaultLanguageRuntime && (config.Runtime.Name == rt.Name || config.Runtime.Name == rt.Language) {
+		return rt
+	}
+	return lookupLanguageRuntime(config.Runtime.Name)
+}
+
+// builderImage returns the image of the builder stage; an empty version selects the default
+func (rt *languageRuntime) builderImage(version string) string {
+	return rt.image(rt.BuilderImage, version)
+}
+
+// runtimeImage returns the image of the final stage
+func (rt *languageRuntime) runtimeImage(version string) string {
+	if rt.RuntimeImage == "" {
+		return rt.builderImage(version)
+	}
+	return rt.image(rt.RuntimeImage, version)
+}
+
+func (rt *languageRuntime) image(pattern, version string) string {
+	if !strings.Contains(pattern, "%s") {
+		return pattern
+	}
+	return fmt.Sprintf(pattern, defaultString(version, rt.DefaultVersion))
+}
+
+// imageVersion reduces a version or constraint ("v22.3.0", ">=18", "3.12.1") to the
+// granularity the runtime's image tags use. Aliases such as "lts/*" or "node" yield "".
+func (rt *languageRuntime) imageVersion(version string) string {
+	match := versionPattern.FindString(version)
+	if match == "" {
+		return ""
+	}
+	parts := strings.Split(match, ".")
+	if rt.VersionParts > 0 && len(parts) > rt.VersionParts {
+		parts = parts[:rt.VersionParts]
+	}
+	return strings.Join(parts, ".")
+}
+
+// command returns the container command for run
+func (rt *languageRuntime) command(run models.RunConfig) []string {
+	if rt.Command != nil {
+		return rt.Command(run)
+	}
+	return append([]string{run.Command}, run.Args...)
+}
+
+// copyApp copies the builder's /app into the runtime stage, owned by the runtime user
+func copyApp(chown string) string {
+	return fmt.Sprintf("COPY --from=builder %s /app /app", chown)
+}
+
+// launcherPackage returns the package an npx/bunx/uvx command line launches: the value of
+// one of packageFlags, or else the first argument that is neither a flag nor a flag's value
+func launcherPackage(args []string, packageFlags, valueFlags []string) string {
+	for i, arg := range args {
+		for _, flag := range packageFlags {
+			if arg == flag && i+1 < len(args) {
+				return args[i+1]
+			}
+			if strings.HasPrefix(arg, flag+"=") {
+				return strings.TrimPrefix(arg, flag+"=")
+			}
+		}
+	}
+
+	for i := 0; i < len(args); i++ {
+		if contains(valueFlags, args[i]) {
+			i++
+			continue
+		}
+		if !strings.HasPrefix(args[i], "-") {
+			return args[i]
+		}
+	}
+	return ""
+}
+
+// denoEntrypoint returns the module "deno [run|serve] [flags] <module> [args]" starts
+func denoEntrypoint(args []string) string {
+	if len(args) > 0 && (args[0] == "run" || args[0] == "serve") {
+		args = args[1:]
+	}
+	return launcherPackage(args, nil, []string{"-c", "--config", "--import-map", "--lock", "--cert", "--location"})
+}
+
+// splitCargoRun returns the --bin of "cargo run [options] [-- program args]" and the program arguments
+func splitCargoRun(args []string) (string, []string) {
+	binary := ""
+	for i, arg := range args {
+		if arg == "--" {
+			break
+		}
+		if arg == "--bin" && i+1 < len(args) {
+			binary = args[i+1]
+		} else if strings.HasPrefix(arg, "--bin=") {
+			binary = strings.TrimPrefix(arg, "--bin=")
+		}
+	}
+	return binary, argsAfterSeparator(args)
+}
+
+// argsAfterSeparator returns the arguments following "--"
+func argsAfterSeparator(args []string) []string {
+	for i, arg := range args {
+		if arg == "--" {
+			return args[i+1:]
+		}
+	}
+	return nil
+}
+
+func shellQuoteAll(values []string) []string {
+	quoted := make([]string, len(values))
+	for i, value := range values {
+		quoted[i] = shellQuote(value)
+	}
+	return quoted
+}
+
+func isPrebuiltDotnet(args []string) bool {
+	return len(args) > 0 && strings.HasSuffix(args[0], ".dll")
+}
+
+// shellQuote quotes value for a shell command line when it contains special characters.
+// Line breaks are replaced first since the command line is part of a Dockerfile instruction.
+func shellQuote(value string) string {
+	value = singleLine(value)
+	if value != "" && strings.Trim(value, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789@%+=:,./-_") == "" {
+		return value
+	}
+	return "'" + strings.ReplaceAll(value, "'", `'"'"'`) + "'"
+}
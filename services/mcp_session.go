@@ -0,0 +1,432 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"mcphub/mcp"
+	"mcphub/models"
+)
+
+// Session is an initialized MCP connection to a server running in a container
+type Session struct {
+	Client   *mcp.Client
+	Info     *mcp.InitializeResult
+	Metadata *ImageMetadata
+	logs     func() (string, error)
+}
+
+// Close ends the connection and removes the container when it was started for the session
+func (s *Session) Close() error {
+	return s.Client.Close()
+}
+
+// Logs returns what the server printed to stderr, or its container logs for network servers
+func (s *Session) Logs() (string, error) {
+	if s.logs == nil {
+		return "", nil
+	}
+	return s.logs()
+}
+
+// StartSession runs image in a throwaway container, connects to it over the transport in its
+// labels and performs the MCP handshake. env holds NAME=value entries for the server.
+// The container is removed when the session is closed.
+func StartSession(ctx context.Context, rt *ContainerRuntime, image string, env []string) (*Session, error) {
+	labels, err := rt.ImageLabels(image)
+	if err != nil {
+		return nil, err
+	}
+	metadata, err := serverMetadata(image, labels)
+	if err != nil {
+		return nil, err
+	}
+	stderr := &syncBuffer{}
+	server, err := startServer(ctx, rt, image, metadata, env, stderr)
+	if err != nil {
+		return nil, err
+	}
+	return server.initialize(ctx, stderr)
+}
+
+// AttachSession connects to a server in a running container. Network servers are reached
+// on their published port; stdio servers get a fresh server process started with `exec -i`,
+// since the stdin of the container's own process belongs to whoever started it.
+func AttachSession(ctx context.Context, rt *ContainerRuntime, container string) (*Session, error) {
+	config, err := rt.containerConfig(container)
+	if err != nil {
+		return nil, err
+	}
+	stderr := &syncBuffer{}
+	server, err := attachServer(ctx, rt, container, config, stderr)
+	if err != nil {
+		return nil, err
+	}
+	return server.initialize(ctx, stderr)
+}
+
+// ConnectServer opens a session with server: a running container started by `mcphub run`
+// when one has that name, otherwise a throwaway container of the local image server.
+// env holds NAME=value (or NAME, passed through) entries for throwaway containers; their
+// required variables must be given or set in this process's environment.
+func ConnectServer(ctx context.Context, rt *ContainerRuntime, server string, env []string) (*Session, error) {
+	stderr := &syncBuffer{}
+	opened, err := openServer(ctx, rt, server, env, stderr)
+	if err != nil {
+		return nil, err
+	}
+	return opened.initialize(ctx, stderr)
+}
+
+// OpenTransport connects to server as ConnectServer does, but without the handshake, so
+// that a client can perform it over the returned transport. Closing the transport removes
+// a throwaway container. Server stderr is copied to logs.
+func OpenTransport(ctx context.Context, rt *ContainerRuntime, server string, env []string, logs io.Writer) (mcp.Transport, error) {
+	opened, err := openServer(ctx, rt, server, env, logs)
+	if err != nil {
+		return nil, err
+	}
+	return opened.transport, nil
+}
+
+// serverConnection is a transport to a server before the handshake
+type serverConnection struct {
+	// transport removes a throwaway container when closed
+	transport mcp.Transport
+	metadata  *ImageMetadata
+	// containerLogs reads the logs of a network server's container; stdio servers write
+	// their stderr where they were opened with instead
+	containerLogs func() (string, error)
+}
+
+// openServer connects to the running container started by `mcphub run` named server, or
+// else starts a throwaway container of the local image server. Stdio servers write their
+// stderr to logs.
+func openServer(ctx context.Context, rt *ContainerRuntime, server string, env []string, logs io.Writer) (*serverConnection, error) {
+	if config, err := rt.containerConfig(server); err == nil && config.Labels[LabelManaged] == "true" {
+		if !rt.containerRunning(server) {
+			return nil, fmt.Errorf("container %s is not running; start it with '%s start %s'", server, rt.Binary(), server)
+		}
+		return attachServer(ctx, rt, server, config, logs)
+	}
+
+	labels, err := rt.ImageLabels(server)
+	if err != nil {
+		return nil, fmt.Errorf("no running MCPHub container or local image named %s", server)
+	}
+	return startImage(ctx, rt, server, labels, env, logs)
+}
+
+// startImage starts a throwaway container of the local image, whose labels are labels,
+// once the required variables of its server are given in env or set in this process's
+// environment. Stdio servers write their stderr to logs.
+func startImage(ctx context.Context, rt *ContainerRuntime, image string, labels map[string]string, env []string, logs io.Writer) (*serverConnection, error) {
+	metadata, err := serverMetadata(image, labels)
+	if err != nil {
+		return nil, err
+	}
+	if env, err = forwardEnv(metadata, env); err != nil {
+		return nil, err
+	}
+	return startServer(ctx, rt, image, metadata, env, logs)
+}
+
+// serverMetadata reads how to reach the server in image from its labels
+func serverMetadata(image string, labels map[string]string) (*ImageMetadata, error) {
+	metadata := ParseImageMetadata(labels)
+	if metadata.Transport == "" {
+		return nil, fmt.Errorf("%s was not built by MCPHub; its transport is unknown", image)
+	}
+	return metadata, nil
+}
+
+// forwardEnv checks that the required variables of metadata are given in env or set in
+// this process's environment, returning env with the set ones passed through by name
+func forwardEnv(metadata *ImageMetadata, env []string) ([]string, error) {
+	forward, missing := metadata.ResolveEnv(env)
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("missing required environment variables: %s (pass them with -e NAME=value)", strings.Join(missing, ", "))
+	}
+	return append(append([]string{}, env...), forward...), nil
+}
+
+// startServer runs image in a throwaway container, removed when the transport is closed
+func startServer(ctx context.Context, rt *ContainerRuntime, image string, metadata *ImageMetadata, env []string, logs io.Writer) (*serverConnection, error) {
+	name := sessionContainerName()
+	args := []string{"run", "--name", name}
+	for _, entry := range env {
+		args = append(args, "-e", entry)
+	}
+
+	if metadata.Transport == models.TransportStdio {
+		transport, err := startStdioTransport(rt, append(args, "--rm", "-i", image), name, logs)
+		if err != nil {
+			return nil, err
+		}
+		return &serverConnection{transport: transport, metadata: metadata}, nil
+	}
+	if metadata.Port <= 0 {
+		return nil, fmt.Errorf("%s uses the %s transport but declares no port", image, metadata.Transport)
+	}
+
+	// Publish the server port on a random loopback port. The container is kept when the
+	// server exits so its logs can be read; closing the transport removes it.
+	args = append(args, "-d", "-p", fmt.Sprintf("127.0.0.1::%d", metadata.Port), image)
+	if output, err := rt.Command(args...).CombinedOutput(); err != nil {
+		return nil, fmt.Errorf("failed to start %s: %s", image, strings.TrimSpace(string(output)))
+	}
+	remove := func() error { return rt.Command("rm", "-f", name).Run() }
+	address, err := rt.publishedAddress(name, metadata.Port)
+	if err != nil {
+		remove()
+		return nil, err
+	}
+	transport, err := openNetworkTransport(ctx, rt, name, metadata, address, remove)
+	if err != nil {
+		remove()
+		return nil, err
+	}
+	return &serverConnection{transport: transport, metadata: metadata, containerLogs: containerLogs(rt, name)}, nil
+}
+
+// attachServer connects to the server in a running container, whose configuration is config
+func attachServer(ctx context.Context, rt *ContainerRuntime, container string, config *inspectedContainer, logs io.Writer) (*serverConnection, error) {
+	metadata := ParseImageMetadata(config.Labels)
+	if metadata.Transport == "" {
+		return nil, fmt.Errorf("container %s was not started from an MCPHub image", container)
+	}
+
+	if metadata.Transport == models.TransportStdio {
+		command := append(append([]string{}, config.Entrypoint...), config.Cmd...)
+		if len(command) == 0 {
+			return nil, fmt.Errorf("container %s has no command to run", container)
+		}
+		transport, err := startStdioTransport(rt, append([]string{"exec", "-i", container}, command...), "", logs)
+		if err != nil {
+			return nil, err
+		}
+		return &serverConnection{transport: transport, metadata: metadata}, nil
+	}
+
+	address, err := rt.publishedAddress(container, metadata.Port)
+	if err != nil {
+		return nil, fmt.Errorf("%w (run it with a published port)", err)
+	}
+	transport, err := openNetworkTransport(ctx, rt, container, metadata, address, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &serverConnection{transport: transport, metadata: metadata, containerLogs: containerLogs(rt, container)}, nil
+}
+
+// initialize performs the handshake over the connection. stderr is where a stdio server was
+// opened to write its stderr.
+func (c *serverConnection) initialize(ctx context.Context, stderr *syncBuffer) (*Session, error) {
+	logs := c.containerLogs
+	if logs == nil {
+		logs = func() (string, error) { return stderr.String(), nil }
+	}
+	client := mcp.NewClient(c.transport)
+	info, err := client.Initialize(ctx)
+	if err != nil {
+		var output string
+		if c.containerLogs != nil {
+			// Read before closing removes a throwaway container
+			output, _ = c.containerLogs()
+			client.Close()
+		} else {
+			// Closing waits for the server, so its stderr is complete
+			client.Close()
+			output = stderr.String()
+		}
+		return nil, withContainerOutput(err, output)
+	}
+	return &Session{Client: client, Info: info, Metadata: c.metadata, logs: logs}, nil
+}
+
+// containerLogs reads the last logs of container
+func containerLogs(rt *ContainerRuntime, container string) func() (string, error) {
+	return func() (string, error) {
+		output, err := rt.Command("logs", "--tail", "100", container).CombinedOutput()
+		if err != nil {
+			return "", fmt.Errorf("failed to read the logs of %s: %s", container, strings.TrimSpace(string(output)))
+		}
+		return string(output), nil
+	}
+}
+
+// syncBuffer is a buffer that can be read while a process writes to it
+type syncBuffer struct {
+	mu     sync.Mutex
+	buffer bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buffer.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buffer.String()
+}
+
+// publishedAddress returns the host address a container port is published on
+func (r *ContainerRuntime) publishedAddress(container string, port int) (string, error) {
+	output, err := r.Command("port", container, fmt.Sprintf("%d/tcp", port)).Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to find the published port of %s: %w", container, err)
+	}
+	for _, line := range strings.Split(string(output), "\n") {
+		host, hostPort, err := net.SplitHostPort(strings.TrimSpace(line))
+		if err != nil {
+			continue
+		}
+		if host == "" || host == "0.0.0.0" || host == "::" {
+			host = "127.0.0.1"
+		}
+		return net.JoinHostPort(host, hostPort), nil
+	}
+	return "", fmt.Errorf("port %d of %s is not published", port, container)
+}
+
+// containerRunning reports whether the container exists and is running
+func (r *ContainerRuntime) containerRunning(container string) bool {
+	output, err := r.Command("inspect", "--format", "{{.State.Running}}", container).Output()
+	return err == nil && strings.TrimSpace(string(output)) == "true"
+}
+
+func sessionContainerName() string {
+	suffix := make([]byte, 4)
+	rand.Read(suffix)
+	return "mcphub-session-" + hex.EncodeToString(suffix)
+}
+
+// withContainerOutput appends the last lines the container printed to err
+func withContainerOutput(err error, output string) error {
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	if len(lines) > buildErrorTailLines {
+		lines = lines[len(lines)-buildErrorTailLines:]
+	}
+	if tail := strings.TrimSpace(strings.Join(lines, "\n")); tail != "" {
+		return fmt.Errorf("%w\n%s", err, tail)
+	}
+	return err
+}
+
+// inspectedContainer is the part of a container's configuration sessions need
+type inspectedContainer struct {
+	Labels     map[string]string
+	Entrypoint []string
+	Cmd        []string
+}
+
+// containerConfig returns the labels (the image's included) and command of a container
+func (r *ContainerRuntime) containerConfig(container string) (*inspectedContainer, error) {
+	output, err := r.Command("container", "inspect", "--format", "{{json .Config}}", container).Output()
+	if err != nil {
+		return nil, fmt.Errorf("container %s not found in %s", container, r.DisplayName())
+	}
+	var config inspectedContainer
+	if err := json.Unmarshal(output, &config); err != nil {
+		return nil, fmt.Errorf("failed to read the configuration of %s: %w", container, err)
+	}
+	return &config, nil
+}
+
+// startStdioTransport runs a stdio server with `run -i` or `exec -i`. The container name,
+// when set, is removed on close.
+func startStdioTransport(rt *ContainerRuntime, args []string, name string, logs io.Writer) (mcp.Transport, error) {
+	cmd := rt.Command(args...)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	cmd.Stderr = logs
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start %s: %w", rt.Binary(), err)
+	}
+	return mcp.NewStdioTransport(stdout, stdin, func() error {
+		if name != "" {
+			rt.Command("rm", "-f", name).Run()
+		}
+		cmd.Wait()
+		return nil
+	}), nil
+}
+
+// openNetworkTransport connects to the server in container, published on address, once it
+// answers HTTP requests. A TCP connection is not enough, since the runtime's port proxy
+// accepts them before the server listens. remove, when set, is called on close.
+func openNetworkTransport(ctx context.Context, rt *ContainerRuntime, container string, metadata *ImageMetadata, address string, remove func() error) (mcp.Transport, error) {
+	url := fmt.Sprintf("http://%s%s", address, metadata.Path)
+	for {
+		var transport mcp.Transport
+		var err error
+		if metadata.Transport == models.TransportSSE {
+			transport, err = mcp.NewSSETransport(ctx, url)
+		} else if err = probeHTTP(ctx, url); err == nil {
+			transport = mcp.NewHTTPTransport(url)
+		}
+		if err == nil {
+			if remove == nil {
+				return transport, nil
+			}
+			return &removingTransport{Transport: transport, remove: remove}, nil
+		}
+
+		if !rt.containerRunning(container) {
+			logs, _ := rt.Command("logs", container).CombinedOutput()
+			return nil, withContainerOutput(fmt.Errorf("the server exited: %w", err), string(logs))
+		}
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("the server did not accept connections at %s: %w", url, err)
+		case <-time.After(500 * time.Millisecond):
+		}
+	}
+}
+
+// probeHTTP checks that a server answers at url; any status will do
+func probeHTTP(ctx context.Context, url string) error {
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+	request, err := http.NewRequestWithContext(ctx, http.MethodHead, url, nil)
+	if err != nil {
+		return err
+	}
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return err
+	}
+	response.Body.Close()
+	return nil
+}
+
+// removingTransport removes the server's container once its connection is closed
+type removingTransport struct {
+	mcp.Transport
+	remove func() error
+}
+
+func (t *removingTransport) Close() error {
+	err := t.Transport.Close()
+	t.remove()
+	return err
+}
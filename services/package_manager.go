@@ -0,0 +1,224 @@
+package services
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// InstallPlan is the single dependency installation step chosen for a project
+type InstallPlan struct {
+	// Manager is the package manager, e.g. npm, pnpm, yarn-berry, poetry or uv
+	Manager string
+	// Files are copied before installing so the install layer is cached until they change
+	Files []string
+	// Commands run in one RUN instruction
+	Commands []string
+	// ProjectCommands install the project itself, with its console scripts, once the full
+	// source is copied
+	ProjectCommands []string
+	// NeedsSource installs after the full source is copied (e.g. "pip install .")
+	NeedsSource bool
+}
+
+// lockfileManagers maps lockfiles to the package manager that owns them, per language
+var lockfileManagers = map[string]map[string]string{
+	"node": {
+		"package-lock.json":   "npm",
+		"npm-shrinkwrap.json": "npm",
+		"pnpm-lock.yaml":      "pnpm",
+		"yarn.lock":           "yarn",
+		"bun.lockb":           "bun",
+		"bun.lock":            "bun",
+	},
+	"python": {
+		"poetry.lock":  "poetry",
+		"uv.lock":      "uv",
+		"Pipfile.lock": "pipenv",
+	},
+	"bun": {
+		"bun.lockb": "bun",
+		"bun.lock":  "bun",
+	},
+}
+
+// DetectInstallPlan picks the package manager from the lockfiles in dir. override (from
+// build.package_manager) settles projects with several lockfiles; without it they are an error.
+// A nil plan means the language has no dependency step.
+func DetectInstallPlan(dir, language, override string) (*InstallPlan, error) {
+	managers, ok := lockfileManagers[language]
+	if !ok && language != "go" {
+		return nil, nil
+	}
+
+	found := map[string][]string{}
+	for lockfile, manager := range managers {
+		if fileExists(filepath.Join(dir, lockfile)) {
+			found[manager] = append(found[manager], lockfile)
+		}
+	}
+
+	manager := override
+	if manager == "" {
+		switch len(found) {
+		case 0:
+		case 1:
+			for m := range found {
+				manager = m
+			}
+		default:
+			var lockfiles []string
+			for _, files := range found {
+				lockfiles = append(lockfiles, files...)
+			}
+			sort.Strings(lockfiles)
+			return nil, fmt.Errorf("ambiguous dependencies: found %s; remove the stale lockfiles or set build.package_manager in mcp.json", strings.Join(lockfiles, ", "))
+		}
+	}
+
+	switch language {
+	case "node":
+		return nodeInstallPlan(dir, manager, found)
+	case "python":
+		return pythonInstallPlan(dir, manager, found)
+	case "go":
+		return goInstallPlan(dir), nil
+	case "bun":
+		return bunInstallPlan(dir, found), nil
+	}
+	return nil, nil
+}
+
+func nodeInstallPlan(dir, manager string, found map[string][]string) (*InstallPlan, error) {
+	if !fileExists(filepath.Join(dir, "package.json")) {
+		return nil, nil
+	}
+	files := append([]string{"package.json"}, found[manager]...)
+
+	switch manager {
+	case "":
+		return &InstallPlan{Manager: "npm", Files: files, Commands: []string{"npm install --omit=dev"}}, nil
+	case "npm":
+		if len(found["npm"]) == 0 {
+			return &InstallPlan{Manager: "npm", Files: files, Commands: []string{"npm install --omit=dev"}}, nil
+		}
+		return &InstallPlan{Manager: "npm", Files: files, Commands: []string{"npm ci --omit=dev"}}, nil
+	case "pnpm":
+		files = appendIfExists(dir, files, "pnpm-workspace.yaml", ".npmrc")
+		return &InstallPlan{Manager: "pnpm", Files: files, Commands: []string{
+			"corepack enable",
+			"pnpm install --prod --frozen-lockfile",
+		}}, nil
+	case "yarn", "yarn-berry":
+		if manager == "yarn-berry" || isYarnBerry(dir) {
+			files = appendIfExists(dir, files, ".yarnrc.yml", ".yarn")
+			// workspaces focus installs without devDependencies; yarn 2 and 3 need its plugin
+			return &InstallPlan{Manager: "yarn-berry", Files: files, Commands: []string{
+				"corepack enable",
+				"(yarn workspaces focus --help >/dev/null 2>&1 || yarn plugin import workspace-tools)",
+				"yarn workspaces focus --all --production",
+			}}, nil
+		}
+		return &InstallPlan{Manager: "yarn", Files: files, Commands: []string{"yarn install --production --frozen-lockfile"}}, nil
+	case "bun":
+		return &InstallPlan{Manager: "bun", Files: files, Commands: []string{
+			"npm install -g bun",
+			"bun install --production --frozen-lockfile",
+		}}, nil
+	default:
+		return nil, fmt.Errorf("unsupported node package manager %q (use npm, pnpm, yarn, yarn-berry or bun)", manager)
+	}
+}
+
+func pythonInstallPlan(dir, manager string, found map[string][]string) (*InstallPlan, error) {
+	switch manager {
+	case "poetry":
+		files := appendIfExists(dir, []string{"pyproject.toml"}, "poetry.lock")
+		return &InstallPlan{Manager: "poetry", Files: files, Commands: []string{
+			"pip install --no-cache-dir poetry",
+			"poetry config virtualenvs.create false",
+			"poetry install --only main --no-root --no-interaction",
+		}, ProjectCommands: []string{"poetry install --only main --no-interaction"}}, nil
+	case "uv":
+		files := appendIfExists(dir, []string{"pyproject.toml"}, "uv.lock")
+		return &InstallPlan{Manager: "uv", Files: files, Commands: []string{
+			"pip install --no-cache-dir uv",
+			"UV_PROJECT_ENVIRONMENT=/opt/venv uv sync --frozen --no-dev --no-install-project",
+		}, ProjectCommands: []string{"UV_PROJECT_ENVIRONMENT=/opt/venv uv sync --frozen --no-dev --no-editable"}}, nil
+	case "pipenv":
+		files := appendIfExists(dir, []string{"Pipfile"}, "Pipfile.lock")
+		commands := []string{"pip install --no-cache-dir pipenv"}
+		if len(found["pipenv"]) > 0 {
+			commands = append(commands, "pipenv install --deploy --system")
+		} else {
+			commands = append(commands, "pipenv install --system --skip-lock")
+		}
+		return &InstallPlan{Manager: "pipenv", Files: files, Commands: commands}, nil
+	case "pip", "":
+		switch {
+		case fileExists(filepath.Join(dir, "requirements.txt")):
+			return &InstallPlan{Manager: "pip", Files: []string{"requirements.txt"}, Commands: []string{"pip install --no-cache-dir -r requirements.txt"}}, nil
+		case fileExists(filepath.Join(dir, "pyproject.toml")):
+			return &InstallPlan{Manager: "pip", Commands: []string{"pip install --no-cache-dir ."}, NeedsSource: true}, nil
+		case fileExists(filepath.Join(dir, "Pipfile")):
+			return pythonInstallPlan(dir, "pipenv", found)
+		}
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("unsupported python package manager %q (use pip, poetry, uv or pipenv)", manager)
+	}
+}
+
+// bunInstallPlan installs with the bun binary of the oven/bun images
+func bunInstallPlan(dir string, found map[string][]string) *InstallPlan {
+	if !fileExists(filepath.Join(dir, "package.json")) {
+		return nil
+	}
+	if len(found["bun"]) == 0 {
+		return &InstallPlan{Manager: "bun", Files: []string{"package.json"}, Commands: []string{"bun install --production"}}
+	}
+	files := append([]string{"package.json"}, found["bun"]...)
+	return &InstallPlan{Manager: "bun", Files: files, Commands: []string{"bun install --production --frozen-lockfile"}}
+}
+
+func goInstallPlan(dir string) *InstallPlan {
+	if !fileExists(filepath.Join(dir, "go.mod")) {
+		return nil
+	}
+	files := appendIfExists(dir, []string{"go.mod"}, "go.sum")
+	return &InstallPlan{Manager: "go", Files: files, Commands: []string{"go mod download"}}
+}
+
+// isYarnBerry tells yarn 2+ lockfiles (YAML with __metadata) from classic yarn 1 ones
+func isYarnBerry(dir string) bool {
+	if fileExists(filepath.Join(dir, ".yarnrc.yml")) {
+		return true
+	}
+	content, err := os.ReadFile(filepath.Join(dir, "yarn.lock"))
+	return err == nil && strings.Contains(string(content), "__metadata:")
+}
+
+func appendIfExists(dir string, files []string, names ...string) []string {
+	for _, name := range names {
+		if fileExists(filepath.Join(dir, name)) && !contains(files, name) {
+			files = append(files, name)
+		}
+	}
+	return files
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
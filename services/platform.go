@@ -0,0 +1,63 @@
+package services
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+)
+
+// HostPlatform returns the container platform matching this machine, e.g. linux/arm64.
+// Containers run Linux even on macOS and Windows hosts, so the OS is always linux.
+func HostPlatform() string {
+	if runtime.GOARCH == "arm" {
+		return "linux/arm/v7"
+	}
+	return "linux/" + runtime.GOARCH
+}
+
+// ParsePlatforms splits a comma separated --platform value
+func ParsePlatforms(value string) []string {
+	var platforms []string
+	for _, platform := range strings.Split(value, ",") {
+		if platform = strings.TrimSpace(platform); platform != "" {
+			platforms = append(platforms, platform)
+		}
+	}
+	return platforms
+}
+
+// NormalizePlatforms validates os/arch[/variant] platforms, drops duplicates and orders
+// the host platform last so it is the image left in the local runtime after building.
+func NormalizePlatforms(platforms []string) ([]string, error) {
+	seen := map[string]bool{}
+	var normalized []string
+	hasHost := false
+
+	for _, platform := range platforms {
+		platform = strings.ToLower(strings.TrimSpace(platform))
+		parts := strings.Split(platform, "/")
+		if len(parts) < 2 || len(parts) > 3 || parts[0] == "" || parts[1] == "" {
+			return nil, fmt.Errorf("invalid platform %q (expected os/arch[/variant], e.g. linux/arm64)", platform)
+		}
+		if seen[platform] {
+			continue
+		}
+		seen[platform] = true
+
+		if platform == HostPlatform() {
+			hasHost = true
+			continue
+		}
+		normalized = append(normalized, platform)
+	}
+
+	if hasHost {
+		normalized = append(normalized, HostPlatform())
+	}
+	return normalized, nil
+}
+
+// PlatformTag turns a platform into a form usable in file names and object keys
+func PlatformTag(platform string) string {
+	return strings.ReplaceAll(platform, "/", "-")
+}
@@ -0,0 +1,150 @@
+package services
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"mcphub/models"
+)
+
+// LockFileName is the managed lock pinning base images by digest, kept next to mcp.json
+const LockFileName = "mcp.lock.json"
+
+// ProjectInfo is what was detected from an MCP server's source files
+type ProjectInfo struct {
+	// RuntimeVersion is the language version requested by the project files
+	RuntimeVersion string
+	// VersionSource names the file RuntimeVersion was read from
+	VersionSource string
+	// Lock pins base images by digest when the project has a lock file
+	Lock *models.LockFile
+	// Install is the dependency installation step chosen from the project's lockfiles
+	Install *InstallPlan
+}
+
+var versionPattern = regexp.MustCompile(`\d+(\.\d+)*`)
+
+// DetectProject inspects dir for the runtime version files of the language behind
+// run.command (.nvmrc and package.json engines for node and npx, .python-version for python
+// and uvx, go.mod for go), picks the package manager from the lockfiles and loads the base image lock if present
+func DetectProject(dir string, config *models.MCPConfig) (*ProjectInfo, error) {
+	project := &ProjectInfo{}
+
+	language := runtimeFor(config).Language
+
+	switch language {
+	case "node":
+		if version := readVersionFile(filepath.Join(dir, ".nvmrc")); version != "" {
+			project.RuntimeVersion, project.VersionSource = version, ".nvmrc"
+		} else if version := packageJSONEngine(filepath.Join(dir, "package.json")); version != "" {
+			project.RuntimeVersion, project.VersionSource = version, "package.json engines"
+		}
+	case "python":
+		if version := readVersionFile(filepath.Join(dir, ".python-version")); version != "" {
+			project.RuntimeVersion, project.VersionSource = version, ".python-version"
+		}
+	case "go":
+		if version := goModVersion(filepath.Join(dir, "go.mod")); version != "" {
+			project.RuntimeVersion, project.VersionSource = version, "go.mod"
+		}
+	}
+
+	override := ""
+	if config.Build != nil {
+		override = config.Build.PackageManager
+	}
+	install, err := DetectInstallPlan(dir, language, override)
+	if err != nil {
+		return nil, err
+	}
+	project.Install = install
+
+	lock, err := ReadLockFile(dir)
+	if err != nil {
+		return nil, err
+	}
+	project.Lock = lock
+
+	return project, nil
+}
+
+// readVersionFile returns the first line of a version file such as .nvmrc
+func readVersionFile(path string) string {
+	file, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" && !strings.HasPrefix(line, "#") {
+			return line
+		}
+	}
+	return ""
+}
+
+// packageJSONEngine returns engines.node from package.json
+func packageJSONEngine(path string) string {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	var pkg struct {
+		Engines map[string]string `json:"engines"`
+	}
+	if json.Unmarshal(content, &pkg) != nil {
+		return ""
+	}
+	return pkg.Engines["node"]
+}
+
+// goModVersion returns the go directive of go.mod
+func goModVersion(path string) string {
+	file, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && fields[0] == "go" {
+			return fields[1]
+		}
+	}
+	return ""
+}
+
+// ReadLockFile loads the base image lock in dir, returning nil when there is none
+func ReadLockFile(dir string) (*models.LockFile, error) {
+	content, err := os.ReadFile(filepath.Join(dir, LockFileName))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", LockFileName, err)
+	}
+
+	var lock models.LockFile
+	if err := json.Unmarshal(content, &lock); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", LockFileName, err)
+	}
+	return &lock, nil
+}
+
+// WriteLockFile saves the base image lock in dir
+func WriteLockFile(dir string, lock *models.LockFile) error {
+	content, err := json.MarshalIndent(lock, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, LockFileName), append(content, '\n'), 0644)
+}
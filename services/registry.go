@@ -0,0 +1,267 @@
+package services
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"mcphub/models"
+)
+
+// RegistryEnvVar selects the registry backend when no flag is given
+const RegistryEnvVar = "MCPHUB_REGISTRY"
+
+// Registry stores built MCP server images and hands them back on pull
+type Registry interface {
+	// Push publishes every built artifact of an MCP server
+	Push(result *models.DockerfileResponse) error
+	// Pull fetches author/name (optionally :tag) for platform into the local runtime and
+	// returns the local image name
+	Pull(author, imageName, tag, platform string) (string, error)
+	// NeedsArchives reports whether pushes need image tar archives
+	NeedsArchives() bool
+	// Published reports whether the version of config is published from sourceHash for
+	// exactly platforms (none for an image of the builder's platform only). It is false
+	// when that is unknown.
+	Published(config *models.MCPConfig, sourceHash string, platforms []string) bool
+	// Manifests describes every published server, for searching
+	Manifests() ([]*models.RegistryManifest, error)
+}
+
+// NewRegistry returns the backend for location: empty or "s3" for the default S3 bucket,
+// "s3://bucket" for another bucket and anything else (optionally "oci://" prefixed) for
+// an OCI Distribution registry such as "localhost:5000" or "ghcr.io/my-org".
+// When location is empty MCPHUB_REGISTRY is consulted. Status lines and the runtime's
+// push and pull output go to progress, which may be nil.
+func NewRegistry(location string, runtime *ContainerRuntime, progress io.Writer) (Registry, error) {
+	if location == "" {
+		location = os.Getenv(RegistryEnvVar)
+	}
+
+	switch {
+	case location == "" || location == "s3":
+		return newS3Registry("", runtime, progress)
+	case strings.HasPrefix(location, "s3://"):
+		return newS3Registry(strings.TrimPrefix(location, "s3://"), runtime, progress)
+	default:
+		host := strings.TrimSuffix(strings.TrimPrefix(location, "oci://"), "/")
+		if host == "" {
+			return nil, fmt.Errorf("invalid registry %q", location)
+		}
+		return &OCIRegistry{host: host, runtime: runtime, progress: orDiscard(progress)}, nil
+	}
+}
+
+// S3Registry stores `docker save` archives in an S3 bucket
+type S3Registry struct {
+	s3       *S3Service
+	runtime  *ContainerRuntime
+	progress io.Writer
+}
+
+func newS3Registry(bucket string, runtime *ContainerRuntime, progress io.Writer) (*S3Registry, error) {
+	s3Service, err := NewS3Service(bucket)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize S3 service: %v", err)
+	}
+	return &S3Registry{s3: s3Service, runtime: runtime, progress: orDiscard(progress)}, nil
+}
+
+func (r *S3Registry) NeedsArchives() bool {
+	return true
+}
+
+// Push uploads every platform archive to S3, followed by the manifest describing them
+func (r *S3Registry) Push(result *models.DockerfileResponse) error {
+	manifest := &models.RegistryManifest{
+		Name:          result.Config.Name,
+		Author:        result.Config.Author,
+		Version:       result.Config.Version,
+		SourceHash:    result.SourceHash,
+		Config:        result.Config,
+		PushedAt:      time.Now().UTC().Format(time.RFC3339),
+		Introspection: result.Introspection,
+	}
+
+	for _, artifact := range result.Artifacts {
+		if err := r.s3.PushMCP(result.Config.Author, result.Config.Name, artifact.Platform, artifact.TarFilePath); err != nil {
+			return fmt.Errorf("failed to upload to S3: %v", err)
+		}
+		if artifact.Platform != "" {
+			fmt.Fprintf(r.progress, "📦 Uploaded %s image\n", artifact.Platform)
+			manifest.Platforms = append(manifest.Platforms, artifact.Platform)
+		}
+	}
+
+	if err := r.s3.PutManifest(manifest); err != nil {
+		return fmt.Errorf("failed to upload manifest to S3: %v", err)
+	}
+	return nil
+}
+
+func (r *S3Registry) Published(config *models.MCPConfig, sourceHash string, platforms []string) bool {
+	manifest, err := r.s3.GetManifest(config.Author, config.Name)
+	if err != nil || manifest.Version != config.Version || manifest.SourceHash != sourceHash {
+		return false
+	}
+	return samePlatforms(manifest.Platforms, platforms)
+}
+
+func (r *S3Registry) Manifests() ([]*models.RegistryManifest, error) {
+	return r.s3.ListManifests()
+}
+
+// Pull downloads the archive for platform and loads it into the runtime. S3 keeps a single
+// version per server, so tag is ignored.
+func (r *S3Registry) Pull(author, imageName, tag, platform string) (string, error) {
+	tarFile, err := r.s3.PullMCP(author, imageName, platform)
+	if err != nil {
+		return "", fmt.Errorf("failed to download from S3: %v", err)
+	}
+
+	fmt.Fprintf(r.progress, "🐳 Loading image from %s into %s...\n", tarFile, r.runtime.DisplayName())
+	loadedImage, output, err := r.runtime.Load(tarFile)
+	if err != nil {
+		return "", err
+	}
+	if output != "" {
+		fmt.Fprintf(r.progress, "📝 %s output: %s\n", r.runtime.DisplayName(), output)
+	}
+	return loadedImage, nil
+}
+
+// OCIRegistry pushes images to an OCI Distribution registry through the container runtime,
+// reusing its login credentials. MCP metadata travels as image labels.
+type OCIRegistry struct {
+	host     string
+	runtime  *ContainerRuntime
+	progress io.Writer
+}
+
+func (r *OCIRegistry) NeedsArchives() bool {
+	return false
+}
+
+// Reference returns the remote image reference for author/name:tag
+func (r *OCIRegistry) Reference(author, imageName, tag string) string {
+	if tag == "" {
+		tag = "latest"
+	}
+	repository := strings.ToLower(imageName)
+	if author != "" {
+		repository = strings.ToLower(author) + "/" + repository
+	}
+	return fmt.Sprintf("%s/%s:%s", r.host, repository, tag)
+}
+
+// Push tags and pushes the image under its version and latest. Multi-platform builds push
+// one tag per platform and then a manifest list that ties them together.
+func (r *OCIRegistry) Push(result *models.DockerfileResponse) error {
+	tags := []string{"latest"}
+	if version := ociTag(result.Config.Version); version != "" && version != "latest" {
+		tags = append([]string{version}, tags...)
+	}
+
+	author, name := result.Config.Author, result.Config.Name
+	multiPlatform := len(result.Artifacts) > 1 || result.Artifacts[0].Platform != ""
+
+	if !multiPlatform {
+		for _, tag := range tags {
+			ref := r.Reference(author, name, tag)
+			if err := r.runtime.Tag(result.Artifacts[0].Image, ref); err != nil {
+				return err
+			}
+			if err := r.runtime.PushImage(ref, r.progress); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	var platformRefs []string
+	for _, artifact := range result.Artifacts {
+		ref := r.Reference(author, name, tags[0]+"-"+PlatformTag(artifact.Platform))
+		if err := r.runtime.Tag(artifact.Image, ref); err != nil {
+			return err
+		}
+		if err := r.runtime.PushImage(ref, r.progress); err != nil {
+			return err
+		}
+		platformRefs = append(platformRefs, ref)
+	}
+
+	for _, tag := range tags {
+		if err := r.runtime.PushManifestList(r.Reference(author, name, tag), platformRefs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *OCIRegistry) Published(config *models.MCPConfig, sourceHash string, platforms []string) bool {
+	ref := r.Reference(config.Author, config.Name, ociTag(config.Version))
+	if r.runtime.RemoteImageLabel(ref, LabelSourceHash) != sourceHash {
+		return false
+	}
+	published, err := r.runtime.RemotePlatforms(ref)
+	return err == nil && samePlatforms(published, platforms)
+}
+
+// Manifests is not supported: OCI registries cannot be listed portably, and the metadata
+// of their images is only known once pulled
+func (r *OCIRegistry) Manifests() ([]*models.RegistryManifest, error) {
+	return nil, fmt.Errorf("searching is not supported for OCI registries; pull the images and search locally")
+}
+
+// Pull does a regular, layer-deduplicated pull and tags the result with the short image
+// name so `mcphub run <name>` works the same as for S3 pulls.
+func (r *OCIRegistry) Pull(author, imageName, tag, platform string) (string, error) {
+	ref := r.Reference(author, imageName, tag)
+	if err := r.runtime.PullImage(ref, platform, r.progress); err != nil {
+		return "", err
+	}
+
+	localName := strings.ToLower(imageName)
+	if err := r.runtime.Tag(ref, localName); err != nil {
+		return "", err
+	}
+	return localName, nil
+}
+
+// samePlatforms reports whether both lists hold the same platforms, in any order
+func samePlatforms(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	a = append([]string{}, a...)
+	b = append([]string{}, b...)
+	sort.Strings(a)
+	sort.Strings(b)
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// ociTag turns a version into a valid OCI tag
+func ociTag(version string) string {
+	var tag strings.Builder
+	for _, c := range strings.TrimPrefix(version, "v") {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '_', c == '.', c == '-':
+			tag.WriteRune(c)
+		default:
+			tag.WriteRune('-')
+		}
+	}
+	result := tag.String()
+	if len(result) > 128 {
+		result = result[:128]
+	}
+	return strings.TrimLeft(result, ".-")
+}
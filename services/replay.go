@@ -0,0 +1,149 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"mcphub/mcp"
+)
+
+var (
+	timestampPattern = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}[T ]\d{2}:\d{2}(:\d{2}(\.\d+)?)?(Z|[+-]\d{2}:?\d{2})?$`)
+	uuidPattern      = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+)
+
+// ReplayOptions control how replayed responses are compared with recorded ones
+type ReplayOptions struct {
+	// Ignore lists the values left out of the comparison: JSON paths into the response such
+	// as $.result.content[*].text, where * matches any key or index, or key names, which
+	// are ignored at any depth
+	Ignore []string
+	// Strict compares timestamps and UUIDs too; they differ between runs, so by default
+	// any two compare equal
+	Strict bool
+}
+
+// ReplaySession replays a recorded session against image in a throwaway container, even when
+// a container started by `mcphub run` has the image's name, so sessions never share a server.
+// timeout bounds starting the server and waiting for each response; server stderr is copied
+// to logs.
+func ReplaySession(ctx context.Context, rt *ContainerRuntime, image string, env []string, session []mcp.RecordedMessage, timeout time.Duration, logs io.Writer) ([]mcp.ReplayedRequest, error) {
+	labels, err := rt.ImageLabels(image)
+	if err != nil {
+		return nil, err
+	}
+	startCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	server, err := startImage(startCtx, rt, image, labels, env, logs)
+	if err != nil {
+		return nil, err
+	}
+	defer server.transport.Close()
+	return mcp.Replay(ctx, server.transport, session, timeout), nil
+}
+
+// CompareResponses shows how a replayed response differs from the recorded one, as a line
+// diff of their results or errors; it returns "" when they match
+func CompareResponses(recorded, replayed *mcp.Message, options ReplayOptions) string {
+	expected := comparableResponse(recorded, options)
+	actual := comparableResponse(replayed, options)
+	if expected == actual {
+		return ""
+	}
+	return diffLines(expected, actual)
+}
+
+// comparableResponse renders the result or error of a response as indented JSON, with the
+// ignored values masked
+func comparableResponse(response *mcp.Message, options ReplayOptions) string {
+	document := map[string]any{}
+	if len(response.Result) > 0 {
+		var result any
+		json.Unmarshal(response.Result, &result)
+		document["result"] = result
+	}
+	if response.Error != nil {
+		var rpcErr any
+		content, _ := json.Marshal(response.Error)
+		json.Unmarshal(content, &rpcErr)
+		document["error"] = rpcErr
+	}
+
+	var rules [][]string
+	keys := map[string]bool{}
+	for _, rule := range options.Ignore {
+		if strings.HasPrefix(rule, "$") {
+			rules = append(rules, pathSegments(rule))
+		} else {
+			keys[rule] = true
+		}
+	}
+	return indentJSON(maskValues(document, nil, rules, keys, options.Strict))
+}
+
+// maskValues replaces the ignored values under path with "<ignored>", and timestamps and
+// UUIDs unless strict
+func maskValues(value any, path []string, rules [][]string, keys map[string]bool, strict bool) any {
+	for _, rule := range rules {
+		if matchesPath(rule, path) {
+			return "<ignored>"
+		}
+	}
+	switch value := value.(type) {
+	case map[string]any:
+		masked := make(map[string]any, len(value))
+		for key, item := range value {
+			if keys[key] {
+				masked[key] = "<ignored>"
+				continue
+			}
+			masked[key] = maskValues(item, append(path[:len(path):len(path)], key), rules, keys, strict)
+		}
+		return masked
+	case []any:
+		masked := make([]any, len(value))
+		for i, item := range value {
+			masked[i] = maskValues(item, append(path[:len(path):len(path)], strconv.Itoa(i)), rules, keys, strict)
+		}
+		return masked
+	case string:
+		switch {
+		case strict:
+		case timestampPattern.MatchString(value):
+			return "<timestamp>"
+		case uuidPattern.MatchString(value):
+			return "<uuid>"
+		}
+	}
+	return value
+}
+
+// pathSegments splits a JSON path such as $.result.content[*].text into its keys and indexes
+func pathSegments(path string) []string {
+	path = strings.TrimPrefix(path, "$")
+	path = strings.NewReplacer("[", ".", "]", "").Replace(path)
+	var segments []string
+	for _, segment := range strings.Split(path, ".") {
+		if segment != "" {
+			segments = append(segments, segment)
+		}
+	}
+	return segments
+}
+
+func matchesPath(rule, path []string) bool {
+	if len(rule) != len(path) {
+		return false
+	}
+	for i := range rule {
+		if rule[i] != "*" && rule[i] != path[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,596 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Supported container runtimes
+const (
+	RuntimeDocker       = "docker"
+	RuntimePodman       = "podman"
+	RuntimePodmanSocket = "podman-socket"
+	RuntimeNerdctl      = "nerdctl"
+)
+
+// RuntimeEnvVar selects the container runtime when no flag is given
+const RuntimeEnvVar = "MCPHUB_RUNTIME"
+
+// InsecureRegistriesEnvVar lists more registries, comma separated, reached over plain HTTP
+// or without verifying their certificates. Registries on localhost always are.
+const InsecureRegistriesEnvVar = "MCPHUB_INSECURE_REGISTRIES"
+
+// ContainerRuntime wraps the CLI of a container engine (docker, podman or nerdctl)
+// and hides the differences between them from the rest of MCPHub.
+type ContainerRuntime struct {
+	Name   string
+	binary string
+	env    []string
+}
+
+// NewContainerRuntime returns the named runtime. When name is empty, MCPHUB_RUNTIME is
+// consulted and otherwise the first working runtime on this machine is detected.
+func NewContainerRuntime(name string) (*ContainerRuntime, error) {
+	if name == "" {
+		name = os.Getenv(RuntimeEnvVar)
+	}
+
+	switch strings.ToLower(name) {
+	case "":
+		return detectContainerRuntime()
+	case RuntimeDocker:
+		return &ContainerRuntime{Name: RuntimeDocker, binary: "docker"}, nil
+	case RuntimePodman:
+		return &ContainerRuntime{Name: RuntimePodman, binary: "podman"}, nil
+	case RuntimeNerdctl:
+		return &ContainerRuntime{Name: RuntimeNerdctl, binary: "nerdctl"}, nil
+	case RuntimePodmanSocket:
+		socket := podmanSocketPath()
+		if socket == "" {
+			return nil, fmt.Errorf("podman socket not found; start it with 'systemctl --user start podman.socket'")
+		}
+		return newPodmanSocketRuntime(socket), nil
+	default:
+		return nil, fmt.Errorf("unsupported container runtime %q (use docker, podman, podman-socket or nerdctl)", name)
+	}
+}
+
+// detectContainerRuntime picks the first runtime that responds, preferring docker
+func detectContainerRuntime() (*ContainerRuntime, error) {
+	candidates := []*ContainerRuntime{
+		{Name: RuntimeDocker, binary: "docker"},
+		{Name: RuntimePodman, binary: "podman"},
+		{Name: RuntimeNerdctl, binary: "nerdctl"},
+	}
+
+	for _, rt := range candidates {
+		if _, err := exec.LookPath(rt.binary); err != nil {
+			continue
+		}
+		if rt.Available() {
+			return rt, nil
+		}
+	}
+
+	// The docker CLI can still talk to a rootless podman through its compatible socket
+	if _, err := exec.LookPath("docker"); err == nil {
+		if socket := podmanSocketPath(); socket != "" {
+			rt := newPodmanSocketRuntime(socket)
+			if rt.Available() {
+				return rt, nil
+			}
+		}
+	}
+
+	return nil, fmt.Errorf("no container runtime found. Install and start docker, podman or nerdctl")
+}
+
+func newPodmanSocketRuntime(socket string) *ContainerRuntime {
+	return &ContainerRuntime{
+		Name:   RuntimePodmanSocket,
+		binary: "docker",
+		env:    []string{"DOCKER_HOST=unix://" + socket},
+	}
+}
+
+// podmanSocketPath returns the path of the podman API socket, rootless first
+func podmanSocketPath() string {
+	var candidates []string
+	if runtimeDir := os.Getenv("XDG_RUNTIME_DIR"); runtimeDir != "" {
+		candidates = append(candidates, filepath.Join(runtimeDir, "podman", "podman.sock"))
+	}
+	candidates = append(candidates, "/run/podman/podman.sock")
+
+	for _, path := range candidates {
+		if info, err := os.Stat(path); err == nil && info.Mode()&os.ModeSocket != 0 {
+			return path
+		}
+	}
+	return ""
+}
+
+// DisplayName is the human readable runtime name used in messages
+func (r *ContainerRuntime) DisplayName() string {
+	switch r.Name {
+	case RuntimePodman, RuntimePodmanSocket:
+		return "Podman"
+	case RuntimeNerdctl:
+		return "nerdctl"
+	default:
+		return "Docker"
+	}
+}
+
+// Binary is the CLI executable used to talk to the runtime
+func (r *ContainerRuntime) Binary() string {
+	return r.binary
+}
+
+// Command prepares a runtime CLI invocation with the runtime environment applied
+func (r *ContainerRuntime) Command(args ...string) *exec.Cmd {
+	cmd := exec.Command(r.binary, args...)
+	if len(r.env) > 0 {
+		cmd.Env = append(os.Environ(), r.env...)
+	}
+	return cmd
+}
+
+// Available reports whether the runtime is installed and its engine is reachable
+func (r *ContainerRuntime) Available() bool {
+	return r.Command("info").Run() == nil
+}
+
+// Build builds an image from the given context directory with the specified image name.
+// A non-empty platform cross-builds for that platform (through buildx on docker).
+// Output is streamed live to options.Output when set and always written to a per-build
+// log file, whose path is returned. Failures are reported as *BuildError.
+func (r *ContainerRuntime) Build(buildContext, imageName, platform string, options BuildOptions) (string, error) {
+	logName := imageName
+	if platform != "" {
+		logName += "-" + PlatformTag(platform)
+	}
+	logFile, err := newBuildLog(options.LogDir, logName)
+	if err != nil {
+		return "", err
+	}
+	logPath := logFile.Name()
+	defer logFile.Close()
+
+	cmd := r.Command(r.buildArgs(imageName, platform, options)...)
+	cmd.Dir = buildContext
+	if r.binary == "docker" {
+		// Plain progress keeps BuildKit output line based when it is not a terminal
+		cmd.Env = append(append(os.Environ(), r.env...), "BUILDKIT_PROGRESS=plain")
+	}
+
+	var output io.Writer = logFile
+	if options.Output != nil {
+		output = io.MultiWriter(logFile, options.Output)
+	}
+	cmd.Stdout = output
+	cmd.Stderr = output
+
+	if err := cmd.Run(); err != nil {
+		logFile.Close()
+		step, tail := summarizeBuildFailure(readLog(logPath))
+		return logPath, &BuildError{Err: err, Step: step, Tail: tail, LogPath: logPath}
+	}
+	return logPath, nil
+}
+
+// buildArgs returns the CLI arguments for building imageName, optionally for another platform
+func (r *ContainerRuntime) buildArgs(imageName, platform string, options BuildOptions) []string {
+	var args []string
+	if platform != "" && r.binary == "docker" {
+		// --load keeps the single-platform result in the local image store so it can be saved
+		args = []string{"buildx", "build", "--platform", platform, "--load"}
+	} else {
+		args = []string{"build"}
+		if platform != "" {
+			args = append(args, "--platform", platform)
+		}
+	}
+
+	if r.Name == RuntimeNerdctl {
+		args = append(args, "--progress=plain")
+	}
+
+	if options.Dockerfile != "" && options.Dockerfile != "Dockerfile" {
+		args = append(args, "-f", options.Dockerfile)
+	}
+	for _, key := range sortedKeys(options.BuildArgs) {
+		args = append(args, "--build-arg", key+"="+options.BuildArgs[key])
+	}
+	for _, key := range sortedKeys(options.Labels) {
+		args = append(args, "--label", key+"="+options.Labels[key])
+	}
+
+	return append(args, "-t", imageName, ".")
+}
+
+func sortedKeys(values map[string]string) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// SupportsPlatformBuilds reports whether cross-platform builds are possible
+func (r *ContainerRuntime) SupportsPlatformBuilds() error {
+	if r.binary != "docker" {
+		return nil
+	}
+	if err := r.Command("buildx", "version").Run(); err != nil {
+		return fmt.Errorf("multi-platform builds need docker buildx; install the buildx plugin and QEMU binfmt handlers")
+	}
+	return nil
+}
+
+// Save writes the image to a docker-archive tarball that every runtime can load
+func (r *ContainerRuntime) Save(imageName, tarFilePath string) error {
+	args := []string{"save", "-o", tarFilePath}
+	if r.Name == RuntimePodman {
+		// podman defaults to docker-archive today, but be explicit so the tar stays portable
+		args = append(args, "--format", "docker-archive")
+	}
+	args = append(args, imageName)
+
+	output, err := r.Command(args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s save failed: %w\nOutput: %s", r.binary, err, output)
+	}
+	return nil
+}
+
+// Load imports an image tarball and returns the name of the loaded image when it can be determined
+func (r *ContainerRuntime) Load(tarFilePath string) (string, string, error) {
+	output, err := r.Command("load", "-i", tarFilePath).CombinedOutput()
+	if err != nil {
+		return "", string(output), fmt.Errorf("failed to load image: %s", string(output))
+	}
+	return parseLoadedImage(string(output)), strings.TrimSpace(string(output)), nil
+}
+
+// ImageLabel returns a label of a local image, or "" when the image or label does not exist
+func (r *ContainerRuntime) ImageLabel(image, key string) string {
+	format := fmt.Sprintf(`{{ index .Config.Labels %q }}`, key)
+	output, err := r.Command("image", "inspect", "--format", format, image).Output()
+	if err != nil {
+		return ""
+	}
+	value := strings.TrimSpace(string(output))
+	if value == "<no value>" {
+		return ""
+	}
+	return value
+}
+
+// ImageLabels returns all labels of a local image
+func (r *ContainerRuntime) ImageLabels(image string) (map[string]string, error) {
+	output, err := r.Command("image", "inspect", "--format", "{{json .Config.Labels}}", image).Output()
+	if err != nil {
+		return nil, fmt.Errorf("image %s not found in %s", image, r.DisplayName())
+	}
+	labels := map[string]string{}
+	if err := json.Unmarshal(output, &labels); err != nil {
+		return nil, fmt.Errorf("failed to read labels of %s: %w", image, err)
+	}
+	return labels, nil
+}
+
+// ImagesWithLabel lists the local images carrying label, one reference per repository
+// (its latest tag when there is one)
+func (r *ContainerRuntime) ImagesWithLabel(label string) ([]string, error) {
+	output, err := r.Command("images", "--filter", "label="+label, "--format", "{{.Repository}}:{{.Tag}}").Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list images: %w", err)
+	}
+
+	var repositories []string
+	refs := map[string]string{}
+	for _, line := range strings.Split(string(output), "\n") {
+		line = strings.TrimSpace(line)
+		separator := strings.LastIndex(line, ":")
+		if separator < 0 {
+			continue
+		}
+		repository, tag := line[:separator], line[separator+1:]
+		if repository == "" || repository == "<none>" || tag == "<none>" {
+			continue
+		}
+		if _, seen := refs[repository]; !seen {
+			repositories = append(repositories, repository)
+		}
+		if refs[repository] == "" || tag == "latest" {
+			refs[repository] = repository + ":" + tag
+		}
+	}
+
+	images := make([]string, 0, len(repositories))
+	for _, repository := range repositories {
+		images = append(images, refs[repository])
+	}
+	return images, nil
+}
+
+// RemoteImageLabel reads a label of an image in a registry without pulling it.
+// It returns "" when the image does not exist or the runtime cannot inspect remote images.
+func (r *ContainerRuntime) RemoteImageLabel(ref, key string) string {
+	var output []byte
+	var err error
+	switch {
+	case r.binary == "docker":
+		output, err = r.Command("buildx", "imagetools", "inspect", "--format", "{{ json .Image }}", ref).Output()
+	case r.Name == RuntimePodman:
+		if _, lookErr := exec.LookPath("skopeo"); lookErr != nil {
+			return ""
+		}
+		args := []string{"inspect", "--format", "{{ json .Labels }}"}
+		if insecureRegistry(ref) {
+			args = append(args, "--tls-verify=false")
+		}
+		output, err = exec.Command("skopeo", append(args, "docker://"+ref)...).Output()
+		if err == nil {
+			var labels map[string]string
+			if json.Unmarshal(output, &labels) == nil {
+				return labels[key]
+			}
+		}
+		return ""
+	default:
+		return ""
+	}
+	if err != nil {
+		return ""
+	}
+	return labelFromImageConfig(output, key)
+}
+
+// RemotePlatforms lists the platforms of the manifest list ref points to in its registry, or
+// none when it is a single image
+func (r *ContainerRuntime) RemotePlatforms(ref string) ([]string, error) {
+	var output []byte
+	var err error
+	switch {
+	case r.binary == "docker":
+		output, err = r.Command("buildx", "imagetools", "inspect", "--raw", ref).Output()
+	case r.Name == RuntimePodman:
+		if _, err := exec.LookPath("skopeo"); err != nil {
+			return nil, fmt.Errorf("skopeo is required to inspect remote images with podman; install it and try again")
+		}
+		args := []string{"inspect", "--raw"}
+		if insecureRegistry(ref) {
+			args = append(args, "--tls-verify=false")
+		}
+		output, err = exec.Command("skopeo", append(args, "docker://"+ref)...).Output()
+	default:
+		return nil, fmt.Errorf("%s cannot inspect remote images", r.binary)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to inspect %s: %w", ref, err)
+	}
+	return platformsFromManifest(output)
+}
+
+// platformsFromManifest lists the platforms of a raw image index or manifest list. Entries
+// without a real platform, such as buildx attestations, are skipped.
+func platformsFromManifest(raw []byte) ([]string, error) {
+	var index struct {
+		Manifests []struct {
+			Platform *struct {
+				OS           string `json:"os"`
+				Architecture string `json:"architecture"`
+				Variant      string `json:"variant"`
+			} `json:"platform"`
+		} `json:"manifests"`
+	}
+	if err := json.Unmarshal(raw, &index); err != nil {
+		return nil, fmt.Errorf("invalid image manifest: %w", err)
+	}
+	var platforms []string
+	for _, manifest := range index.Manifests {
+		platform := manifest.Platform
+		if platform == nil || platform.OS == "unknown" || platform.Architecture == "unknown" {
+			continue
+		}
+		name := platform.OS + "/" + platform.Architecture
+		// arm64 only has v8, which registries may or may not spell out
+		if platform.Variant != "" && name != "linux/arm64" {
+			name += "/" + platform.Variant
+		}
+		platforms = append(platforms, name)
+	}
+	return platforms, nil
+}
+
+// labelFromImageConfig finds a label in `imagetools inspect` output, which is a single image
+// config for single-platform images and a map of platform to config for indexes
+func labelFromImageConfig(output []byte, key string) string {
+	type imageConfig struct {
+		Config struct {
+			Labels map[string]string `json:"Labels"`
+		} `json:"config"`
+	}
+
+	var single imageConfig
+	if json.Unmarshal(output, &single) == nil && single.Config.Labels != nil {
+		return single.Config.Labels[key]
+	}
+
+	var index map[string]imageConfig
+	if json.Unmarshal(output, &index) == nil {
+		for _, config := range index {
+			if value := config.Config.Labels[key]; value != "" {
+				return value
+			}
+		}
+	}
+	return ""
+}
+
+// ImageDigest pulls ref and returns the registry digest it resolved to (sha256:...)
+func (r *ContainerRuntime) ImageDigest(ref string) (string, error) {
+	if output, err := r.Command("pull", "-q", ref).CombinedOutput(); err != nil {
+		return "", fmt.Errorf("%s pull %s failed: %w\nOutput: %s", r.binary, ref, err, output)
+	}
+
+	output, err := r.Command("image", "inspect", "--format", "{{ json .RepoDigests }}", ref).Output()
+	if err != nil {
+		return "", fmt.Errorf("%s image inspect %s failed: %w", r.binary, ref, err)
+	}
+	var repoDigests []string
+	if err := json.Unmarshal(output, &repoDigests); err != nil {
+		return "", fmt.Errorf("unexpected image inspect output for %s: %w", ref, err)
+	}
+	for _, repoDigest := range repoDigests {
+		if _, digest, ok := strings.Cut(repoDigest, "@"); ok {
+			return digest, nil
+		}
+	}
+	return "", fmt.Errorf("no registry digest recorded for %s", ref)
+}
+
+// Tag adds target as another name for the source image
+func (r *ContainerRuntime) Tag(source, target string) error {
+	output, err := r.Command("tag", source, target).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s tag failed: %w\nOutput: %s", r.binary, err, output)
+	}
+	return nil
+}
+
+// PushImage pushes a tagged image to its registry using the runtime's stored credentials,
+// writing the runtime's progress to output
+func (r *ContainerRuntime) PushImage(ref string, output io.Writer) error {
+	cmd := r.Command(append(append([]string{"push"}, r.insecureFlags("push", ref)...), ref)...)
+	cmd.Stdout = output
+	cmd.Stderr = output
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s push %s failed: %w", r.binary, ref, err)
+	}
+	return nil
+}
+
+// PullImage pulls ref for the given platform (empty for the runtime default), writing the
+// runtime's progress to output
+func (r *ContainerRuntime) PullImage(ref, platform string, output io.Writer) error {
+	args := append([]string{"pull"}, r.insecureFlags("pull", ref)...)
+	if platform != "" {
+		args = append(args, "--platform", platform)
+	}
+	cmd := r.Command(append(args, ref)...)
+	cmd.Stdout = output
+	cmd.Stderr = output
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s pull %s failed: %w", r.binary, ref, err)
+	}
+	return nil
+}
+
+// PushManifestList publishes ref as a multi-platform index over already pushed per-platform images
+func (r *ContainerRuntime) PushManifestList(ref string, images []string) error {
+	var steps [][]string
+	switch r.Name {
+	case RuntimeDocker, RuntimePodmanSocket:
+		create := append([]string{"manifest", "create"}, r.insecureFlags("manifest", ref)...)
+		steps = append(steps, append(append(create, "--amend", ref), images...))
+		push := append([]string{"manifest", "push"}, r.insecureFlags("manifest", ref)...)
+		steps = append(steps, append(push, "--purge", ref))
+	case RuntimePodman:
+		r.Command("manifest", "rm", ref).Run() // a stale local list would be amended otherwise
+		steps = append(steps, []string{"manifest", "create", ref})
+		for _, image := range images {
+			add := append([]string{"manifest", "add"}, r.insecureFlags("manifest", image)...)
+			steps = append(steps, append(add, ref, "docker://"+image))
+		}
+		push := append([]string{"manifest", "push"}, r.insecureFlags("manifest", ref)...)
+		steps = append(steps, append(push, "--all", ref, "docker://"+ref))
+	default:
+		return fmt.Errorf("%s cannot publish multi-platform manifests; push with docker or podman", r.binary)
+	}
+
+	for _, args := range steps {
+		if output, err := r.Command(args...).CombinedOutput(); err != nil {
+			return fmt.Errorf("%s %s failed: %w\nOutput: %s", r.binary, strings.Join(args[:2], " "), err, output)
+		}
+	}
+	return nil
+}
+
+// insecureFlags returns the flags a registry command ("push", "pull" or "manifest") needs to
+// reach the registry of ref when it is insecure. The docker daemon trusts loopback
+// registries on push and pull by itself, and other hosts once they are listed in its
+// insecure-registries setting; its manifest commands and the other runtimes need flags.
+func (r *ContainerRuntime) insecureFlags(command, ref string) []string {
+	if !insecureRegistry(ref) {
+		return nil
+	}
+	switch r.Name {
+	case RuntimePodman:
+		return []string{"--tls-verify=false"}
+	case RuntimeNerdctl:
+		return []string{"--insecure-registry"}
+	}
+	if command == "manifest" {
+		return []string{"--insecure"}
+	}
+	return nil
+}
+
+// insecureRegistry reports whether the registry of ref is on localhost or listed in
+// MCPHUB_INSECURE_REGISTRIES
+func insecureRegistry(ref string) bool {
+	registry, _, _ := strings.Cut(strings.TrimPrefix(ref, "docker://"), "/")
+	for _, entry := range strings.Split(os.Getenv(InsecureRegistriesEnvVar), ",") {
+		if entry = strings.TrimSpace(entry); entry != "" && strings.EqualFold(strings.TrimSuffix(entry, "/"), registry) {
+			return true
+		}
+	}
+	host := registry
+	if name, _, err := net.SplitHostPort(registry); err == nil {
+		host = name
+	}
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	ip := net.ParseIP(strings.Trim(host, "[]"))
+	return ip != nil && ip.IsLoopback()
+}
+
+// parseLoadedImage extracts the image reference from docker, podman or nerdctl load output
+func parseLoadedImage(output string) string {
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(line, "Loaded image:"):
+			return strings.TrimSpace(strings.TrimPrefix(line, "Loaded image:"))
+		case strings.HasPrefix(line, "Loaded image(s):"):
+			images := strings.Split(strings.TrimPrefix(line, "Loaded image(s):"), ",")
+			return strings.TrimSpace(images[0])
+		case strings.HasPrefix(line, "unpacking "):
+			// nerdctl: "unpacking docker.io/library/name:latest (sha256:...)...done"
+			fields := strings.Fields(line)
+			if len(fields) > 1 {
+				return fields[1]
+			}
+		}
+	}
+	return ""
+}
+
+// LocalImageName strips the registry prefixes runtimes add to locally built images
+func LocalImageName(image string) string {
+	for _, prefix := range []string{"localhost/", "docker.io/library/"} {
+		image = strings.TrimPrefix(image, prefix)
+	}
+	return image
+}
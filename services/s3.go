@@ -1,16 +1,22 @@
 package services
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
 	"strings"
 
+	"mcphub/models"
+
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
 )
 
 type S3Service struct {
@@ -18,22 +24,38 @@ type S3Service struct {
 	bucket string
 }
 
-func NewS3Service() (*S3Service, error) {
+// DefaultBucket is the S3 bucket used when none is configured
+const DefaultBucket = "mcp-servers"
+
+// NewS3Service creates an S3 client for bucket, or DefaultBucket when empty
+func NewS3Service(bucket string) (*S3Service, error) {
 	cfg, err := config.LoadDefaultConfig(context.TODO())
 	if err != nil {
 		return nil, fmt.Errorf("unable to load SDK config: %v", err)
 	}
 
+	if bucket == "" {
+		bucket = DefaultBucket
+	}
+
 	client := s3.NewFromConfig(cfg)
 	return &S3Service{
 		client: client,
-		bucket: "mcp-servers", // You can make this configurable
+		bucket: bucket,
 	}, nil
 }
 
-// PushMCP uploads a tar file to S3
-func (s *S3Service) PushMCP(author, imageName, tarPath string) error {
-	objectKey := fmt.Sprintf("%s/%s.tar", author, imageName)
+// objectKey returns the S3 key of an image archive; per-platform archives carry an @os-arch suffix
+func objectKey(author, imageName, platform string) string {
+	if platform == "" {
+		return fmt.Sprintf("%s/%s.tar", author, imageName)
+	}
+	return fmt.Sprintf("%s/%s@%s.tar", author, imageName, PlatformTag(platform))
+}
+
+// PushMCP uploads a tar file to S3. platform is empty for host-only builds
+func (s *S3Service) PushMCP(author, imageName, platform, tarPath string) error {
+	objectKey := objectKey(author, imageName, platform)
 
 	file, err := os.Open(tarPath)
 	if err != nil {
@@ -60,41 +82,118 @@ func (s *S3Service) PushMCP(author, imageName, tarPath string) error {
 	return nil
 }
 
-// PullMCP downloads a tar file from S3
-func (s *S3Service) PullMCP(author, imageName string) error {
-	objectKey := fmt.Sprintf("%s/%s.tar", author, imageName)
-
+// PullMCP downloads the tar file matching platform from S3 and returns the path it was
+// saved to. The platform-independent archive of servers pushed before per-platform
+// archives is only used when the server has no per-platform archives at all, since it
+// may be a stale image of another platform otherwise.
+func (s *S3Service) PullMCP(author, imageName, platform string) (string, error) {
 	result, err := s.client.GetObject(context.TODO(), &s3.GetObjectInput{
 		Bucket: aws.String(s.bucket),
-		Key:    aws.String(objectKey),
+		Key:    aws.String(objectKey(author, imageName, platform)),
 	})
+
+	var noSuchKey *types.NoSuchKey
+	if errors.As(err, &noSuchKey) {
+		available, listErr := s.listPlatforms(author, imageName)
+		if listErr != nil {
+			return "", listErr
+		}
+		if len(available) > 0 {
+			return "", fmt.Errorf("no image for platform %s (available: %s)", platform, strings.Join(available, ", "))
+		}
+		result, err = s.client.GetObject(context.TODO(), &s3.GetObjectInput{
+			Bucket: aws.String(s.bucket),
+			Key:    aws.String(objectKey(author, imageName, "")),
+		})
+	}
 	if err != nil {
-		return fmt.Errorf("error downloading from S3: %v", err)
+		return "", fmt.Errorf("error downloading from S3: %v", err)
 	}
 	defer result.Body.Close()
 
 	// Create downloaded directory if it doesn't exist
 	downloadedDir := "downloaded"
 	if err := os.MkdirAll(downloadedDir, 0755); err != nil {
-		return fmt.Errorf("error creating downloaded directory: %v", err)
+		return "", fmt.Errorf("error creating downloaded directory: %v", err)
 	}
 
 	// Create the output file
 	outputPath := filepath.Join(downloadedDir, fmt.Sprintf("%s.tar", imageName))
 	file, err := os.Create(outputPath)
 	if err != nil {
-		return fmt.Errorf("error creating output file: %v", err)
+		return "", fmt.Errorf("error creating output file: %v", err)
 	}
 	defer file.Close()
 
 	// Copy the S3 object body to the file
 	if _, err := io.Copy(file, result.Body); err != nil {
-		return fmt.Errorf("error writing to file: %v", err)
+		return "", fmt.Errorf("error writing to file: %v", err)
+	}
+
+	return outputPath, nil
+}
+
+// listPlatforms returns the platforms that have an archive for author/imageName
+func (s *S3Service) listPlatforms(author, imageName string) ([]string, error) {
+	prefix := fmt.Sprintf("%s/%s@", author, imageName)
+	result, err := s.client.ListObjectsV2(context.TODO(), &s3.ListObjectsV2Input{
+		Bucket: aws.String(s.bucket),
+		Prefix: aws.String(prefix),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error listing objects: %v", err)
+	}
+
+	var platforms []string
+	for _, obj := range result.Contents {
+		tag := strings.TrimSuffix(strings.TrimPrefix(*obj.Key, prefix), ".tar")
+		platforms = append(platforms, strings.Replace(tag, "-", "/", 2))
+	}
+	return platforms, nil
+}
+
+// manifestKey returns the S3 key of the manifest describing author/imageName
+func manifestKey(author, imageName string) string {
+	return fmt.Sprintf("%s/%s.json", author, imageName)
+}
+
+// PutManifest uploads the manifest describing a pushed MCP server
+func (s *S3Service) PutManifest(manifest *models.RegistryManifest) error {
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding manifest: %v", err)
 	}
 
+	_, err = s.client.PutObject(context.TODO(), &s3.PutObjectInput{
+		Bucket:      aws.String(s.bucket),
+		Key:         aws.String(manifestKey(manifest.Author, manifest.Name)),
+		Body:        bytes.NewReader(data),
+		ContentType: aws.String("application/json"),
+	})
+	if err != nil {
+		return fmt.Errorf("error uploading manifest: %v", err)
+	}
 	return nil
 }
 
+// GetManifest downloads the manifest describing author/imageName
+func (s *S3Service) GetManifest(author, imageName string) (*models.RegistryManifest, error) {
+	result, err := s.client.GetObject(context.TODO(), &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(manifestKey(author, imageName)),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error downloading manifest: %v", err)
+	}
+	defer result.Body.Close()
+
+	var manifest models.RegistryManifest
+	if err := json.NewDecoder(result.Body).Decode(&manifest); err != nil {
+		return nil, fmt.Errorf("error decoding manifest: %v", err)
+	}
+	return &manifest, nil
+}
+
 // ListMCPs lists all MCPs in the S3 bucket
 func (s *S3Service) ListMCPs() ([]string, error) {
 	result, err := s.client.ListObjectsV2(context.TODO(), &s3.ListObjectsV2Input{
@@ -105,13 +204,51 @@ func (s *S3Service) ListMCPs() ([]string, error) {
 	}
 
 	var mcps []string
+	seen := map[string]bool{}
 	for _, obj := range result.Contents {
 		key := *obj.Key
 		if strings.HasSuffix(key, ".tar") {
-			// Remove .tar extension and add to list
-			mcps = append(mcps, strings.TrimSuffix(key, ".tar"))
+			// Remove .tar extension and platform suffix, listing each MCP once
+			name := strings.TrimSuffix(key, ".tar")
+			if i := strings.Index(name, "@"); i >= 0 {
+				name = name[:i]
+			}
+			if !seen[name] {
+				seen[name] = true
+				mcps = append(mcps, name)
+			}
 		}
 	}
 
 	return mcps, nil
+}
+
+// ListManifests downloads the manifest of every MCP in the bucket, reading every page of
+// the listing
+func (s *S3Service) ListManifests() ([]*models.RegistryManifest, error) {
+	paginator := s3.NewListObjectsV2Paginator(s.client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(s.bucket),
+	})
+
+	var manifests []*models.RegistryManifest
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(context.TODO())
+		if err != nil {
+			return nil, fmt.Errorf("error listing objects: %v", err)
+		}
+		for _, obj := range page.Contents {
+			key := *obj.Key
+			author, name, found := strings.Cut(strings.TrimSuffix(key, ".json"), "/")
+			if !strings.HasSuffix(key, ".json") || !found || strings.Contains(name, "/") {
+				continue
+			}
+			manifest, err := s.GetManifest(author, name)
+			if err != nil {
+				return nil, err
+			}
+			manifests = append(manifests, manifest)
+		}
+	}
+
+	return manifests, nil
 }
\ No newline at end of file
@@ -1,13 +1,34 @@
 package services
 
 import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
 	"testing"
+	"time"
 
+	"mcphub/mcp"
 	"mcphub/models"
 
 	"github.com/stretchr/testify/assert"
 )
 
+// generateDockerfile renders the Dockerfile of config, failing the test on template errors
+func generateDockerfile(t testing.TB, generator *DockerfileGenerator, config *models.MCPConfig, project *ProjectInfo) string {
+	t.Helper()
+	output, err := generator.GenerateForProject(config, project)
+	if err != nil {
+		t.Fatalf("failed to generate Dockerfile: %v", err)
+	}
+	return output
+}
+
 func TestDockerfileGenerator_Generate(t *testing.T) {
 	generator := NewDockerfileGenerator()
 
@@ -23,7 +44,7 @@ func TestDockerfileGenerator_Generate(t *testing.T) {
 			},
 		}
 
-		output := generator.Generate(&config)
+		output := generateDockerfile(t, generator, &config, nil)
 
 		assert.Contains(t, output, "FROM python:3.11-slim")
 		assert.Contains(t, output, "EXPOSE 5000")
@@ -43,11 +64,1172 @@ func TestDockerfileGenerator_Generate(t *testing.T) {
 			},
 		}
 
-		output := generator.Generate(&config)
+		output := generateDockerfile(t, generator, &config, nil)
 
 		assert.Contains(t, output, "FROM node:18-alpine")
 		assert.Contains(t, output, "EXPOSE 8080")
 		assert.Contains(t, output, `CMD ["node", "server.js"]`)
 		assert.Contains(t, output, "npm install")
 	})
+
+	t.Run("Go application", func(t *testing.T) {
+		config := models.MCPConfig{
+			Name: "go-app",
+			Run: models.RunConfig{
+				Command: "go",
+				Args:    []string{"run", "./cmd/server", "--stdio"},
+			},
+		}
+
+		output := generateDockerfile(t, generator, &config, nil)
+
+		assert.Contains(t, output, "FROM golang:1.21-alpine AS builder")
+		assert.Contains(t, output, "-o /out/server ./cmd/server")
+		assert.Contains(t, output, "FROM alpine:3.19")
+		assert.Contains(t, output, "USER mcp")
+		assert.Contains(t, output, `CMD ["/app/server", "--stdio"]`)
+		assert.NotContains(t, output, `CMD ["go"`)
+	})
+}
+
+func TestDockerfileGenerator_LanguageRuntimes(t *testing.T) {
+	generate := func(command string, args ...string) string {
+		config := models.MCPConfig{Name: "app", Run: models.RunConfig{Command: command, Args: args}}
+		return generateDockerfile(t, NewDockerfileGenerator(), &config, nil)
+	}
+
+	t.Run("npx pre-installs the package", func(t *testing.T) {
+		output := generate("npx", "-y", "@modelcontextprotocol/server-filesystem@^2025.1.0", "/data")
+		assert.Contains(t, output, "FROM node:18-alpine AS builder")
+		assert.Contains(t, output, "RUN npm install --omit=dev --no-save '@modelcontextprotocol/server-filesystem@^2025.1.0'")
+		assert.Contains(t, output, `CMD ["npx", "-y", "@modelcontextprotocol/server-filesystem@^2025.1.0", "/data"]`)
+	})
+
+	t.Run("uvx pre-installs the tool", func(t *testing.T) {
+		output := generate("uvx", "--python", "3.12", "--from", "mcp-server-git==0.6.2", "mcp-server-git")
+		assert.Contains(t, output, "FROM ghcr.io/astral-sh/uv:python3.12-bookworm-slim AS builder")
+		assert.Contains(t, output, "RUN uv tool install mcp-server-git==0.6.2")
+		assert.Contains(t, output, "COPY --from=builder /opt/uv /opt/uv")
+		assert.Contains(t, generate("uvx", "--python", "3.12", "mcp-server-time"), "RUN uv tool install mcp-server-time\n")
+	})
+
+	t.Run("deno caches the entrypoint", func(t *testing.T) {
+		output := generate("deno", "run", "--allow-net", "-c", "deno.json", "main.ts")
+		assert.Contains(t, output, "FROM denoland/deno:2.1.4 AS builder")
+		assert.Contains(t, output, "RUN deno cache main.ts")
+	})
+
+	t.Run("rust runs the installed binary", func(t *testing.T) {
+		output := generate("cargo", "run", "--release", "--bin", "server", "--", "--stdio")
+		assert.Contains(t, output, "FROM rust:1-slim AS builder")
+		assert.Contains(t, output, "cargo install --path . --root /out --bin server")
+		assert.Contains(t, output, "FROM debian:bookworm-slim")
+		assert.Contains(t, output, `CMD ["/app/server", "--stdio"]`)
+	})
+
+	t.Run("dotnet publishes the project", func(t *testing.T) {
+		output := generate("dotnet", "run", "--", "--stdio")
+		assert.Contains(t, output, "FROM mcr.microsoft.com/dotnet/sdk:8.0 AS builder")
+		assert.Contains(t, output, "FROM mcr.microsoft.com/dotnet/aspnet:8.0")
+		assert.Contains(t, output, `CMD ["dotnet", "/app/server.dll", "--stdio"]`)
+		assert.NotContains(t, generate("dotnet", "Server.dll"), "dotnet publish")
+	})
+
+	t.Run("java and bun", func(t *testing.T) {
+		assert.Contains(t, generate("java", "-jar", "server.jar"), "FROM eclipse-temurin:21-jre")
+		assert.Contains(t, generate("bunx", "some-mcp"), "RUN bun add some-mcp")
+	})
+
+	t.Run("unknown commands", func(t *testing.T) {
+		assert.Contains(t, generate("./server"), "FROM ubuntu:22.04 AS builder")
+	})
+
+	t.Run("runtime name", func(t *testing.T) {
+		config := models.MCPConfig{
+			Name:    "app",
+			Run:     models.RunConfig{Command: "npx", Args: []string{"-y", "some-mcp"}},
+			Runtime: &models.RuntimeConfig{Name: "node", Version: "22"},
+		}
+		output := generateDockerfile(t, NewDockerfileGenerator(), &config, nil)
+		assert.Contains(t, output, "FROM node:22-alpine AS builder")
+		assert.Contains(t, output, "npm install --omit=dev --no-save some-mcp")
+	})
+}
+
+func TestDockerfileGenerator_Escaping(t *testing.T) {
+	config := models.MCPConfig{
+		Name:        `weird "name"`,
+		Version:     "1.0.0",
+		Description: "Line one\nCMD [\"sh\"]\ncosts $5 \\",
+		Author:      "me\r\nUSER root",
+		Run:         models.RunConfig{Command: "node", Args: []string{`say "hi"`, `C:\path`, "two\nlines"}},
+		Build:       &models.BuildConfig{Run: []string{"echo one\necho two", "apt-get update \\"}},
+	}
+
+	output := generateDockerfile(t, NewDockerfileGenerator(), &config, nil)
+
+	assert.Contains(t, output, `LABEL org.opencontainers.image.title="weird \"name\""`)
+	assert.Contains(t, output, `LABEL org.opencontainers.image.description="Line one\nCMD [\"sh\"]\ncosts \$5 \\"`)
+	assert.Contains(t, output, `LABEL org.opencontainers.image.authors="me\nUSER root"`)
+	assert.Contains(t, output, `CMD ["node", "say \"hi\"", "C:\\path", "two\nlines"]`)
+	assert.Contains(t, output, `RUN ["/bin/sh", "-c", "echo one\necho two"]`)
+	assert.Contains(t, output, `RUN ["/bin/sh", "-c", "apt-get update \\"]`)
+	assert.Equal(t, []string{"USER"}, filterInstructions(dockerfileInstructions(output), "USER"))
+}
+
+func TestDockerfileGenerator_Healthcheck(t *testing.T) {
+	generator := NewDockerfileGenerator()
+	config := models.MCPConfig{Name: "app", Run: models.RunConfig{Command: "python3", Args: []string{"server.py"}, Port: 8000}}
+
+	output := generateDockerfile(t, generator, &config, nil)
+	assert.Contains(t, output, "HEALTHCHECK --interval=30s --timeout=5s --start-period=10s --retries=3")
+	assert.Contains(t, output, `CMD ["bash", "-c", "post() { exec 3<>/dev/tcp/127.0.0.1/8000`)
+	assert.Contains(t, output, `\"method\":\"initialize\"`)
+	// Later probes ping the session of the first one instead of opening one each
+	assert.Contains(t, output, `\"method\":\"ping\"`)
+	assert.Contains(t, output, healthcheckSessionFile)
+	assert.NotContains(t, output, "curl")
+
+	config.Run.Command = "node"
+	config.Run.Transport = models.TransportSSE
+	config.Healthcheck = &models.HealthcheckConfig{Interval: "1m", Retries: 5}
+	output = generateDockerfile(t, generator, &config, nil)
+	assert.Contains(t, output, "HEALTHCHECK --interval=1m --timeout=5s --start-period=10s --retries=5")
+	assert.Contains(t, output, `CMD ["wget", "-q", "--spider", "http://127.0.0.1:8000/sse"]`)
+
+	config.Healthcheck = &models.HealthcheckConfig{Path: "/healthz"}
+	assert.Contains(t, generateDockerfile(t, generator, &config, nil), "http://127.0.0.1:8000/healthz")
+
+	config.Healthcheck = &models.HealthcheckConfig{Disabled: true}
+	assert.Contains(t, generateDockerfile(t, generator, &config, nil), "HEALTHCHECK NONE")
+
+	stdio := models.MCPConfig{Name: "app", Run: models.RunConfig{Command: "node", Args: []string{"index.js"}}}
+	assert.NotContains(t, generateDockerfile(t, generator, &stdio, nil), "HEALTHCHECK")
+
+	assert.Error(t, validateConfig(&models.MCPConfig{Run: models.RunConfig{Transport: "http"}}))
+	assert.Error(t, validateConfig(&models.MCPConfig{Run: models.RunConfig{Transport: "websocket", Port: 80}}))
+	assert.Error(t, validateConfig(&models.MCPConfig{Run: models.RunConfig{Port: 80}, Healthcheck: &models.HealthcheckConfig{Interval: "often"}}))
+	assert.EqualError(t, validateConfig(&models.MCPConfig{Run: models.RunConfig{Port: 80}, Healthcheck: &models.HealthcheckConfig{Interval: "0s"}}), `healthcheck.interval "0s" must be at least 1ms`)
+	assert.Error(t, validateConfig(&models.MCPConfig{Run: models.RunConfig{Port: 80}, Healthcheck: &models.HealthcheckConfig{Timeout: "-5s"}}))
+	assert.NoError(t, validateConfig(&models.MCPConfig{Run: models.RunConfig{Port: 80}, Healthcheck: &models.HealthcheckConfig{StartPeriod: "0s"}}))
+	assert.NoError(t, validateConfig(&config))
+}
+
+func TestDockerfileGenerator_CustomTemplates(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "company.tmpl"), []byte(`{{define "runtime-prelude"}}COPY --from=certs /ca.crt /usr/local/share/ca-certificates/ca.crt
+ENV HTTPS_PROXY={{quote "http://proxy:3128"}}
+
+{{end}}`), 0644))
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, "node"), 0755))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "node", "image.tmpl"), []byte(`{{define "runtime-image"}}registry.example.com/node:{{.Runtime}}{{end}}`), 0644))
+
+	generator := NewDockerfileGeneratorWithTemplates(dir)
+	node := models.MCPConfig{Name: "app", Run: models.RunConfig{Command: "node", Args: []string{"index.js"}}}
+	output := generateDockerfile(t, generator, &node, nil)
+	assert.Contains(t, output, "FROM node:18-alpine AS builder\n")
+	assert.Contains(t, output, "FROM registry.example.com/node:node\n\nCOPY --from=certs /ca.crt /usr/local/share/ca-certificates/ca.crt\nENV HTTPS_PROXY=\"http://proxy:3128\"\n\nWORKDIR /app")
+
+	// Runtime directories only apply to their runtime
+	python := models.MCPConfig{Name: "app", Run: models.RunConfig{Command: "python3", Args: []string{"app.py"}}}
+	output = generateDockerfile(t, generator, &python, nil)
+	assert.Contains(t, output, "FROM python:3.11-slim\n\nCOPY --from=certs")
+
+	// The built-in templates render the same Dockerfile as an empty templates directory
+	builtin := generateDockerfile(t, NewDockerfileGenerator(), &python, nil)
+	assert.Equal(t, builtin, generateDockerfile(t, NewDockerfileGeneratorWithTemplates(t.TempDir()), &python, nil))
+
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "broken.tmpl"), []byte(`{{define "cmd"}}{{.Missing}}{{end}}`), 0644))
+	_, err := generator.Generate(&node)
+	assert.Error(t, err)
+
+	_, err = NewDockerfileGeneratorWithTemplates(filepath.Join(dir, "missing")).Generate(&node)
+	assert.Error(t, err)
+}
+
+func TestResolveTemplatesDir(t *testing.T) {
+	dir := t.TempDir()
+	settings := filepath.Join(dir, "config.json")
+	assert.NoError(t, os.WriteFile(settings, []byte(`{"templates": "company-templates"}`), 0644))
+	t.Setenv(SettingsEnvVar, settings)
+	t.Setenv(TemplatesEnvVar, "")
+
+	resolved, err := ResolveTemplatesDir("")
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "company-templates"), resolved)
+
+	t.Setenv(TemplatesEnvVar, "/env/templates")
+	resolved, _ = ResolveTemplatesDir("")
+	assert.Equal(t, "/env/templates", resolved)
+
+	resolved, _ = ResolveTemplatesDir("/flag/templates")
+	assert.Equal(t, "/flag/templates", resolved)
+}
+
+// dockerfileInstructions returns the instruction keyword of every logical Dockerfile line
+func dockerfileInstructions(dockerfile string) []string {
+	var instructions []string
+	continued := false
+	for _, line := range strings.Split(dockerfile, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		if !continued {
+			instructions = append(instructions, strings.ToUpper(strings.Fields(trimmed)[0]))
+		}
+		continued = endsWithContinuation(line)
+	}
+	return instructions
+}
+
+func filterInstructions(instructions []string, keyword string) []string {
+	var matching []string
+	for _, instruction := range instructions {
+		if instruction == keyword {
+			matching = append(matching, instruction)
+		}
+	}
+	return matching
+}
+
+// FuzzGenerate checks that no mcp.json value can add, remove or reorder Dockerfile
+// instructions: the output must have the same instructions as a config with plain values
+func FuzzGenerate(f *testing.F) {
+	f.Add("app", "1.0.0", "A server", "me", "server.js", "curl", "fc-cache -f", "NPM_TOKEN", "node:20")
+	f.Add("x\nUSER root", "1\\", "multi\nline\r\ndescription", "\"quoted\"", "a\nRUN evil", "pkg\\", "step \\", "A\nRUN evil", "img \\")
+	f.Add("", "", "$HOME `id`", "", "", "", "cat <<EOF", "", "")
+
+	plain := func(value string) string {
+		if value == "" {
+			return ""
+		}
+		return "x"
+	}
+	config := func(name, version, description, author, arg, pkg, step, buildArg, baseImage string) *models.MCPConfig {
+		build := &models.BuildConfig{BaseImage: baseImage}
+		if pkg != "" {
+			build.Packages = []string{pkg}
+		}
+		if step != "" {
+			build.Run = []string{step}
+		}
+		if buildArg != "" {
+			build.Args = map[string]string{buildArg: "value"}
+		}
+		return &models.MCPConfig{
+			Name:        name,
+			Version:     version,
+			Description: description,
+			Author:      author,
+			Run:         models.RunConfig{Command: "node", Args: []string{arg}, Port: 3000},
+			Build:       build,
+		}
+	}
+
+	f.Fuzz(func(t *testing.T, name, version, description, author, arg, pkg, step, buildArg, baseImage string) {
+		generator := NewDockerfileGenerator()
+		output := generateDockerfile(t, generator, config(name, version, description, author, arg, pkg, step, buildArg, baseImage), nil)
+		expected := generateDockerfile(t, generator, config(plain(name), plain(version), plain(description), plain(author), plain(arg), plain(pkg), plain(step), plain(buildArg), plain(baseImage)), nil)
+
+		if got, want := dockerfileInstructions(output), dockerfileInstructions(expected); strings.Join(got, " ") != strings.Join(want, " ") {
+			t.Fatalf("instructions changed:\n got %v\nwant %v\n%s", got, want, output)
+		}
+		for _, line := range strings.Split(output, "\n") {
+			if strings.HasPrefix(line, "LABEL ") && !labelLine.MatchString(line) {
+				t.Fatalf("malformed label: %q", line)
+			}
+		}
+	})
+}
+
+var labelLine = regexp.MustCompile(`^LABEL [a-z.-]+="(\\.|[^"\\])*"$`)
+
+func TestParseLoadedImage(t *testing.T) {
+	assert.Equal(t, "weather:latest", parseLoadedImage("Loaded image: weather:latest\n"))
+	assert.Equal(t, "localhost/weather:latest", parseLoadedImage("Getting image source signatures\nLoaded image(s): localhost/weather:latest\n"))
+	assert.Equal(t, "docker.io/library/weather:latest", parseLoadedImage("unpacking docker.io/library/weather:latest (sha256:abc)...done\n"))
+	assert.Equal(t, "", parseLoadedImage("nothing useful"))
+	assert.Equal(t, "weather:latest", LocalImageName("localhost/weather:latest"))
+}
+
+func TestNewContainerRuntime(t *testing.T) {
+	rt, err := NewContainerRuntime("podman")
+	assert.NoError(t, err)
+	assert.Equal(t, "podman", rt.Binary())
+	assert.Equal(t, "Podman", rt.DisplayName())
+
+	_, err = NewContainerRuntime("lxc")
+	assert.Error(t, err)
+}
+
+func TestSummarizeBuildFailure(t *testing.T) {
+	t.Run("BuildKit", func(t *testing.T) {
+		log := `#5 [2/4] WORKDIR /app
+#5 DONE 0.1s
+#6 [3/4] COPY . .
+#6 DONE 0.1s
+#7 [4/4] RUN npm ci
+#7 0.512 npm ERR! missing package-lock.json
+#7 ERROR: process "/bin/sh -c npm ci" did not complete successfully: exit code: 1
+ERROR: failed to solve: process "/bin/sh -c npm ci" did not complete successfully: exit code: 1
+`
+		step, tail := summarizeBuildFailure(log)
+		assert.Equal(t, "[4/4] RUN npm ci", step)
+		assert.Contains(t, tail, "0.512 npm ERR! missing package-lock.json")
+	})
+
+	t.Run("Podman", func(t *testing.T) {
+		log := `STEP 1/3: FROM python:3.11-slim
+STEP 2/3: COPY . .
+--> 1234
+STEP 3/3: RUN pip install -r requirements.txt
+ERROR: Could not open requirements file
+Error: building at STEP "RUN pip install -r requirements.txt": exit status 1
+`
+		step, tail := summarizeBuildFailure(log)
+		assert.Equal(t, "[3/3] RUN pip install -r requirements.txt", step)
+		assert.Equal(t, "ERROR: Could not open requirements file", tail[0])
+	})
+
+	err := &BuildError{Err: assert.AnError, Step: "[4/4] RUN npm ci", LogPath: "logs/app.log"}
+	assert.Contains(t, err.Error(), "Failing step: [4/4] RUN npm ci")
+	assert.Contains(t, err.Error(), "logs/app.log")
+}
+
+func TestNormalizePlatforms(t *testing.T) {
+	// The host platform is moved last, whichever it is, and duplicates are dropped
+	var others []string
+	for _, platform := range []string{"linux/s390x", "linux/arm/v7", "linux/ppc64le"} {
+		if platform != HostPlatform() && len(others) < 2 {
+			others = append(others, platform)
+		}
+	}
+	platforms, err := NormalizePlatforms([]string{HostPlatform(), others[0], strings.ToUpper(others[0]), others[1], HostPlatform()})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{others[0], others[1], HostPlatform()}, platforms)
+
+	_, err = NormalizePlatforms([]string{"arm64"})
+	assert.Error(t, err)
+
+	assert.Equal(t, []string{"linux/amd64", "linux/arm64"}, ParsePlatforms(" linux/amd64, linux/arm64,"))
+	assert.Equal(t, "author/app@linux-arm64.tar", objectKey("author", "app", "linux/arm64"))
+	assert.Equal(t, "author/app.tar", objectKey("author", "app", ""))
+}
+
+func TestOCIRegistry(t *testing.T) {
+	registry, err := NewRegistry("oci://localhost:5000/", &ContainerRuntime{Name: RuntimeDocker, binary: "docker"}, nil)
+	assert.NoError(t, err)
+	assert.False(t, registry.NeedsArchives())
+
+	oci := registry.(*OCIRegistry)
+	assert.Equal(t, "localhost:5000/alice/weather:1.2.0", oci.Reference("Alice", "Weather", "1.2.0"))
+	assert.Equal(t, "localhost:5000/alice/weather:latest", oci.Reference("alice", "weather", ""))
+
+	assert.Equal(t, "1.2.0-beta_1", ociTag("v1.2.0-beta_1"))
+	assert.Equal(t, "1.0-build-5", ociTag("1.0+build 5"))
+
+	// A push for other platforms than the published ones is not skipped
+	platforms, err := platformsFromManifest([]byte(`{"manifests": [
+		{"platform": {"os": "linux", "architecture": "arm64", "variant": "v8"}},
+		{"platform": {"os": "linux", "architecture": "amd64"}},
+		{"platform": {"os": "unknown", "architecture": "unknown"}}]}`))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"linux/arm64", "linux/amd64"}, platforms)
+	assert.True(t, samePlatforms([]string{"linux/amd64", "linux/arm64"}, platforms))
+	assert.False(t, samePlatforms([]string{"linux/arm64"}, platforms))
+	single, err := platformsFromManifest([]byte(`{"config": {}, "layers": []}`))
+	assert.NoError(t, err)
+	assert.Empty(t, single)
+	assert.True(t, samePlatforms(single, nil))
+
+	// Podman reads remote manifests through skopeo
+	t.Setenv("PATH", t.TempDir())
+	podman := &ContainerRuntime{Name: RuntimePodman, binary: "podman"}
+	_, err = podman.RemotePlatforms("localhost:5000/alice/weather")
+	assert.ErrorContains(t, err, "skopeo is required")
+}
+
+func TestInsecureRegistry(t *testing.T) {
+	t.Setenv(InsecureRegistriesEnvVar, "registry.lan:5000, 10.0.0.5")
+	for ref, insecure := range map[string]bool{
+		"localhost:5000/alice/weather:1.2.0":        true,
+		"127.0.0.1:5000/alice/weather:latest":       true,
+		"[::1]:5000/alice/weather":                  true,
+		"docker://localhost/alice/weather":          true,
+		"registry.lan:5000/alice/weather":           true,
+		"10.0.0.5/alice/weather":                    true,
+		"registry.lan/alice/weather":                false,
+		"ghcr.io/my-org/alice/weather:latest":       false,
+		"localhost.example.com/alice/weather:1.2.0": false,
+	} {
+		assert.Equal(t, insecure, insecureRegistry(ref), ref)
+	}
+
+	docker := &ContainerRuntime{Name: RuntimeDocker, binary: "docker"}
+	podman := &ContainerRuntime{Name: RuntimePodman, binary: "podman"}
+	assert.Nil(t, docker.insecureFlags("push", "localhost:5000/alice/weather"))
+	assert.Equal(t, []string{"--insecure"}, docker.insecureFlags("manifest", "localhost:5000/alice/weather"))
+	assert.Equal(t, []string{"--tls-verify=false"}, podman.insecureFlags("push", "localhost:5000/alice/weather"))
+	assert.Nil(t, podman.insecureFlags("push", "ghcr.io/my-org/alice/weather"))
+}
+
+func TestHashBuildContext(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "index.js"), []byte("console.log(1)"), 0644))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "Dockerfile"), []byte("FROM node:18-alpine\n"), 0644))
+
+	first, err := HashBuildContext(dir)
+	assert.NoError(t, err)
+	again, _ := HashBuildContext(dir)
+	assert.Equal(t, first, again)
+	assert.Contains(t, first, "sha256:")
+
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "Dockerfile"), []byte("FROM node:20-alpine\n"), 0644))
+	changed, _ := HashBuildContext(dir)
+	assert.NotEqual(t, first, changed)
+
+	// Ignored files do not affect the hash
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, DockerignoreFileName), []byte("*.log\n"), 0644))
+	ignored, _ := HashBuildContext(dir)
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "debug.log"), []byte("noise"), 0644))
+	withLog, _ := HashBuildContext(dir)
+	assert.Equal(t, ignored, withLog)
+}
+
+func TestIgnoreMatcher(t *testing.T) {
+	matcher, err := newIgnoreMatcher([]string{"# comment", "**/node_modules", "/dist", "*.md", "!README.md", "docs/**/*.png", "!node_modules/keep"})
+	assert.NoError(t, err)
+
+	assert.True(t, matcher.Excluded("node_modules/lib/index.js"))
+	assert.True(t, matcher.Excluded("packages/a/node_modules/x.js"))
+	assert.False(t, matcher.Excluded("node_modules/keep/x.js"))
+	assert.True(t, matcher.Excluded("dist/main.js"))
+	assert.True(t, matcher.Excluded("CHANGELOG.md"))
+	assert.False(t, matcher.Excluded("README.md"))
+	assert.False(t, matcher.Excluded("src/notes.md"))
+	assert.True(t, matcher.Excluded("docs/img/a/b.png"))
+	assert.False(t, matcher.Excluded("src/index.js"))
+}
+
+func TestGenerateDockerignore(t *testing.T) {
+	config := &models.MCPConfig{Name: "app", Run: models.RunConfig{Command: "npx", Args: []string{"-y", "some-mcp"}}}
+	content := GenerateDockerignore(config, "fixtures\n!.env\n")
+	assert.Contains(t, content, "**/node_modules\n")
+	assert.Contains(t, content, ".git\n")
+	assert.NotContains(t, content, "__pycache__")
+	assert.Less(t, strings.Index(content, ".env\n"), strings.Index(content, "!.env\n"))
+
+	dir := t.TempDir()
+	for _, name := range []string{"mcp.json", ".env", ".env.example", "server.pem", "index.js"} {
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte("x"), 0644))
+	}
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, "node_modules", "dep"), 0755))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "node_modules", "dep", "index.js"), []byte("x"), 0644))
+
+	report, err := InspectBuildContext(dir)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{".env", "server.pem"}, report.Secrets)
+	assert.Len(t, report.Files, 6)
+
+	assert.NoError(t, writeDockerignore(config, dir))
+	report, err = InspectBuildContext(dir)
+	assert.NoError(t, err)
+	assert.Empty(t, report.Secrets)
+	var paths []string
+	for _, file := range report.Files {
+		paths = append(paths, file.Path)
+	}
+	assert.Equal(t, []string{".dockerignore", ".env.example", "index.js", "mcp.json"}, paths)
+	assert.Equal(t, "1.5 KB", formatSize(1536))
+}
+
+func TestMetadataLabels(t *testing.T) {
+	config := &models.MCPConfig{
+		Name:       "weather",
+		Version:    "1.2.0",
+		Author:     "acme",
+		License:    "MIT",
+		Repository: models.Repository{Type: "git", URL: "https://github.com/acme/weather"},
+		Run:        models.RunConfig{Command: "node", Args: []string{"index.js"}, Port: 8080},
+		Env: []models.EnvVar{
+			{Name: "API_KEY", Required: true, Secret: true},
+			{Name: "UNITS", Default: "metric"},
+		},
+	}
+
+	labels := MetadataLabels(config)
+	assert.Equal(t, "weather", labels[LabelTitle])
+	assert.Equal(t, "MIT", labels[LabelLicenses])
+	assert.Equal(t, "https://github.com/acme/weather", labels[LabelSource])
+	assert.Equal(t, "http", labels[LabelTransport])
+	assert.Equal(t, "8080", labels[LabelPort])
+	assert.Contains(t, labels[LabelConfigDigest], "sha256:")
+	_, hasDescription := labels[LabelDescription]
+	assert.False(t, hasDescription)
+
+	metadata := ParseImageMetadata(labels)
+	assert.Equal(t, 8080, metadata.Port)
+	assert.Equal(t, "/mcp", metadata.Path)
+	assert.Equal(t, config.Env, metadata.Env)
+	assert.Equal(t, config.Run, metadata.Config.Run)
+
+	t.Setenv("API_KEY", "")
+	forward, missing := metadata.ResolveEnv(nil)
+	assert.Equal(t, []string{"API_KEY"}, forward)
+	assert.Empty(t, missing)
+	os.Unsetenv("API_KEY")
+	_, missing = metadata.ResolveEnv([]string{"UNITS=imperial"})
+	assert.Equal(t, []string{"API_KEY"}, missing)
+	_, missing = metadata.ResolveEnv([]string{"API_KEY=secret"})
+	assert.Empty(t, missing)
+
+	output := generateDockerfile(t, NewDockerfileGenerator(), config, nil)
+	assert.Contains(t, output, `LABEL io.mcphub.port="8080"`)
+	assert.Contains(t, output, `ENV UNITS="metric"`)
+	assert.NotContains(t, output, "ENV API_KEY")
+	assert.Error(t, validateConfig(&models.MCPConfig{Env: []models.EnvVar{{Name: "BAD-NAME"}}}))
+
+	config.Build = &models.BuildConfig{Args: map[string]string{"NPM_TOKEN": "npm-token-value"}}
+	config.Env = append(config.Env, models.EnvVar{Name: "DB_PASSWORD", Secret: true, Default: "hunter2-default"})
+	output = generateDockerfile(t, NewDockerfileGenerator(), config, nil)
+	assert.Contains(t, output, "ARG NPM_TOKEN")
+	assert.NotContains(t, output, "npm-token-value")
+	assert.NotContains(t, output, "hunter2-default")
+	metadata = ParseImageMetadata(MetadataLabels(config))
+	assert.Equal(t, map[string]string{"NPM_TOKEN": ""}, metadata.Config.Build.Args)
+	assert.Equal(t, "DB_PASSWORD", metadata.Env[2].Name)
+	assert.Empty(t, metadata.Env[2].Default)
+	assert.Equal(t, "npm-token-value", config.Build.Args["NPM_TOKEN"])
+	assert.Equal(t, "hunter2-default", config.Env[2].Default)
+
+	dir := t.TempDir()
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, ".git", "refs", "heads"), 0755))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, ".git", "HEAD"), []byte("ref: refs/heads/main\n"), 0644))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, ".git", "refs", "heads", "main"), []byte("0123abc\n"), 0644))
+	assert.Equal(t, "0123abc", BuildLabels(dir)[LabelRevision])
+	assert.NotEmpty(t, BuildLabels(t.TempDir())[LabelCreated])
+}
+
+func TestServerMetadata(t *testing.T) {
+	// Images without MCPHub labels are refused before their transport is looked at
+	_, err := serverMetadata("nginx", map[string]string{})
+	assert.EqualError(t, err, "nginx was not built by MCPHub; its transport is unknown")
+
+	metadata, err := serverMetadata("weather", map[string]string{
+		LabelTransport: "stdio",
+		LabelEnv:       `[{"name":"API_KEY","required":true},{"name":"UNITS"}]`,
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+	t.Setenv("API_KEY", "")
+	os.Unsetenv("API_KEY")
+	_, err = forwardEnv(metadata, nil)
+	assert.EqualError(t, err, "missing required environment variables: API_KEY (pass them with -e NAME=value)")
+	os.Setenv("API_KEY", "secret")
+	env, err := forwardEnv(metadata, []string{"UNITS=metric"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"UNITS=metric", "API_KEY"}, env)
+}
+
+func TestSearchServer(t *testing.T) {
+	introspection := &mcp.Introspection{
+		Tools: []mcp.Tool{
+			{Name: "create_issue", Description: "Create a GitHub issue"},
+			{Name: "list_repos", Description: "List repositories"},
+		},
+		Prompts: []mcp.Prompt{{Name: "triage", Description: "Triage open issues"}},
+	}
+
+	match := SearchServer("github", "1.0.0", "GitHub integration", introspection, "Issue")
+	if assert.NotNil(t, match) {
+		assert.Equal(t, []string{"create_issue"}, match.Tools)
+		assert.Equal(t, []string{"triage"}, match.Prompts)
+	}
+
+	// Every word must appear in the server itself or in one tool
+	assert.Nil(t, SearchServer("github", "1.0.0", "GitHub integration", introspection, "create repositories"))
+	assert.NotNil(t, SearchServer("github", "1.0.0", "GitHub integration", nil, "github"))
+	assert.Nil(t, SearchServer("github", "1.0.0", "", nil, "slack"))
+
+	content, _ := json.Marshal(introspection)
+	metadata := ParseImageMetadata(map[string]string{LabelIntrospection: string(content)})
+	if assert.NotNil(t, metadata.Introspection) {
+		assert.Len(t, metadata.Introspection.Tools, 2)
+	}
+}
+
+func TestLabelFromImageConfig(t *testing.T) {
+	single := []byte(`{"config":{"Labels":{"io.mcphub.source-hash":"sha256:abc"}}}`)
+	assert.Equal(t, "sha256:abc", labelFromImageConfig(single, LabelSourceHash))
+
+	index := []byte(`{"linux/amd64":{"config":{"Labels":{"io.mcphub.source-hash":"sha256:def"}}}}`)
+	assert.Equal(t, "sha256:def", labelFromImageConfig(index, LabelSourceHash))
+
+	assert.Equal(t, "", labelFromImageConfig([]byte(`{}`), LabelSourceHash))
+}
+
+func TestDockerfileGenerator_BuildOverrides(t *testing.T) {
+	config := models.MCPConfig{
+		Name: "pdf-tools",
+		Run:  models.RunConfig{Command: "python3", Args: []string{"server.py"}},
+		Build: &models.BuildConfig{
+			BaseImage: "python:3.12-slim-bookworm",
+			Packages:  []string{"poppler-utils"},
+			Run:       []string{"fc-cache -f"},
+			Args:      map[string]string{"PIP_INDEX_URL": "https://pypi.internal/simple"},
+		},
+	}
+
+	output := generateDockerfile(t, NewDockerfileGenerator(), &config, nil)
+
+	assert.Contains(t, output, "FROM python:3.12-slim-bookworm AS builder")
+	assert.NotContains(t, output, "python:3.11-slim")
+	assert.Contains(t, output, "apt-get install -y --no-install-recommends poppler-utils")
+	assert.Contains(t, output, "ARG PIP_INDEX_URL")
+	assert.Contains(t, output, "RUN fc-cache -f\n")
+	assert.Less(t, strings.Index(output, "RUN fc-cache -f"), strings.Index(output, "USER mcp"))
+
+	assert.NoError(t, validateConfig(&config))
+	config.Build.Run = []string{"fc-cache -f", " \t"}
+	assert.EqualError(t, validateConfig(&config), "build.run step 2 is empty")
+	config.Build.Run = nil
+	config.Build.Packages = []string{""}
+	assert.EqualError(t, validateConfig(&config), "build.packages entry 1 is empty")
+}
+
+func TestZipProcessor_ResolveDockerfile(t *testing.T) {
+	processor := NewZipProcessor(nil, ProcessOptions{})
+	config := &models.MCPConfig{Name: "app", Run: models.RunConfig{Command: "node"}}
+
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "Dockerfile"), []byte("FROM scratch\n"), 0644))
+	path, generated, err := processor.resolveDockerfile(config, dir)
+	assert.NoError(t, err)
+	assert.False(t, generated)
+	content, _ := os.ReadFile(path)
+	assert.Equal(t, "FROM scratch\n", string(content))
+
+	config.Build = &models.BuildConfig{Dockerfile: "../Dockerfile"}
+	_, _, err = processor.resolveDockerfile(config, dir)
+	assert.Error(t, err)
+
+	// Overrides of the generated Dockerfile would be ignored, so they are rejected
+	config.Build = &models.BuildConfig{Packages: []string{"git"}, Run: []string{"make"}, Args: map[string]string{"A": "1"}}
+	_, _, err = processor.resolveDockerfile(config, dir)
+	assert.EqualError(t, err, "build.packages, build.run only apply to generated Dockerfiles, but the project's Dockerfile is used; do their work in that Dockerfile instead")
+	config.Build = &models.BuildConfig{Dockerfile: "Dockerfile", BaseImage: "alpine"}
+	_, _, err = processor.resolveDockerfile(config, dir)
+	assert.Error(t, err)
+	config.Build = &models.BuildConfig{Args: map[string]string{"A": "1"}}
+	_, _, err = processor.resolveDockerfile(config, dir)
+	assert.NoError(t, err)
+
+	config.Build = nil
+	empty := t.TempDir()
+	_, generated, err = processor.resolveDockerfile(config, empty)
+	assert.NoError(t, err)
+	assert.True(t, generated)
+
+	rt := &ContainerRuntime{Name: RuntimeDocker, binary: "docker"}
+	args := rt.buildArgs("app", "", BuildOptions{Dockerfile: "docker/Dockerfile.prod", BuildArgs: map[string]string{"A": "1"}})
+	assert.Equal(t, []string{"build", "-f", "docker/Dockerfile.prod", "--build-arg", "A=1", "-t", "app", "."}, args)
+}
+
+// fakeRuntime returns a docker runtime whose docker binary runs script, with the command
+// line in "$@", and the file every command line is appended to
+func fakeRuntime(t *testing.T, script string) (*ContainerRuntime, string) {
+	t.Helper()
+	dir := t.TempDir()
+	calls := filepath.Join(dir, "calls.log")
+	content := "#!/bin/sh\necho \"$@\" >> " + calls + "\n" + script
+	if err := os.WriteFile(filepath.Join(dir, "docker"), []byte(content), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	return &ContainerRuntime{Name: RuntimeDocker, binary: "docker"}, calls
+}
+
+func TestZipProcessor_HostBuildCache(t *testing.T) {
+	// An earlier multi-platform build left an image of another platform with the same
+	// sources under the plain name
+	rt, calls := fakeRuntime(t, `[ "$1 $2" = "image inspect" ] && [ "$5" = app ] && echo abc123
+exit 0
+`)
+	dir := t.TempDir()
+	prepared := &PreparedBuild{
+		Config:         &models.MCPConfig{Name: "app", Run: models.RunConfig{Command: "node"}},
+		BaseName:       "app",
+		ContextDir:     dir,
+		DockerfilePath: filepath.Join(dir, "Dockerfile"),
+		ImageName:      "app",
+		SourceHash:     "abc123",
+	}
+	processor := NewZipProcessor(rt, ProcessOptions{Build: BuildOptions{LogDir: t.TempDir()}, SkipArchive: true, SkipIntrospection: true})
+
+	artifacts, _, err := processor.buildAndSave(prepared)
+	assert.NoError(t, err)
+	host := "app:" + PlatformTag(HostPlatform())
+	assert.Equal(t, host, artifacts[0].Image)
+
+	log, _ := os.ReadFile(calls)
+	assert.Contains(t, string(log), "build ")
+	assert.Contains(t, string(log), "tag app "+host+"\n")
+	assert.Contains(t, string(log), "tag "+host+" app\n")
+}
+
+func TestZipProcessor_IntrospectionLabel(t *testing.T) {
+	// The host image is up to date and introspected; the riscv64 one was built from the
+	// same sources before the introspection was recorded
+	introspection, _ := json.Marshal(&mcp.Introspection{ProtocolVersion: "2025-06-18", ServerInfo: mcp.Implementation{Name: "app", Version: "1.0.0"}})
+	host := "app:" + PlatformTag(HostPlatform())
+	rt, calls := fakeRuntime(t, `if [ "$1 $2" = "image inspect" ]; then
+	case "$4" in
+	*source-hash*) echo abc123 ;;
+	*introspection*) [ "$5" = "`+host+`" ] && echo '`+string(introspection)+`' ;;
+	esac
+fi
+exit 0
+`)
+	dir := t.TempDir()
+	prepared := &PreparedBuild{
+		Config:         &models.MCPConfig{Name: "app", Run: models.RunConfig{Command: "node"}},
+		BaseName:       "app",
+		ContextDir:     dir,
+		DockerfilePath: filepath.Join(dir, "Dockerfile"),
+		ImageName:      "app",
+		SourceHash:     "abc123",
+	}
+	processor := NewZipProcessor(rt, ProcessOptions{
+		Build:       BuildOptions{LogDir: t.TempDir()},
+		Platforms:   []string{HostPlatform(), "linux/riscv64"},
+		SkipArchive: true,
+	})
+
+	_, recorded, err := processor.buildAndSave(prepared)
+	assert.NoError(t, err)
+	assert.Equal(t, "app", recorded.ServerInfo.Name)
+
+	// Only the outdated image is built, with the introspection passed as a label
+	log, _ := os.ReadFile(calls)
+	assert.Contains(t, string(log), "buildx build --platform linux/riscv64 --load")
+	assert.Contains(t, string(log), "--label "+LabelIntrospection+"="+string(introspection)+" ")
+	assert.Equal(t, 1, strings.Count(string(log), "build --platform"))
+	assert.NotContains(t, string(log), "\nbuild ")
+}
+
+func TestDetectProject(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "package.json"), []byte(`{"engines":{"node":">=20.1.0"}}`), 0644))
+
+	nodeConfig := &models.MCPConfig{Run: models.RunConfig{Command: "node"}}
+	project, err := DetectProject(dir, nodeConfig)
+	assert.NoError(t, err)
+	assert.Equal(t, ">=20.1.0", project.RuntimeVersion)
+	assert.Equal(t, "package.json engines", project.VersionSource)
+
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, ".nvmrc"), []byte("v22.3.0\n"), 0644))
+	project, _ = DetectProject(dir, nodeConfig)
+	assert.Equal(t, ".nvmrc", project.VersionSource)
+
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module x\n\ngo 1.22.3\n"), 0644))
+	project, _ = DetectProject(dir, &models.MCPConfig{Run: models.RunConfig{Command: "go"}})
+	assert.Equal(t, "1.22.3", project.RuntimeVersion)
+
+	assert.Equal(t, "22", lookupLanguageRuntime("node").imageVersion("v22.3.0"))
+	assert.Equal(t, "3.12", lookupLanguageRuntime("python").imageVersion("3.12.1"))
+	assert.Equal(t, "", lookupLanguageRuntime("node").imageVersion("lts/*"))
+	assert.Equal(t, "1.83.0", lookupLanguageRuntime("cargo").imageVersion("1.83.0"))
+}
+
+func TestDockerfileGenerator_RuntimeVersionAndLock(t *testing.T) {
+	generator := NewDockerfileGenerator()
+	config := models.MCPConfig{Name: "app", Run: models.RunConfig{Command: "node", Args: []string{"index.js"}}}
+
+	project := &ProjectInfo{
+		RuntimeVersion: "v22.3.0",
+		Lock:           &models.LockFile{Images: map[string]string{"node:22-alpine": "sha256:abc"}},
+	}
+	output := generateDockerfile(t, generator, &config, project)
+	assert.Contains(t, output, "FROM node:22-alpine@sha256:abc AS builder")
+
+	// mcp.json wins over version files
+	config.Runtime = &models.RuntimeConfig{Version: "20"}
+	output = generateDockerfile(t, generator, &config, project)
+	assert.Contains(t, output, "FROM node:20-alpine AS builder")
+
+	goConfig := models.MCPConfig{Name: "app", Run: models.RunConfig{Command: "go"}}
+	builder, runtime := generator.BaseImages(&goConfig, &ProjectInfo{RuntimeVersion: "1.22.3"})
+	assert.Equal(t, "golang:1.22-alpine", builder)
+	assert.Equal(t, "alpine:3.19", runtime)
+}
+
+func TestDetectInstallPlan(t *testing.T) {
+	write := func(dir string, names ...string) {
+		for _, name := range names {
+			assert.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte("{}"), 0644))
+		}
+	}
+
+	t.Run("npm ci from package-lock", func(t *testing.T) {
+		dir := t.TempDir()
+		write(dir, "package.json", "package-lock.json")
+		plan, err := DetectInstallPlan(dir, "node", "")
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"package.json", "package-lock.json"}, plan.Files)
+		assert.Equal(t, []string{"npm ci --omit=dev"}, plan.Commands)
+	})
+
+	t.Run("yarn berry", func(t *testing.T) {
+		dir := t.TempDir()
+		write(dir, "package.json", ".yarnrc.yml")
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, "yarn.lock"), []byte("__metadata:\n  version: 8\n"), 0644))
+		plan, err := DetectInstallPlan(dir, "node", "")
+		assert.NoError(t, err)
+		assert.Equal(t, "yarn-berry", plan.Manager)
+		assert.Contains(t, plan.Commands, "yarn workspaces focus --all --production")
+	})
+
+	t.Run("ambiguous lockfiles", func(t *testing.T) {
+		dir := t.TempDir()
+		write(dir, "package.json", "package-lock.json", "pnpm-lock.yaml")
+		_, err := DetectInstallPlan(dir, "node", "")
+		assert.ErrorContains(t, err, "package-lock.json, pnpm-lock.yaml")
+
+		plan, err := DetectInstallPlan(dir, "node", "pnpm")
+		assert.NoError(t, err)
+		assert.Equal(t, "pnpm", plan.Manager)
+	})
+
+	t.Run("python lockfiles win over requirements", func(t *testing.T) {
+		dir := t.TempDir()
+		write(dir, "pyproject.toml", "uv.lock", "requirements.txt")
+		plan, err := DetectInstallPlan(dir, "python", "")
+		assert.NoError(t, err)
+		assert.Equal(t, "uv", plan.Manager)
+
+		// The dependencies are cached apart from the sources; the project's scripts are
+		// installed once they are copied
+		config := &models.MCPConfig{Name: "app", Run: models.RunConfig{Command: "weather-server"}, Runtime: &models.RuntimeConfig{Name: "python"}}
+		project, err := DetectProject(dir, config)
+		assert.NoError(t, err)
+		output := generateDockerfile(t, NewDockerfileGenerator(), config, project)
+		assert.Contains(t, output, "uv sync --frozen --no-dev --no-install-project\n\nCOPY . .\n\nRUN UV_PROJECT_ENVIRONMENT=/opt/venv uv sync --frozen --no-dev --no-editable\n")
+	})
+
+	t.Run("poetry installs the project", func(t *testing.T) {
+		dir := t.TempDir()
+		write(dir, "pyproject.toml", "poetry.lock")
+		plan, err := DetectInstallPlan(dir, "python", "")
+		assert.NoError(t, err)
+		assert.Contains(t, plan.Commands, "poetry install --only main --no-root --no-interaction")
+		assert.Equal(t, []string{"poetry install --only main --no-interaction"}, plan.ProjectCommands)
+	})
+
+	t.Run("single cached install step", func(t *testing.T) {
+		dir := t.TempDir()
+		write(dir, "requirements.txt")
+		config := &models.MCPConfig{Name: "app", Run: models.RunConfig{Command: "python3", Args: []string{"app.py"}}}
+		project, err := DetectProject(dir, config)
+		assert.NoError(t, err)
+
+		output := generateDockerfile(t, NewDockerfileGenerator(), config, project)
+		assert.Contains(t, output, "COPY requirements.txt ./\nRUN pip install --no-cache-dir -r requirements.txt\n\nCOPY . .")
+		assert.NotContains(t, output, "pipenv")
+		assert.NotContains(t, output, "uv")
+	})
+}
+
+func TestClientConfig(t *testing.T) {
+	stdio := &ImageMetadata{
+		Transport: models.TransportStdio,
+		Env: []models.EnvVar{
+			{Name: "GITHUB_TOKEN", Description: "Personal access token", Required: true, Secret: true},
+			{Name: "LOG_LEVEL", Default: "info"},
+			{Name: "WEBHOOK_SECRET", Secret: true},
+		},
+		Config: &models.MCPConfig{Name: "github"},
+	}
+	http := &ImageMetadata{Transport: models.TransportHTTP, Port: 8000, Path: "/mcp"}
+	assert.Equal(t, "github", ClientServerName("registry.example.com/acme/github-mcp:1.0", stdio))
+	assert.Equal(t, "jira-mcp", ClientServerName("registry.example.com:5000/acme/jira-mcp:1.0", http))
+
+	_, err := NewClientConfig("emacs")
+	assert.Error(t, err)
+
+	config, _ := NewClientConfig(ClientClaudeDesktop)
+	assert.NoError(t, config.Add("github", "docker", "github-mcp:1.0", stdio, ""))
+	assert.NoError(t, config.Add("jira", "docker", "jira-mcp:1.0", http, ""))
+	assert.Equal(t, models.ClientServerConfig{
+		Command: "docker",
+		Args:    []string{"run", "-i", "--rm", "-e", "GITHUB_TOKEN", "-e", "WEBHOOK_SECRET", "github-mcp:1.0"},
+		Env:     map[string]string{"GITHUB_TOKEN": "<GITHUB_TOKEN>", "WEBHOOK_SECRET": "<WEBHOOK_SECRET>"},
+	}, config.Servers["github"])
+	assert.Equal(t, []string{"-y", "mcp-remote", "http://localhost:8000/mcp"}, config.Servers["jira"].Args)
+	assert.Equal(t, []string{"GITHUB_TOKEN", "WEBHOOK_SECRET"}, config.Placeholders["github"])
+	assert.Error(t, config.Add("plain", "docker", "nginx", &ImageMetadata{}, ""))
+
+	// A running container is reached where it publishes the port
+	cursor, _ := NewClientConfig(ClientCursor)
+	assert.NoError(t, cursor.Add("jira", "docker", "jira-mcp:1.0", http, "127.0.0.1:18000"))
+	assert.Equal(t, "http://127.0.0.1:18000/mcp", cursor.Servers["jira"].URL)
+
+	vscode, _ := NewClientConfig(ClientVSCode)
+	vscode.Add("github", "podman", "github-mcp:1.0", stdio, "")
+	vscode.Add("jira", "podman", "jira-mcp:1.0", http, "")
+	assert.Equal(t, "stdio", vscode.Servers["github"].Type)
+	assert.Equal(t, "${input:GITHUB_TOKEN}", vscode.Servers["github"].Env["GITHUB_TOKEN"])
+	assert.Equal(t, []models.ClientInput{
+		{Type: "promptString", ID: "GITHUB_TOKEN", Description: "Personal access token", Password: true},
+		{Type: "promptString", ID: "WEBHOOK_SECRET", Password: true},
+	}, vscode.Inputs)
+	assert.Equal(t, models.ClientServerConfig{Type: "http", URL: "http://localhost:8000/mcp"}, vscode.Servers["jira"])
+
+	// Merging keeps other settings and servers, replaces same-named ones and backs up the file
+	path := filepath.Join(t.TempDir(), ".vscode", "mcp.json")
+	backup, _, err := vscode.Merge(path)
+	assert.NoError(t, err)
+	assert.Empty(t, backup)
+
+	os.WriteFile(path, []byte(`{"servers": {"other": {"url": "http://example.com"}, "jira": {}}, "inputs": [{"type": "promptString", "id": "GITHUB_TOKEN"}], "theme": "dark"}`), 0600)
+	os.Chmod(path, 0600)
+	backup, replaced, err := vscode.Merge(path)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"jira"}, replaced)
+	original, _ := os.ReadFile(backup)
+	assert.Contains(t, string(original), `"theme": "dark"`)
+
+	var merged struct {
+		Servers map[string]models.ClientServerConfig `json:"servers"`
+		Inputs  []models.ClientInput                 `json:"inputs"`
+		Theme   string                               `json:"theme"`
+	}
+	content, _ := os.ReadFile(path)
+	assert.NoError(t, json.Unmarshal(content, &merged))
+	assert.Len(t, merged.Servers, 3)
+	assert.Equal(t, "http://localhost:8000/mcp", merged.Servers["jira"].URL)
+	assert.Len(t, merged.Inputs, 2)
+	assert.Equal(t, "dark", merged.Theme)
+	info, _ := os.Stat(path)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+}
+
+func TestZipDirectory(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "src"), 0755)
+	os.MkdirAll(filepath.Join(dir, ".git"), 0755)
+	os.WriteFile(filepath.Join(dir, "mcp.json"), []byte(`{"name": "demo"}`), 0644)
+	os.WriteFile(filepath.Join(dir, "src", "server.py"), []byte("print()"), 0644)
+	os.WriteFile(filepath.Join(dir, ".git", "HEAD"), []byte("ref: main"), 0644)
+
+	data, err := ZipDirectory(dir)
+	if !assert.NoError(t, err) {
+		return
+	}
+	reader, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if !assert.NoError(t, err) {
+		return
+	}
+	var names []string
+	for _, file := range reader.File {
+		names = append(names, file.Name)
+	}
+	assert.ElementsMatch(t, []string{"mcp.json", "src/server.py"}, names)
+}
+
+func TestReadToolTests(t *testing.T) {
+	dir := t.TempDir()
+	assert.Equal(t, "", FindToolTests(dir))
+
+	path := filepath.Join(dir, "mcp.tests.yaml")
+	os.WriteFile(path, []byte(`tests:
+  - tool: echo
+    arguments: {text: hi, count: 2}
+    expect:
+      contains: [hi]
+      json:
+        $.count: 2
+  - name: rejects empty text
+    tool: echo
+    expect: {isError: true}
+`), 0644)
+	assert.Equal(t, path, FindToolTests(dir))
+	file, err := ReadToolTests(path)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Len(t, file.Tests, 2)
+	assert.Equal(t, "echo", file.Tests[0].Name)
+	assert.JSONEq(t, `{"text": "hi", "count": 2}`, string(file.Tests[0].Arguments))
+	assert.JSONEq(t, `2`, string(file.Tests[0].Expect.JSON["$.count"]))
+	assert.True(t, file.Tests[1].Expect.IsError)
+
+	os.WriteFile(path, []byte(`{"tests": [{"tool": "echo", "expect": {"contain": ["hi"]}}]}`), 0644)
+	_, err = ReadToolTests(path)
+	assert.ErrorContains(t, err, `unknown field "contain"`)
+
+	os.WriteFile(path, []byte(`{"tests": [{"tool": "echo", "expect": {"regex": ["(unclosed"]}}]}`), 0644)
+	_, err = ReadToolTests(path)
+	assert.ErrorContains(t, err, "invalid regex")
+}
+
+func TestMatchToolResult(t *testing.T) {
+	result := &mcp.CallToolResult{
+		Content:           []mcp.Content{{Type: "text", Text: "found 2 issues"}, {Type: "text", Text: "done"}},
+		StructuredContent: json.RawMessage(`{"issues": [{"id": 7, "title": "Broken link"}, {"id": 9}], "total": 2}`),
+	}
+
+	assert.Empty(t, MatchToolResult(models.ToolExpectation{
+		Exact:    json.RawMessage(`"found 2 issues\ndone"`),
+		Contains: []string{"2 issues"},
+		Regex:    []string{`^found \d+`},
+		JSON: map[string]json.RawMessage{
+			"$.issues[0].title": json.RawMessage(`"Broken link"`),
+			"total":             json.RawMessage(`2.0`),
+			"$.issues[1]":       json.RawMessage(`{"id": 9}`),
+		},
+	}, result))
+	assert.Empty(t, MatchToolResult(models.ToolExpectation{
+		Exact: json.RawMessage(`{"total": 2, "issues": [{"title": "Broken link", "id": 7}, {"id": 9}]}`),
+	}, result))
+
+	assert.Equal(t, []string{
+		"the text is not the expected text:\n- found 3 issues\n+ found 2 issues\n  done",
+		`the text does not contain "closed"`,
+		"the text does not match /^done/",
+		`$.issues[0].id is 7, expected 8`,
+		`$.issues[2]: $.issues has 2 items, no index 2`,
+		`$.total.count: $.total is not an object`,
+	}, MatchToolResult(models.ToolExpectation{
+		Exact:    json.RawMessage(`"found 3 issues\ndone"`),
+		Contains: []string{"closed"},
+		Regex:    []string{"^done"},
+		JSON: map[string]json.RawMessage{
+			"$.issues[0].id": json.RawMessage(`8`),
+			"$.issues[2]":    json.RawMessage(`{}`),
+			"$.total.count":  json.RawMessage(`1`),
+		},
+	}, result))
+
+	assert.Equal(t, []string{"the tool reported an error"},
+		MatchToolResult(models.ToolExpectation{}, &mcp.CallToolResult{IsError: true}))
+	assert.Empty(t, MatchToolResult(models.ToolExpectation{IsError: true, Contains: []string{"denied"}},
+		&mcp.CallToolResult{IsError: true, Content: []mcp.Content{{Type: "text", Text: "access denied"}}}))
+}
+
+func TestWriteJUnit(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "report.xml")
+	err := WriteJUnit(path, []TestSuite{
+		{Name: "conformance", Cases: []TestCase{{Name: "ping"}, {Name: "tool schemas", Skipped: "the server declares no tools"}}},
+		{Name: "tools", Cases: []TestCase{{Name: "echo", Failure: "the text does not contain \"hi\"\ntext:\nbye", Duration: 1500 * time.Millisecond}}},
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+	content, _ := os.ReadFile(path)
+	report := string(content)
+	assert.Contains(t, report, `<testsuites tests="3" failures="1" skipped="1" time="1.500">`)
+	assert.Contains(t, report, `<testcase name="tool schemas" classname="conformance" time="0.000">`)
+	assert.Contains(t, report, `<skipped message="the server declares no tools"></skipped>`)
+	assert.Contains(t, report, `<failure message="the text does not contain &#34;hi&#34;">the text does not contain &#34;hi&#34;`)
+}
+
+func TestCompareResponses(t *testing.T) {
+	response := func(result string) *mcp.Message {
+		return &mcp.Message{JSONRPC: "2.0", ID: json.RawMessage(`1`), Result: json.RawMessage(result)}
+	}
+	recorded := response(`{"content": [{"type": "text", "text": "created 2026-10-19T09:00:00Z"}, {"type": "text", "text": "3f2b8c1e-7a4d-4e5f-9b6a-1c2d3e4f5a6b"}], "serverInfo": {"version": "1.0.0"}, "took": 12}`)
+	replayed := response(`{"took": 15, "serverInfo": {"version": "1.1.0"}, "content": [{"type": "text", "text": "created 2026-10-19T09:00:00Z"}, {"type": "text", "text": "0c9d8e7f-6a5b-4c3d-8e2f-1a0b9c8d7e6f"}]}`)
+
+	// Timestamps inside longer text are compared; whole-value UUIDs are not
+	assert.Empty(t, CompareResponses(recorded, replayed, ReplayOptions{Ignore: []string{"$.result.serverInfo.version", "took"}}))
+	assert.Equal(t, strings.Join([]string{
+		"  {",
+		"    \"result\": {",
+		"      \"content\": [",
+		"        {",
+		"          \"text\": \"<ignored>\",",
+		"          \"type\": \"text\"",
+		"        },",
+		"        {",
+		"          \"text\": \"<ignored>\",",
+		"          \"type\": \"text\"",
+		"        }",
+		"      ],",
+		"      \"serverInfo\": {",
+		"-       \"version\": \"1.0.0\"",
+		"+       \"version\": \"1.1.0\"",
+		"      },",
+		"      \"took\": \"<ignored>\"",
+		"    }",
+		"  }",
+	}, "\n"), CompareResponses(recorded, replayed, ReplayOptions{Ignore: []string{"$.result.content[*].text", "took"}, Strict: true}))
+
+	assert.NotEmpty(t, CompareResponses(recorded, replayed, ReplayOptions{Ignore: []string{"took"}, Strict: true}))
+	failed := &mcp.Message{JSONRPC: "2.0", ID: json.RawMessage(`1`), Error: &mcp.Error{Code: mcp.CodeInvalidParams, Message: "bad"}}
+	assert.Contains(t, CompareResponses(recorded, failed, ReplayOptions{}), "+   \"error\": {")
+	assert.Empty(t, CompareResponses(failed, failed, ReplayOptions{}))
+}
+
+func TestBackgroundBridges(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	bridges, err := BackgroundBridges()
+	assert.NoError(t, err)
+	assert.Empty(t, bridges)
+
+	sleep := exec.Command("sleep", "60")
+	if err := sleep.Start(); err != nil {
+		t.Skip("sleep is not available")
+	}
+	exited := make(chan struct{})
+	go func() {
+		sleep.Wait()
+		close(exited)
+	}()
+	running := &BackgroundBridge{Name: "github", Image: "github", PID: sleep.Process.Pid, URL: "http://localhost:8080"}
+	assert.NoError(t, SaveBackgroundBridge(running))
+	// A record whose process is gone is dropped
+	assert.NoError(t, SaveBackgroundBridge(&BackgroundBridge{Name: "gone", PID: 1 << 30}))
+
+	bridges, err = BackgroundBridges()
+	assert.NoError(t, err)
+	assert.Equal(t, []*BackgroundBridge{running}, bridges)
+	bridge, err := FindBackgroundBridge("gone")
+	assert.NoError(t, err)
+	assert.Nil(t, bridge)
+
+	// Another process cannot remove the record
+	RemoveBackgroundBridge("github", os.Getpid())
+	bridge, err = FindBackgroundBridge("github")
+	assert.NoError(t, err)
+	assert.Equal(t, running, bridge)
+
+	assert.NoError(t, bridge.Stop())
+	select {
+	case <-exited:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the bridge process was not stopped")
+	}
+	bridges, err = BackgroundBridges()
+	assert.NoError(t, err)
+	assert.Empty(t, bridges)
+}
+
+func TestReplaySessionStartsFreshContainer(t *testing.T) {
+	// `mcphub run app` left a running container named after the image
+	rt, calls := fakeRuntime(t, `case "$1" in
+container) echo '{"Labels":{"`+LabelManaged+`":"true","`+LabelTransport+`":"stdio"},"Cmd":["node"]}' ;;
+image) echo '{"`+LabelTransport+`":"stdio"}' ;;
+inspect) echo true ;;
+esac
+exit 0
+`)
+
+	_, err := ReplaySession(context.Background(), rt, "app", nil, nil, time.Second, &bytes.Buffer{})
+	assert.NoError(t, err)
+
+	log, _ := os.ReadFile(calls)
+	assert.Regexp(t, `(?m)^run --name mcphub-session-\w+ --rm -i app$`, string(log))
+	assert.NotContains(t, string(log), "exec")
 }
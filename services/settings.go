@@ -0,0 +1,73 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// SettingsEnvVar points at the settings file instead of ~/.mcphub/config.json
+const SettingsEnvVar = "MCPHUB_CONFIG"
+
+// TemplatesEnvVar selects the custom Dockerfile templates directory when no flag is given
+const TemplatesEnvVar = "MCPHUB_TEMPLATES"
+
+// Settings are the machine-wide MCPHub settings, shared by every server built on it
+type Settings struct {
+	// Templates is the directory of custom Dockerfile templates; relative paths are
+	// resolved against the settings file's directory
+	Templates string `json:"templates,omitempty"`
+}
+
+// SettingsPath returns the settings file: $MCPHUB_CONFIG or ~/.mcphub/config.json
+func SettingsPath() string {
+	if path := os.Getenv(SettingsEnvVar); path != "" {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".mcphub", "config.json")
+}
+
+// LoadSettings reads the settings file; a missing file yields empty settings
+func LoadSettings() (*Settings, error) {
+	settings := &Settings{}
+	path := SettingsPath()
+	if path == "" {
+		return settings, nil
+	}
+
+	content, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return settings, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read settings: %w", err)
+	}
+	if err := json.Unmarshal(content, settings); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	if settings.Templates != "" && !filepath.IsAbs(settings.Templates) {
+		settings.Templates = filepath.Join(filepath.Dir(path), settings.Templates)
+	}
+	return settings, nil
+}
+
+// ResolveTemplatesDir returns the custom templates directory: dir when set, then
+// MCPHUB_TEMPLATES, then the settings file. An empty result means the built-in templates.
+func ResolveTemplatesDir(dir string) (string, error) {
+	if dir != "" {
+		return dir, nil
+	}
+	if dir := os.Getenv(TemplatesEnvVar); dir != "" {
+		return dir, nil
+	}
+	settings, err := LoadSettings()
+	if err != nil {
+		return "", err
+	}
+	return settings.Templates, nil
+}
@@ -0,0 +1,65 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// HashBuildContext fingerprints every file of the build context in dir (paths, executable bits
+// and contents, after .dockerignore) so two builds of identical sources and Dockerfile get the
+// same hash regardless of file timestamps or ignored files
+func HashBuildContext(dir string) (string, error) {
+	contextFiles, err := buildContextFiles(dir)
+	if err != nil {
+		return "", err
+	}
+	var files []string
+	for _, file := range contextFiles {
+		files = append(files, filepath.Join(dir, filepath.FromSlash(file.Path)))
+	}
+
+	hash := sha256.New()
+	for _, path := range files {
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return "", err
+		}
+		info, err := os.Lstat(path)
+		if err != nil {
+			return "", err
+		}
+
+		fmt.Fprintf(hash, "%s\x00%t\x00", filepath.ToSlash(rel), info.Mode()&0111 != 0)
+
+		if info.Mode()&os.ModeSymlink != 0 {
+			target, err := os.Readlink(path)
+			if err != nil {
+				return "", err
+			}
+			fmt.Fprintf(hash, "link:%s\x00", target)
+			continue
+		}
+
+		if err := hashFile(hash, path); err != nil {
+			return "", err
+		}
+		hash.Write([]byte{0})
+	}
+
+	return "sha256:" + hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+func hashFile(w io.Writer, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = io.Copy(w, file)
+	return err
+}
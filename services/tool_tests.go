@@ -0,0 +1,323 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"mcphub/mcp"
+	"mcphub/models"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ToolTestFileNames are the names tool tests are read from next to mcp.json, in order of
+// preference
+var ToolTestFileNames = []string{"mcp.tests.json", "mcp.tests.yaml", "mcp.tests.yml"}
+
+// FindToolTests returns the path of the tool test file in dir, or "" when it has none
+func FindToolTests(dir string) string {
+	for _, name := range ToolTestFileNames {
+		path := filepath.Join(dir, name)
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return ""
+}
+
+// ReadToolTests loads a tool test file. JSON is read as YAML, which it is a subset of.
+// Unknown fields are rejected so misspelled matchers do not pass silently.
+func ReadToolTests(path string) (*models.ToolTestFile, error) {
+	name := filepath.Base(path)
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", name, err)
+	}
+	var document any
+	if err := yaml.Unmarshal(content, &document); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", name, err)
+	}
+	data, err := json.Marshal(document)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", name, err)
+	}
+
+	var file models.ToolTestFile
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&file); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", name, err)
+	}
+	for i := range file.Tests {
+		test := &file.Tests[i]
+		if test.Tool == "" {
+			return nil, fmt.Errorf("%s: test %d has no tool", name, i+1)
+		}
+		if test.Name == "" {
+			test.Name = test.Tool
+		}
+		for _, pattern := range test.Expect.Regex {
+			if _, err := regexp.Compile(pattern); err != nil {
+				return nil, fmt.Errorf("%s: %s: invalid regex: %w", name, test.Name, err)
+			}
+		}
+	}
+	return &file, nil
+}
+
+// ToolTestResult is the outcome of a tool test
+type ToolTestResult struct {
+	Test models.ToolTest
+	// Failures are the expectations the result did not meet, with diffs; none means it passed
+	Failures []string
+	// Text is the text of the result, for reporting failures
+	Text     string
+	Duration time.Duration
+}
+
+// Passed reports whether the result met every expectation
+func (r ToolTestResult) Passed() bool {
+	return len(r.Failures) == 0
+}
+
+// RunImageToolTests runs tests against image in a throwaway container. env holds NAME=value
+// (or NAME, passed through) entries for the server.
+func RunImageToolTests(ctx context.Context, rt *ContainerRuntime, image string, env []string, tests []models.ToolTest) ([]ToolTestResult, error) {
+	env, err := serverEnv(rt, image, env)
+	if err != nil {
+		return nil, err
+	}
+	session, err := StartSession(ctx, rt, image, env)
+	if err != nil {
+		return nil, err
+	}
+	defer session.Close()
+
+	results := make([]ToolTestResult, 0, len(tests))
+	for _, test := range tests {
+		results = append(results, RunToolTest(ctx, session.Client, test))
+	}
+	return results, nil
+}
+
+// RunToolTest calls the tool of test and matches the result. JSON-RPC errors always fail
+// the test; tools report expected failures in the result.
+func RunToolTest(ctx context.Context, client *mcp.Client, test models.ToolTest) ToolTestResult {
+	started := time.Now()
+	called, err := client.CallTool(ctx, test.Tool, test.Arguments)
+	result := ToolTestResult{Test: test, Duration: time.Since(started)}
+	if err != nil {
+		result.Failures = []string{fmt.Sprintf("the call failed: %v", err)}
+		return result
+	}
+	result.Text = resultText(called)
+	result.Failures = MatchToolResult(test.Expect, called)
+	return result
+}
+
+// MatchToolResult lists the expectations result does not meet
+func MatchToolResult(expect models.ToolExpectation, result *mcp.CallToolResult) []string {
+	text := resultText(result)
+	switch {
+	case result.IsError && !expect.IsError:
+		return []string{"the tool reported an error"}
+	case !result.IsError && expect.IsError:
+		return []string{"the tool succeeded, but an error was expected"}
+	}
+
+	var failures []string
+	if len(expect.Exact) > 0 {
+		var exact string
+		if json.Unmarshal(expect.Exact, &exact) == nil {
+			if text != exact {
+				failures = append(failures, "the text is not the expected text:\n"+diffLines(exact, text))
+			}
+		} else if document, err := resultDocument(result); err != nil {
+			failures = append(failures, err.Error())
+		} else if expected := normalizeJSON(expect.Exact); expected != indentJSON(document) {
+			failures = append(failures, "the result is not the expected JSON:\n"+diffLines(expected, indentJSON(document)))
+		}
+	}
+	for _, substring := range expect.Contains {
+		if !strings.Contains(text, substring) {
+			failures = append(failures, fmt.Sprintf("the text does not contain %q", substring))
+		}
+	}
+	for _, pattern := range expect.Regex {
+		if matcher, err := regexp.Compile(pattern); err != nil || !matcher.MatchString(text) {
+			failures = append(failures, fmt.Sprintf("the text does not match /%s/", pattern))
+		}
+	}
+
+	if len(expect.JSON) > 0 {
+		document, err := resultDocument(result)
+		if err != nil {
+			return append(failures, err.Error())
+		}
+		paths := make([]string, 0, len(expect.JSON))
+		for path := range expect.JSON {
+			paths = append(paths, path)
+		}
+		sort.Strings(paths)
+		for _, path := range paths {
+			value, err := lookupJSONPath(document, path)
+			if err != nil {
+				failures = append(failures, fmt.Sprintf("%s: %v", path, err))
+				continue
+			}
+			actual, _ := json.Marshal(value)
+			if expected := normalizeJSON(expect.JSON[path]); expected != indentJSON(value) {
+				failures = append(failures, fmt.Sprintf("%s is %s, expected %s", path, actual, compactJSON(expect.JSON[path])))
+			}
+		}
+	}
+	return failures
+}
+
+// resultText joins the text content of a result, one line per part
+func resultText(result *mcp.CallToolResult) string {
+	var parts []string
+	for _, content := range result.Content {
+		if content.Type == "text" {
+			parts = append(parts, content.Text)
+		}
+	}
+	return strings.Join(parts, "\n")
+}
+
+// resultDocument decodes the structured content of a result or, without any, its text
+func resultDocument(result *mcp.CallToolResult) (any, error) {
+	var document any
+	if len(result.StructuredContent) > 0 && json.Unmarshal(result.StructuredContent, &document) == nil {
+		return document, nil
+	}
+	if json.Unmarshal([]byte(resultText(result)), &document) != nil {
+		return nil, fmt.Errorf("the result has no structured content and its text is not JSON")
+	}
+	return document, nil
+}
+
+// lookupJSONPath finds the value at a path such as $.items[0].name; the leading $ is optional
+func lookupJSONPath(document any, path string) (any, error) {
+	rest := strings.TrimPrefix(path, "$")
+	if rest != "" && rest[0] != '.' && rest[0] != '[' {
+		rest = "." + rest
+	}
+	path = "$" + rest
+	value := document
+	for rest != "" {
+		at := path[:len(path)-len(rest)]
+		if rest[0] == '[' {
+			end := strings.Index(rest, "]")
+			if end < 0 {
+				return nil, fmt.Errorf("invalid path: missing ]")
+			}
+			index, err := strconv.Atoi(rest[1:end])
+			if err != nil {
+				return nil, fmt.Errorf("invalid path: %s is not an array index", rest[1:end])
+			}
+			array, ok := value.([]any)
+			if !ok {
+				return nil, fmt.Errorf("%s is not an array", at)
+			}
+			if index < 0 || index >= len(array) {
+				return nil, fmt.Errorf("%s has %d items, no index %d", at, len(array), index)
+			}
+			value = array[index]
+			rest = rest[end+1:]
+			continue
+		}
+
+		rest = rest[1:]
+		end := strings.IndexAny(rest, ".[")
+		if end < 0 {
+			end = len(rest)
+		}
+		key := rest[:end]
+		rest = rest[end:]
+		object, ok := value.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("%s is not an object", at)
+		}
+		if value, ok = object[key]; !ok {
+			return nil, fmt.Errorf("%s has no %q", at, key)
+		}
+	}
+	return value, nil
+}
+
+// normalizeJSON indents raw JSON with sorted keys, so equal values compare equal
+func normalizeJSON(raw json.RawMessage) string {
+	var value any
+	if json.Unmarshal(raw, &value) != nil {
+		return string(raw)
+	}
+	return indentJSON(value)
+}
+
+// indentJSON leaves <, > and & unescaped so diffs show text as the server sent it
+func indentJSON(value any) string {
+	var buffer bytes.Buffer
+	encoder := json.NewEncoder(&buffer)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(value)
+	return strings.TrimSuffix(buffer.String(), "\n")
+}
+
+func compactJSON(raw json.RawMessage) string {
+	var buffer bytes.Buffer
+	if json.Compact(&buffer, raw) != nil {
+		return string(raw)
+	}
+	return buffer.String()
+}
+
+// diffLines shows how actual differs from expected line by line: lines only expected are
+// prefixed with "-", lines only in actual with "+"
+func diffLines(expected, actual string) string {
+	a := strings.Split(expected, "\n")
+	b := strings.Split(actual, "\n")
+
+	// common[i][j] is the length of the longest common subsequence of a[i:] and b[j:]
+	common := make([][]int, len(a)+1)
+	for i := range common {
+		common[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				common[i][j] = common[i+1][j+1] + 1
+			} else {
+				common[i][j] = max(common[i+1][j], common[i][j+1])
+			}
+		}
+	}
+
+	var lines []string
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			lines = append(lines, "  "+a[i])
+			i++
+			j++
+		case i < len(a) && (j == len(b) || common[i+1][j] >= common[i][j+1]):
+			lines = append(lines, "- "+a[i])
+			i++
+		default:
+			lines = append(lines, "+ "+b[j])
+			j++
+		}
+	}
+	return strings.Join(lines, "\n")
+}
@@ -7,25 +7,71 @@ import (
 	"fmt"
 	"io"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"strings"
 
+	"mcphub/mcp"
 	"mcphub/models"
 )
 
 type ZipProcessor struct {
 	dockerfileGenerator *DockerfileGenerator
+	runtime             *ContainerRuntime
+	options             ProcessOptions
+	progress            io.Writer
 }
 
-func NewZipProcessor() *ZipProcessor {
+// ProcessOptions controls what the processor builds and keeps, and how it reports progress
+type ProcessOptions struct {
+	// Build is applied to every image build
+	Build BuildOptions
+	// Platforms overrides the platforms listed in mcp.json (empty builds for the host only)
+	Platforms []string
+	// Force rebuilds even when a local image was built from identical sources
+	Force bool
+	// SkipArchive leaves built images in the local store without saving tar archives
+	SkipArchive bool
+	// SkipIntrospection publishes without starting the server to record what it offers
+	SkipIntrospection bool
+	// TemplatesDir holds custom Dockerfile templates overriding the built-in ones
+	TemplatesDir string
+	// Progress receives status lines about the build (discarded when nil)
+	Progress io.Writer
+}
+
+func NewZipProcessor(runtime *ContainerRuntime, options ProcessOptions) *ZipProcessor {
 	return &ZipProcessor{
-		dockerfileGenerator: NewDockerfileGenerator(),
+		dockerfileGenerator: NewDockerfileGeneratorWithTemplates(options.TemplatesDir),
+		runtime:             runtime,
+		options:             options,
+		progress:            orDiscard(options.Progress),
 	}
 }
 
+// PreparedBuild is an extracted MCP server whose Dockerfile is ready to be built
+type PreparedBuild struct {
+	Config         *models.MCPConfig
+	BaseName       string
+	ExtractedPath  string
+	ContextDir     string
+	DockerfilePath string
+	ImageName      string
+	SourceHash     string
+	// DockerfileGenerated is false when the project supplied its own Dockerfile
+	DockerfileGenerated bool
+}
+
 // ProcessZip accepts zip data and filename, extracts contents, generates Dockerfile, builds and saves the image.
 func (zp *ZipProcessor) ProcessZip(zipData []byte, zipFileName string) (*models.DockerfileResponse, error) {
+	prepared, err := zp.PrepareZip(zipData, zipFileName)
+	if err != nil {
+		return nil, err
+	}
+	return zp.BuildPrepared(prepared)
+}
+
+// PrepareZip extracts the zip, parses mcp.json, writes the Dockerfile and hashes the build context
+func (zp *ZipProcessor) PrepareZip(zipData []byte, zipFileName string) (*PreparedBuild, error) {
 	// Load zip archive from byte slice
 	reader, err := zip.NewReader(bytes.NewReader(zipData), int64(len(zipData)))
 	if err != nil {
@@ -33,7 +79,8 @@ func (zp *ZipProcessor) ProcessZip(zipData []byte, zipFileName string) (*models.
 	}
 
 	// Prepare extraction directory, cleaning if it exists
-	extractDir := filepath.Join("extracted", strings.TrimSuffix(zipFileName, ".zip"))
+	baseName := strings.TrimSuffix(zipFileName, ".zip")
+	extractDir := filepath.Join("extracted", baseName)
 	if err := os.RemoveAll(extractDir); err != nil {
 		return nil, fmt.Errorf("failed to clean extraction directory: %w", err)
 	}
@@ -52,51 +99,311 @@ func (zp *ZipProcessor) ProcessZip(zipData []byte, zipFileName string) (*models.
 		return nil, err
 	}
 
-	// Generate Dockerfile text from config
-	dockerfileContent := zp.dockerfileGenerator.Generate(mcpConfig)
-
-	// Write Dockerfile next to mcp.json
-	dockerfilePath := filepath.Join(mcpDir, "Dockerfile")
-	if err := os.WriteFile(dockerfilePath, []byte(dockerfileContent), 0644); err != nil {
-		return nil, fmt.Errorf("failed to write Dockerfile: %w", err)
-	}
-
-	// Build Docker image
-	imageName := strings.ToLower(mcpConfig.Name)
-	if err := zp.buildDockerImage(mcpDir, imageName); err != nil {
+	// Use the server's own Dockerfile when it has one, otherwise generate it from config
+	dockerfilePath, generated, err := zp.resolveDockerfile(mcpConfig, mcpDir)
+	if err != nil {
 		return nil, err
 	}
+	if !generated {
+		fmt.Fprintf(zp.progress, "🐳 Using Dockerfile from the project: %s\n", dockerfilePath)
+	}
 
-	// Create temp directory for tar file
-	tempDir, err := os.MkdirTemp("", "mcphub-*")
+	report, err := InspectBuildContext(mcpDir)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create temp directory: %w", err)
+		return nil, fmt.Errorf("failed to inspect build context: %w", err)
+	}
+	fmt.Fprintf(zp.progress, "📦 Build context: %d files, %s\n", len(report.Files), formatSize(report.Size))
+	for _, secret := range report.Secrets {
+		fmt.Fprintf(zp.progress, "⚠️  %s looks like a secret and will be copied into the image; add it to .dockerignore\n", secret)
 	}
-	defer os.RemoveAll(tempDir) // Clean up temp directory when done
 
-	// Save Docker image as tar archive in temp directory
-	tarFileName := strings.TrimSuffix(zipFileName, ".zip") + ".tar"
-	tarFilePath := filepath.Join(tempDir, tarFileName)
-	if err := zp.saveDockerImage(imageName, tarFilePath); err != nil {
-		return nil, err
+	// Fingerprint sources and Dockerfile so unchanged servers are not rebuilt
+	sourceHash, err := HashBuildContext(mcpDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash build context: %w", err)
 	}
 
-	// Return absolute paths in response
+	// Return absolute paths
 	absExtractDir, _ := filepath.Abs(extractDir)
 	absDockerfilePath, _ := filepath.Abs(dockerfilePath)
-	absTarFilePath, _ := filepath.Abs(tarFilePath)
 
-	return &models.DockerfileResponse{
+	return &PreparedBuild{
+		Config:         mcpConfig,
+		BaseName:       baseName,
 		ExtractedPath:  absExtractDir,
+		ContextDir:     mcpDir,
 		DockerfilePath: absDockerfilePath,
-		ImageName:      imageName,
-		TarFilePath:    absTarFilePath,
-		Config:         *mcpConfig,
+		ImageName:      strings.ToLower(mcpConfig.Name),
+		SourceHash:     sourceHash,
+
+		DockerfileGenerated: generated,
+	}, nil
+}
+
+// resolveDockerfile returns the Dockerfile to build and whether it was generated. A
+// build.dockerfile path in mcp.json or a Dockerfile next to mcp.json is used as is;
+// otherwise a Dockerfile is generated from the configuration and written next to mcp.json.
+// Build fields that only apply to generated Dockerfiles are rejected with a project one.
+func (zp *ZipProcessor) resolveDockerfile(config *models.MCPConfig, mcpDir string) (string, bool, error) {
+	if config.Build != nil && config.Build.Dockerfile != "" {
+		dockerfilePath := filepath.Join(mcpDir, config.Build.Dockerfile)
+		rel, err := filepath.Rel(mcpDir, dockerfilePath)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return "", false, fmt.Errorf("build.dockerfile %q must be inside the project", config.Build.Dockerfile)
+		}
+		if _, err := os.Stat(dockerfilePath); err != nil {
+			return "", false, fmt.Errorf("build.dockerfile %q not found", config.Build.Dockerfile)
+		}
+		return dockerfilePath, false, generatedOnly(config, config.Build.Dockerfile)
+	}
+
+	dockerfilePath := filepath.Join(mcpDir, "Dockerfile")
+	if _, err := os.Stat(dockerfilePath); err == nil {
+		return dockerfilePath, false, generatedOnly(config, "Dockerfile")
+	}
+
+	// Generate Dockerfile text from config and the project's version files
+	project, err := DetectProject(mcpDir, config)
+	if err != nil {
+		return "", false, err
+	}
+	if project.VersionSource != "" && (config.Runtime == nil || config.Runtime.Version == "") {
+		fmt.Fprintf(zp.progress, "🔎 Runtime version %s from %s\n", project.RuntimeVersion, project.VersionSource)
+	}
+	if project.Install != nil {
+		fmt.Fprintf(zp.progress, "📦 Installing dependencies with %s\n", project.Install.Manager)
+	}
+	dockerfileContent, err := zp.dockerfileGenerator.GenerateForProject(config, project)
+	if err != nil {
+		return "", false, err
+	}
+	if err := writeDockerignore(config, mcpDir); err != nil {
+		return "", false, err
+	}
+
+	// Write Dockerfile next to mcp.json
+	if err := os.WriteFile(dockerfilePath, []byte(dockerfileContent), 0644); err != nil {
+		return "", false, fmt.Errorf("failed to write Dockerfile: %w", err)
+	}
+	return dockerfilePath, true, nil
+}
+
+// generatedOnly rejects the build fields of config that only shape generated Dockerfiles,
+// which a project Dockerfile would silently ignore
+func generatedOnly(config *models.MCPConfig, dockerfile string) error {
+	build := config.Build
+	if build == nil {
+		return nil
+	}
+	var fields []string
+	if build.BaseImage != "" {
+		fields = append(fields, "build.base_image")
+	}
+	if len(build.Packages) > 0 {
+		fields = append(fields, "build.packages")
+	}
+	if len(build.Run) > 0 {
+		fields = append(fields, "build.run")
+	}
+	if build.PackageManager != "" {
+		fields = append(fields, "build.package_manager")
+	}
+	if len(fields) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%s only apply to generated Dockerfiles, but the project's %s is used; do their work in that Dockerfile instead", strings.Join(fields, ", "), dockerfile)
+}
+
+// BuildPrepared builds the image(s) for a prepared server and saves each as a tar archive
+func (zp *ZipProcessor) BuildPrepared(prepared *PreparedBuild) (*models.DockerfileResponse, error) {
+	artifacts, introspection, err := zp.buildAndSave(prepared)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.DockerfileResponse{
+		ExtractedPath:  prepared.ExtractedPath,
+		DockerfilePath: prepared.DockerfilePath,
+		ImageName:      prepared.ImageName,
+		TarFilePath:    artifacts[len(artifacts)-1].TarFilePath,
+		BuildLogPath:   artifacts[len(artifacts)-1].BuildLogPath,
+		Artifacts:      artifacts,
+		SourceHash:     prepared.SourceHash,
+		Config:         *prepared.Config,
+		Introspection:  introspection,
 		Success:        true,
-		Message:        fmt.Sprintf("Successfully processed %s. Docker image saved as %s", zipFileName, tarFileName),
+		Message:        fmt.Sprintf("Successfully processed %s. Built %d image archive(s)", prepared.BaseName, len(artifacts)),
 	}, nil
 }
 
+// buildAndSave builds the image once per requested platform (or once for the host when none
+// are requested) and, unless archives are skipped, saves every build to its own tar archive in
+// a fresh temp directory. Images already built from the same sources are re-tagged instead of
+// rebuilt. The archives are left for the caller to upload and remove with RemoveArchives; the
+// temp directory is removed on failure.
+func (zp *ZipProcessor) buildAndSave(prepared *PreparedBuild) ([]models.ImageArtifact, *mcp.Introspection, error) {
+	platforms, err := zp.TargetPlatforms(prepared)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(platforms) > 0 {
+		if err := zp.runtime.SupportsPlatformBuilds(); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	// Create temp directory for tar files
+	var tempDir string
+	if !zp.options.SkipArchive {
+		if tempDir, err = os.MkdirTemp("", "mcphub-*"); err != nil {
+			return nil, nil, fmt.Errorf("failed to create temp directory: %w", err)
+		}
+	}
+
+	targets := platforms
+	if len(targets) == 0 {
+		targets = []string{""}
+	}
+
+	options := zp.options.Build
+	options.Labels = BuildLabels(prepared.ContextDir)
+	if !prepared.DockerfileGenerated {
+		// The project's own Dockerfile does not carry the metadata labels
+		for key, value := range MetadataLabels(prepared.Config) {
+			options.Labels[key] = value
+		}
+	}
+	options.Labels[LabelSourceHash] = prepared.SourceHash
+	for key, value := range zp.options.Build.Labels {
+		options.Labels[key] = value
+	}
+	options.Dockerfile, _ = filepath.Rel(prepared.ContextDir, prepared.DockerfilePath)
+	if build := prepared.Config.Build; build != nil {
+		options.BuildArgs = build.Args
+	}
+
+	imageName := prepared.ImageName
+	var builds []*imageBuild
+	var host *imageBuild
+	for _, platform := range targets {
+		// Keep each platform's image addressable once the next build reuses the name. Host
+		// builds get the host platform's tag, so the source hash is never read from an image
+		// of another platform that an earlier multi-platform build left under the name.
+		tag := platform
+		if tag == "" {
+			tag = HostPlatform()
+		}
+		build := &imageBuild{ImageArtifact: models.ImageArtifact{Platform: platform, Image: imageName + ":" + PlatformTag(tag)}}
+		if tag == HostPlatform() {
+			host = build
+		}
+		builds = append(builds, build)
+	}
+
+	// The host image is built first since it is the one introspected. What it reports is
+	// passed as a label to the builds of the other platforms, and the host image is built
+	// again with it, which only writes a new image config as every layer is cached.
+	if host != nil {
+		if err := zp.build(prepared, host, options); err != nil {
+			os.RemoveAll(tempDir)
+			return nil, nil, err
+		}
+	}
+	introspection := zp.introspect(host)
+	if introspection != nil {
+		if content, err := json.Marshal(introspection); err == nil {
+			options.Labels[LabelIntrospection] = string(content)
+		}
+	}
+	for _, build := range builds {
+		if build == host && (introspection == nil || zp.runtime.ImageLabel(host.Image, LabelIntrospection) == options.Labels[LabelIntrospection]) {
+			continue
+		}
+		if err := zp.build(prepared, build, options); err != nil {
+			os.RemoveAll(tempDir)
+			return nil, nil, err
+		}
+	}
+
+	var artifacts []models.ImageArtifact
+	for _, build := range builds {
+		// The platform image becomes the plain image name, so it is what gets saved; the
+		// host platform comes last and keeps the name afterwards
+		if err := zp.runtime.Tag(build.Image, imageName); err != nil {
+			os.RemoveAll(tempDir)
+			return nil, nil, err
+		}
+
+		if !zp.options.SkipArchive {
+			tarFileName := prepared.BaseName + ".tar"
+			if build.Platform != "" {
+				tarFileName = prepared.BaseName + "." + PlatformTag(build.Platform) + ".tar"
+			}
+			tarFilePath := filepath.Join(tempDir, tarFileName)
+			if err := zp.runtime.Save(imageName, tarFilePath); err != nil {
+				os.RemoveAll(tempDir)
+				return nil, nil, err
+			}
+			build.TarFilePath, _ = filepath.Abs(tarFilePath)
+		}
+
+		artifacts = append(artifacts, build.ImageArtifact)
+	}
+
+	return artifacts, introspection, nil
+}
+
+// build builds the image of one platform with options and tags it with its platform tag.
+// An image built from the same sources, and carrying the same introspection when one is
+// passed, is reused instead.
+func (zp *ZipProcessor) build(prepared *PreparedBuild, build *imageBuild, options BuildOptions) error {
+	build.cached = !zp.options.Force &&
+		zp.runtime.ImageLabel(build.Image, LabelSourceHash) == prepared.SourceHash &&
+		(options.Labels[LabelIntrospection] == "" || zp.runtime.ImageLabel(build.Image, LabelIntrospection) == options.Labels[LabelIntrospection])
+	if build.cached {
+		fmt.Fprintf(zp.progress, "♻️  %s is up to date with the sources, skipping build\n", build.Image)
+		return nil
+	}
+	if build.Platform != "" {
+		fmt.Fprintf(zp.progress, "🏗️  Building for %s...\n", build.Platform)
+	}
+
+	buildLogPath, err := zp.runtime.Build(prepared.ContextDir, prepared.ImageName, build.Platform, options)
+	if err != nil {
+		return err
+	}
+	build.BuildLogPath, _ = filepath.Abs(buildLogPath)
+	return zp.runtime.Tag(prepared.ImageName, build.Image)
+}
+
+// RemoveArchives deletes the temp directory holding the tar archives of a build once they
+// are uploaded
+func RemoveArchives(result *models.DockerfileResponse) error {
+	for _, artifact := range result.Artifacts {
+		if artifact.TarFilePath != "" {
+			return os.RemoveAll(filepath.Dir(artifact.TarFilePath))
+		}
+	}
+	return nil
+}
+
+// TargetPlatforms returns the normalized platforms a prepared server is built for: those of
+// the build options, else those in mcp.json. None means the builder's platform only.
+func (zp *ZipProcessor) TargetPlatforms(prepared *PreparedBuild) ([]string, error) {
+	platforms := zp.options.Platforms
+	if len(platforms) == 0 {
+		platforms = prepared.Config.Platforms
+	}
+	return NormalizePlatforms(platforms)
+}
+
+// imageBuild is an artifact while it is being built
+type imageBuild struct {
+	models.ImageArtifact
+	// cached is set when an image built from identical sources was reused
+	cached bool
+}
+
 // extractZip extracts files from the zip archive, flattening single-folder archives
 func (zp *ZipProcessor) extractZip(reader *zip.Reader, extractDir string) error {
 	var commonPrefix string
@@ -200,27 +507,55 @@ func (zp *ZipProcessor) findAndParseMCPConfigFromDir(extractDir string) (*models
 	if mcpConfig.Name == "" || mcpConfig.Run.Command == "" {
 		return nil, "", fmt.Errorf("mcp.json missing required fields 'name' or 'run.command'")
 	}
+	if err := validateConfig(&mcpConfig); err != nil {
+		return nil, "", fmt.Errorf("invalid mcp.json: %w", err)
+	}
 
 	return &mcpConfig, filepath.Dir(mcpFilePath), nil
 }
 
-// buildDockerImage builds a Docker image from the given context directory with the specified image name
-func (zp *ZipProcessor) buildDockerImage(buildContext, imageName string) error {
-	cmd := exec.Command("docker", "build", "-t", imageName, ".")
-	cmd.Dir = buildContext
-	output, err := cmd.CombinedOutput()
+// ZipDirectory archives a project directory in memory, as PrepareZip expects it. Version
+// control data is left out.
+func ZipDirectory(dir string) ([]byte, error) {
+	var buffer bytes.Buffer
+	writer := zip.NewWriter(&buffer)
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() && info.Name() == ".git" {
+			return filepath.SkipDir
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		header, err := zip.FileInfoHeader(info)
+		if err != nil {
+			return err
+		}
+		header.Name = filepath.ToSlash(rel)
+		header.Method = zip.Deflate
+		entry, err := writer.CreateHeader(header)
+		if err != nil {
+			return err
+		}
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		_, err = io.Copy(entry, file)
+		return err
+	})
 	if err != nil {
-		return fmt.Errorf("docker build failed: %w\nOutput: %s", err, output)
+		return nil, fmt.Errorf("failed to archive %s: %w", dir, err)
 	}
-	return nil
-}
-
-// saveDockerImage saves the specified Docker image to a tarball
-func (zp *ZipProcessor) saveDockerImage(imageName, tarFilePath string) error {
-	cmd := exec.Command("docker", "save", "-o", tarFilePath, imageName)
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		return fmt.Errorf("docker save failed: %w\nOutput: %s", err, output)
+	if err := writer.Close(); err != nil {
+		return nil, fmt.Errorf("failed to archive %s: %w", dir, err)
 	}
-	return nil
+	return buffer.Bytes(), nil
 }